@@ -0,0 +1,279 @@
+// Command dsn_bounces procesa los rebotes (DSN, RFC 3464) del envío por SMTP: registra los eventos de cada
+// destinatario y agrega los rebotes permanentes a la lista de supresión.
+//
+// Uso:
+//
+//	dsn_bounces [-dry-run] archivo.eml|directorio ...
+//	dsn_bounces -imap [-mailbox INBOX] [-dry-run]
+//
+// Con -imap se leen los mensajes no leídos del buzón configurado en IMAP_SERVER, IMAP_PORT y SECRETS_IMAP; los
+// DSN procesados se marcan como leídos y los demás correos se dejan sin leer.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"gmf_message_processor/config"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/mailbox"
+	"gmf_message_processor/internal/notification"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm/logger"
+)
+
+const cliMessageID = "dsn-bounces-cli"
+
+// eventProcessor registra los eventos de un DSN (implementado por handler.SESNotificationHandler).
+type eventProcessor interface {
+	ProcessEvent(ctx context.Context, event *notification.Event, deliveryID string) error
+}
+
+// messageSource es el origen de los correos a procesar: archivos .eml o un buzón IMAP.
+type messageSource interface {
+	List() ([]string, error)
+	Fetch(id string) ([]byte, error)
+	MarkProcessed(id string) error
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	fs := flag.NewFlagSet("dsn_bounces", flag.ExitOnError)
+	useIMAP := fs.Bool("imap", false, "leer los mensajes no leídos del buzón IMAP configurado")
+	mailboxName := fs.String("mailbox", envOrDefault("IMAP_MAILBOX", "INBOX"), "buzón IMAP a procesar")
+	dryRun := fs.Bool("dry-run", false, "solo mostrar los rebotes encontrados, sin registrarlos")
+	_ = fs.Parse(os.Args[1:])
+
+	if err := execute(*useIMAP, *mailboxName, *dryRun, fs.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// execute construye el origen de los correos y el procesador de eventos según los parámetros.
+func execute(useIMAP bool, mailboxName string, dryRun bool, paths []string) error {
+	if !useIMAP && len(paths) == 0 {
+		return errors.New("uso: dsn_bounces [-dry-run] archivo.eml|directorio ... | dsn_bounces -imap [-mailbox INBOX]")
+	}
+
+	var secretService connection.SecretService
+	if useIMAP || !dryRun {
+		sess, err := connection.NewSession(cliMessageID)
+		if err != nil {
+			return fmt.Errorf("error al crear la sesión de AWS: %w", err)
+		}
+		secretService = connection.NewSecretService(sess)
+	}
+
+	var source messageSource
+	if useIMAP {
+		client, err := dialIMAPFromEnv(secretService, mailboxName)
+		if err != nil {
+			return err
+		}
+		defer client.Logout()
+		source = &imapSource{client: client}
+	} else {
+		files, err := expandPaths(paths)
+		if err != nil {
+			return err
+		}
+		source = fileSource(files)
+	}
+
+	var processor eventProcessor
+	if !dryRun {
+		dbManager := connection.NewDBManager(secretService, logger.Default.LogMode(logger.Warn))
+		notificationHandler, err := config.InitNotificationHandler(cliMessageID, dbManager)
+		if err != nil {
+			return fmt.Errorf("error al inicializar el manejador de notificaciones: %w", err)
+		}
+		defer dbManager.CloseDB(cliMessageID)
+		processor = notificationHandler
+	}
+
+	return run(context.Background(), source, processor, os.Stdout)
+}
+
+// run procesa cada correo del origen. Si processor es nil (modo -dry-run) solo muestra los rebotes encontrados.
+func run(ctx context.Context, source messageSource, processor eventProcessor, out io.Writer) error {
+	ids, err := source.List()
+	if err != nil {
+		return err
+	}
+
+	var procesados, ignorados int
+	for _, id := range ids {
+		raw, err := source.Fetch(id)
+		if err != nil {
+			return err
+		}
+
+		dsn, err := notification.ParseDSN(bytes.NewReader(raw))
+		if errors.Is(err, notification.ErrNotDSN) {
+			ignorados++
+			continue
+		}
+		if err != nil {
+			// Un reporte malformado se deja sin marcar para revisarlo manualmente
+			logs.LogWarn(fmt.Sprintf("No fue posible interpretar el DSN %s: %v", id, err), cliMessageID)
+			ignorados++
+			continue
+		}
+
+		printDSN(out, id, dsn)
+		if processor == nil {
+			continue
+		}
+
+		for _, event := range dsn.Events() {
+			if err := processor.ProcessEvent(ctx, event, id); err != nil {
+				return fmt.Errorf("error procesando el DSN %s: %w", id, err)
+			}
+		}
+		if err := source.MarkProcessed(id); err != nil {
+			return err
+		}
+		procesados++
+	}
+
+	fmt.Fprintf(out, "DSN procesados: %d, correos ignorados: %d\n", procesados, ignorados)
+	return nil
+}
+
+// printDSN muestra el resumen de un DSN.
+func printDSN(out io.Writer, id string, dsn *notification.DSN) {
+	messageID := dsn.MessageID
+	if messageID == "" {
+		messageID = "desconocido"
+	}
+	fmt.Fprintf(out, "%s: DSN de %s (mensaje %s)\n", id, dsn.ReportingMTA, messageID)
+	for _, recipient := range dsn.Recipients {
+		fmt.Fprintf(out, "  %s\t%s\t%s\t%s\n", recipient.Address, recipient.Action, recipient.Status,
+			recipient.DiagnosticCode)
+	}
+}
+
+// fileSource lee los correos de archivos .eml.
+type fileSource []string
+
+func (f fileSource) List() ([]string, error) {
+	return f, nil
+}
+
+func (f fileSource) Fetch(id string) ([]byte, error) {
+	raw, err := os.ReadFile(id)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo %s: %w", id, err)
+	}
+	return raw, nil
+}
+
+func (f fileSource) MarkProcessed(string) error {
+	return nil
+}
+
+// expandPaths reemplaza cada directorio por los archivos .eml que contiene.
+func expandPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "*.eml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// imapSource lee los mensajes no leídos de un buzón IMAP y marca como leídos los DSN procesados.
+type imapSource struct {
+	client *mailbox.IMAPClient
+}
+
+func (s *imapSource) List() ([]string, error) {
+	uids, err := s.client.SearchUnseen()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		ids = append(ids, strconv.FormatUint(uint64(uid), 10))
+	}
+	return ids, nil
+}
+
+func (s *imapSource) Fetch(id string) ([]byte, error) {
+	uid, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.FetchMessage(uint32(uid))
+}
+
+func (s *imapSource) MarkProcessed(id string) error {
+	uid, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return err
+	}
+	return s.client.MarkSeen(uint32(uid))
+}
+
+// dialIMAPFromEnv abre el buzón configurado en IMAP_SERVER, IMAP_PORT (993 por defecto), IMAP_TLS y
+// SECRETS_IMAP.
+func dialIMAPFromEnv(secretService connection.SecretService, mailboxName string) (*mailbox.IMAPClient, error) {
+	server := os.Getenv("IMAP_SERVER")
+	if server == "" {
+		return nil, errors.New("la variable IMAP_SERVER no está configurada")
+	}
+	secret, err := secretService.GetSecret(os.Getenv("SECRETS_IMAP"), cliMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("error al obtener las credenciales IMAP: %w", err)
+	}
+
+	useTLS := !strings.EqualFold(os.Getenv("IMAP_TLS"), "false")
+	addr := net.JoinHostPort(server, envOrDefault("IMAP_PORT", "993"))
+	client, err := mailbox.DialIMAP(addr, useTLS, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Login(secret.Username, secret.Password); err != nil {
+		client.Logout()
+		return nil, err
+	}
+	if err := client.Select(mailboxName); err != nil {
+		client.Logout()
+		return nil, err
+	}
+	return client, nil
+}
+
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gmf_message_processor/internal/notification"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var dsnFixtures = filepath.Join("..", "..", "test_data", "dsn")
+
+// fakeProcessor guarda los eventos recibidos.
+type fakeProcessor struct {
+	events []*notification.Event
+	err    error
+}
+
+func (f *fakeProcessor) ProcessEvent(_ context.Context, event *notification.Event, _ string) error {
+	f.events = append(f.events, event)
+	return f.err
+}
+
+// memorySource simula un buzón con los mensajes en memoria.
+type memorySource struct {
+	messages  map[string][]byte
+	order     []string
+	processed []string
+}
+
+func newMemorySource(t *testing.T, files ...string) *memorySource {
+	source := &memorySource{messages: map[string][]byte{}}
+	for _, file := range files {
+		raw, err := os.ReadFile(filepath.Join(dsnFixtures, file))
+		if err != nil {
+			t.Fatalf("Error leyendo el fixture %s: %v", file, err)
+		}
+		source.messages[file] = raw
+		source.order = append(source.order, file)
+	}
+	return source
+}
+
+func (m *memorySource) List() ([]string, error)         { return m.order, nil }
+func (m *memorySource) Fetch(id string) ([]byte, error) { return m.messages[id], nil }
+func (m *memorySource) MarkProcessed(id string) error {
+	m.processed = append(m.processed, id)
+	return nil
+}
+
+func TestRunProcessesDSNAndSkipsOtherMail(t *testing.T) {
+	source := newMemorySource(t, "postfix_hard_bounce.eml", "not_a_dsn.eml", "exchange_quota_bounce.eml")
+	processor := &fakeProcessor{}
+	var out bytes.Buffer
+
+	err := run(context.Background(), source, processor, &out)
+
+	assert.NoError(t, err)
+	// Rebote permanente y temporal del primer DSN, rebote permanente del segundo
+	assert.Len(t, processor.events, 3)
+	assert.Equal(t, notification.SourceDSN, processor.events[0].Source)
+	assert.True(t, processor.events[0].Permanent)
+	assert.Equal(t, "buzon@cliente.com.co", processor.events[2].Recipients[0].Address)
+	// El correo que no es un DSN se deja sin leer
+	assert.Equal(t, []string{"postfix_hard_bounce.eml", "exchange_quota_bounce.eml"}, source.processed)
+	assert.Contains(t, out.String(), "noexiste@example.com")
+	assert.Contains(t, out.String(), "DSN procesados: 2, correos ignorados: 1")
+}
+
+func TestRunDryRunDoesNotProcess(t *testing.T) {
+	source := newMemorySource(t, "postfix_hard_bounce.eml")
+	var out bytes.Buffer
+
+	err := run(context.Background(), source, nil, &out)
+
+	assert.NoError(t, err)
+	assert.Empty(t, source.processed)
+	assert.Contains(t, out.String(), "e9cf1877-7fd5-4fba-8dc6-1ee9519355d4")
+}
+
+func TestRunStopsOnProcessingError(t *testing.T) {
+	source := newMemorySource(t, "postfix_hard_bounce.eml")
+	processor := &fakeProcessor{err: errors.New("base de datos no disponible")}
+
+	err := run(context.Background(), source, processor, &bytes.Buffer{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "postfix_hard_bounce.eml")
+	assert.Empty(t, source.processed)
+}
+
+func TestExpandPaths(t *testing.T) {
+	files, err := expandPaths([]string{dsnFixtures})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dsnFixtures, "exchange_quota_bounce.eml"),
+		filepath.Join(dsnFixtures, "not_a_dsn.eml"),
+		filepath.Join(dsnFixtures, "postfix_hard_bounce.eml"),
+	}, files)
+
+	_, err = expandPaths([]string{filepath.Join(dsnFixtures, "no_existe.eml")})
+	assert.Error(t, err)
+}
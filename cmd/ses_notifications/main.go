@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"gmf_message_processor/config"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"gorm.io/gorm/logger"
+	"log"
+	"os"
+	"time"
+)
+
+// Lambda que procesa las notificaciones de rebote, queja y entrega de SES recibidas por SQS o SNS.
+func main() {
+	sess, err := connection.NewSession("initMessageID")
+	if err != nil {
+		logs.LogError("Error al crear la sesión de AWS", err, "initMessageID")
+		return
+	}
+	secretService := connection.NewSecretService(sess)
+
+	newLogger := logger.New(
+		log.New(os.Stdout, "\r\n", log.LstdFlags),
+		logger.Config{
+			SlowThreshold:             time.Second,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+			Colorful:                  true,
+		},
+	)
+	dbManager := connection.NewDBManager(secretService, newLogger)
+
+	notificationHandler, err := config.InitNotificationHandler("", dbManager)
+	if err != nil {
+		logs.LogError("Error inicializando el manejador de notificaciones de SES", err, "")
+		config.CleanupApplication(dbManager, "")
+		return
+	}
+
+	lambda.Start(notificationHandler.HandleEvent)
+
+	config.CleanupApplication(dbManager, "")
+}
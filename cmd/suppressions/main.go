@@ -0,0 +1,170 @@
+// Command suppressions administra la lista de supresión de correos (cgd_correos_supresiones).
+//
+// Uso:
+//
+//	suppressions list
+//	suppressions add -address usuario@dominio.com [-reason manual] [-source cli] [-expires 720h]
+//	suppressions remove -address usuario@dominio.com
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/email"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"gmf_message_processor/internal/repository"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm/logger"
+)
+
+const cliMessageID = "suppressions-cli"
+
+// supresionStore define las operaciones que utiliza la herramienta sobre la lista de supresión.
+type supresionStore interface {
+	ListSupresiones() ([]models.Supresion, error)
+	AddSupresion(supresion *models.Supresion) error
+	RemoveSupresion(address string) (bool, error)
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	sess, err := connection.NewSession(cliMessageID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error al crear la sesión de AWS:", err)
+		os.Exit(1)
+	}
+
+	dbManager := connection.NewDBManager(connection.NewSecretService(sess), logger.Default.LogMode(logger.Warn))
+	if err := dbManager.InitDB(cliMessageID); err != nil {
+		fmt.Fprintln(os.Stderr, "error al conectar con la base de datos:", err)
+		os.Exit(1)
+	}
+
+	err = run(os.Args[1:], repository.NewSupresionRepository(dbManager.GetDB()), os.Stdout, time.Now)
+	dbManager.CloseDB(cliMessageID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run ejecuta el subcomando indicado en args.
+func run(args []string, store supresionStore, out io.Writer, now func() time.Time) error {
+	if len(args) == 0 {
+		return errors.New("uso: suppressions list | add | remove")
+	}
+
+	switch args[0] {
+	case "list":
+		return runList(store, out, now())
+	case "add":
+		return runAdd(args[1:], store, out, now())
+	case "remove":
+		return runRemove(args[1:], store, out)
+	default:
+		return fmt.Errorf("subcomando desconocido %q (use list, add o remove)", args[0])
+	}
+}
+
+func runList(store supresionStore, out io.Writer, now time.Time) error {
+	supresiones, err := store.ListSupresiones()
+	if err != nil {
+		return fmt.Errorf("error al listar las supresiones: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DIRECCION\tMOTIVO\tORIGEN\tEXPIRA\tVIGENTE")
+	for _, supresion := range supresiones {
+		expira := "-"
+		if supresion.ExpiraEn != nil {
+			expira = supresion.ExpiraEn.UTC().Format(time.RFC3339)
+		}
+		vigente := "si"
+		if !supresion.Vigente(now) {
+			vigente = "no"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", supresion.Direccion, supresion.Motivo, supresion.Origen, expira, vigente)
+	}
+	return w.Flush()
+}
+
+func runAdd(args []string, store supresionStore, out io.Writer, now time.Time) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	fs.SetOutput(out)
+	address := fs.String("address", "", "dirección de correo a suprimir")
+	reason := fs.String("reason", models.MotivoSupresionManual, "motivo: hard_bounce, complaint o manual")
+	source := fs.String("source", "cli", "origen de la supresión")
+	expires := fs.Duration("expires", 0, "vigencia de la supresión (por ejemplo 720h); 0 para indefinida")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	direccion, err := parseSingleAddress(*address)
+	if err != nil {
+		return err
+	}
+	switch *reason {
+	case models.MotivoSupresionRebote, models.MotivoSupresionQueja, models.MotivoSupresionManual:
+	default:
+		return fmt.Errorf("motivo %q no válido (use hard_bounce, complaint o manual)", *reason)
+	}
+
+	supresion := &models.Supresion{Direccion: direccion, Motivo: *reason, Origen: *source}
+	if *expires > 0 {
+		expiraEn := now.Add(*expires)
+		supresion.ExpiraEn = &expiraEn
+	}
+
+	if err := store.AddSupresion(supresion); err != nil {
+		return fmt.Errorf("error al agregar la supresión: %w", err)
+	}
+	logs.LogInfo(fmt.Sprintf("Supresión agregada para %s (%s)", supresion.Direccion, supresion.Motivo), cliMessageID)
+	fmt.Fprintf(out, "Supresión agregada: %s\n", supresion.Direccion)
+	return nil
+}
+
+func runRemove(args []string, store supresionStore, out io.Writer) error {
+	fs := flag.NewFlagSet("remove", flag.ContinueOnError)
+	fs.SetOutput(out)
+	address := fs.String("address", "", "dirección de correo a eliminar de la lista")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	direccion, err := parseSingleAddress(*address)
+	if err != nil {
+		return err
+	}
+
+	removed, err := store.RemoveSupresion(direccion)
+	if err != nil {
+		return fmt.Errorf("error al eliminar la supresión: %w", err)
+	}
+	if !removed {
+		return fmt.Errorf("la dirección %s no está en la lista de supresión", direccion)
+	}
+	logs.LogInfo(fmt.Sprintf("Supresión eliminada para %s", direccion), cliMessageID)
+	fmt.Fprintf(out, "Supresión eliminada: %s\n", direccion)
+	return nil
+}
+
+// parseSingleAddress valida que se haya indicado exactamente una dirección.
+func parseSingleAddress(raw string) (string, error) {
+	addresses, err := email.ParseAddressList(raw)
+	if err != nil {
+		return "", err
+	}
+	if len(addresses) != 1 {
+		return "", errors.New("indique una única dirección con -address")
+	}
+	return addresses[0].Address, nil
+}
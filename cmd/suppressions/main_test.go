@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore implementa supresionStore en memoria.
+type memoryStore struct {
+	supresiones map[string]models.Supresion
+	err         error
+}
+
+func (m *memoryStore) ListSupresiones() ([]models.Supresion, error) {
+	var out []models.Supresion
+	for _, supresion := range m.supresiones {
+		out = append(out, supresion)
+	}
+	return out, m.err
+}
+
+func (m *memoryStore) AddSupresion(supresion *models.Supresion) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.supresiones[supresion.Direccion] = *supresion
+	return nil
+}
+
+func (m *memoryStore) RemoveSupresion(address string) (bool, error) {
+	_, ok := m.supresiones[address]
+	delete(m.supresiones, address)
+	return ok, m.err
+}
+
+var fixedNow = func() time.Time { return time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC) }
+
+func TestRunAddListRemove(t *testing.T) {
+	store := &memoryStore{supresiones: map[string]models.Supresion{}}
+	var out bytes.Buffer
+
+	err := run([]string{"add", "-address", "Usuario@Test.COM", "-reason", "complaint", "-expires", "24h"},
+		store, &out, fixedNow)
+	assert.NoError(t, err)
+	supresion := store.supresiones["Usuario@test.com"]
+	assert.Equal(t, models.MotivoSupresionQueja, supresion.Motivo)
+	assert.Equal(t, "cli", supresion.Origen)
+	assert.Equal(t, fixedNow().Add(24*time.Hour), *supresion.ExpiraEn)
+
+	out.Reset()
+	assert.NoError(t, run([]string{"list"}, store, &out, fixedNow))
+	assert.Contains(t, out.String(), "Usuario@test.com  complaint  cli     2024-10-08T09:00:00Z  si")
+
+	out.Reset()
+	assert.NoError(t, run([]string{"remove", "-address", "Usuario@test.com"}, store, &out, fixedNow))
+	assert.Empty(t, store.supresiones)
+
+	err = run([]string{"remove", "-address", "Usuario@test.com"}, store, &out, fixedNow)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no está en la lista")
+}
+
+func TestRunAddValidation(t *testing.T) {
+	store := &memoryStore{supresiones: map[string]models.Supresion{}}
+	var out bytes.Buffer
+
+	assert.Error(t, run([]string{"add", "-address", "no-es-correo"}, store, &out, fixedNow))
+	assert.Error(t, run([]string{"add", "-address", "a@test.com, b@test.com"}, store, &out, fixedNow))
+	assert.Error(t, run([]string{"add", "-address", "a@test.com", "-reason", "spam"}, store, &out, fixedNow))
+	assert.Empty(t, store.supresiones)
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	store := &memoryStore{supresiones: map[string]models.Supresion{}, err: errors.New("sin conexión")}
+	var out bytes.Buffer
+
+	assert.Error(t, run(nil, store, &out, fixedNow))
+	assert.Error(t, run([]string{"purge"}, store, &out, fixedNow))
+	assert.Error(t, run([]string{"list"}, store, &out, fixedNow))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	internalAws "gmf_message_processor/internal/aws"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// sqsTimeout es el tiempo máximo para encolar el mensaje de invalidación.
+const sqsTimeout = 10 * time.Second
+
+// cacheNotifier solicita a las Lambdas que descarten una plantilla de su caché.
+type cacheNotifier interface {
+	InvalidateCache(idPlantilla string) error
+}
+
+// sqsCacheNotifier encola el mensaje de control invalidar_cache en la cola de la Lambda.
+type sqsCacheNotifier struct {
+	client   internalAws.SQSAPI
+	queueURL string
+}
+
+// newCacheNotifier crea el notificador con la cola SQS_QUEUE_URL (y SQS_ENDPOINT y AWS_REGION, igual que la
+// Lambda). Sin SQS_QUEUE_URL devuelve nil y no se solicita la invalidación.
+func newCacheNotifier() (cacheNotifier, error) {
+	queueURL := os.Getenv("SQS_QUEUE_URL")
+	if queueURL == "" {
+		return nil, nil
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("SQS_ENDPOINT")
+
+	loadConfigFunc := func(ctx context.Context, _ ...func(*awsConfig.LoadOptions) error) (aws.Config, error) {
+		options := []func(*awsConfig.LoadOptions) error{awsConfig.WithRegion(region)}
+		if endpoint != "" {
+			options = append(options, awsConfig.WithEndpointResolver(
+				aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
+					return aws.Endpoint{URL: endpoint, SigningRegion: region}, nil
+				}),
+			))
+		}
+		return awsConfig.LoadDefaultConfig(ctx, options...)
+	}
+
+	client, err := internalAws.NewSQSClient(queueURL, loadConfigFunc)
+	if err != nil {
+		return nil, err
+	}
+	return &sqsCacheNotifier{client: client, queueURL: queueURL}, nil
+}
+
+// InvalidateCache encola {"control": "invalidar_cache", "id_plantilla": ...}.
+func (n *sqsCacheNotifier) InvalidateCache(idPlantilla string) error {
+	body, err := json.Marshal(models.SQSMessage{IDPlantilla: idPlantilla, Control: models.ControlInvalidarCache})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sqsTimeout)
+	defer cancel()
+	_, err = n.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(n.queueURL),
+		MessageBody: aws.String(string(body)),
+	})
+	return err
+}
+
+// invalidateCache solicita que las Lambdas descarten la plantilla de su caché. Un fallo no revierte la versión
+// registrada: se informa y el cambio se aplica al vencer el TTL de la caché.
+func invalidateCache(cache cacheNotifier, idPlantilla string, out io.Writer) {
+	if cache == nil {
+		fmt.Fprintln(out, "SQS_QUEUE_URL no está configurada: el cambio se aplica al vencer TEMPLATE_CACHE_TTL")
+		return
+	}
+	if err := cache.InvalidateCache(idPlantilla); err != nil {
+		logs.LogWarn(fmt.Sprintf("No se pudo encolar la invalidación de la caché de la plantilla %s: %v",
+			idPlantilla, err), cliMessageID)
+		fmt.Fprintf(out, "No se pudo solicitar la invalidación de la caché (%v): el cambio se aplica al vencer "+
+			"TEMPLATE_CACHE_TTL\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Invalidación de la caché solicitada para la plantilla %s\n", idPlantilla)
+}
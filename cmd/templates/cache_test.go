@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSQS guarda los mensajes enviados.
+type fakeSQS struct {
+	sent []*sqs.SendMessageInput
+}
+
+func (f *fakeSQS) SendMessage(
+	_ context.Context, input *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, input)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(
+	context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestSQSCacheNotifierSendsControlMessage(t *testing.T) {
+	client := &fakeSQS{}
+	notifier := &sqsCacheNotifier{client: client, queueURL: "https://sqs.us-east-1.amazonaws.com/123/cola"}
+
+	assert.NoError(t, notifier.InvalidateCache("PC001"))
+
+	assert.Len(t, client.sent, 1)
+	assert.Equal(t, "https://sqs.us-east-1.amazonaws.com/123/cola", *client.sent[0].QueueUrl)
+	assert.Contains(t, *client.sent[0].MessageBody, `"id_plantilla":"PC001"`)
+	assert.Contains(t, *client.sent[0].MessageBody, `"control":"invalidar_cache"`)
+}
+
+func TestNewCacheNotifierWithoutQueue(t *testing.T) {
+	t.Setenv("SQS_QUEUE_URL", "")
+
+	notifier, err := newCacheNotifier()
+
+	assert.NoError(t, err)
+	assert.Nil(t, notifier)
+}
@@ -0,0 +1,274 @@
+// Command templates administra el historial de versiones de las plantillas (cgd_correos_plantillas_versiones) y de
+// sus layouts y parciales (cgd_correos_fragmentos_versiones).
+//
+// Uso:
+//
+//	templates versions -id PC001 [-locale en]
+//	templates snapshot -id PC001 [-locale en]
+//	templates rollback -id PC001 -version 3 [-locale en]
+//	templates snapshot -fragment pie [-locale en]
+//
+// snapshot registra el contenido actual de la plantilla (o del fragmento con -fragment) como una nueva versión
+// activa; debe ejecutarse después de editarlo. rollback restaura el contenido de una versión anterior y la marca como
+// activa. Sin -locale se usa el idioma predeterminado (DEFAULT_LOCALE). Después de snapshot y rollback de una
+// plantilla se encola en SQS_QUEUE_URL el mensaje de control invalidar_cache.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"gmf_message_processor/internal/repository"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm/logger"
+)
+
+const cliMessageID = "templates-cli"
+
+// versionStore define las operaciones que utiliza la herramienta sobre el historial de versiones.
+type versionStore interface {
+	ListVersions(idPlantilla, idioma string) ([]models.PlantillaVersion, error)
+	SnapshotPlantilla(idPlantilla, idioma string) (*models.PlantillaVersion, error)
+	RollbackPlantilla(idPlantilla, idioma string, version int) error
+	ListFragmentoVersions(nombre, idioma string) ([]models.FragmentoVersion, error)
+	SnapshotFragmento(nombre, idioma string) (*models.FragmentoVersion, error)
+	RollbackFragmento(nombre, idioma string, version int) error
+}
+
+// repositories reúne los historiales de versiones de las plantillas y de los fragmentos.
+type repositories struct {
+	*repository.GormVersionRepository
+	*repository.GormFragmentoRepository
+}
+
+// target es la plantilla o el fragmento sobre el que opera un subcomando.
+type target struct {
+	id       *string
+	fragment *string
+	locale   *string
+}
+
+func targetFlags(fs *flag.FlagSet) target {
+	return target{
+		id:       fs.String("id", "", "ID de la plantilla"),
+		fragment: fs.String("fragment", "", "nombre del layout o parcial (en lugar de -id)"),
+		locale:   fs.String("locale", "", "idioma de la plantilla (por defecto DEFAULT_LOCALE)"),
+	}
+}
+
+// validate exige exactamente uno de -id y -fragment.
+func (t target) validate() error {
+	hasID, hasFragment := strings.TrimSpace(*t.id) != "", strings.TrimSpace(*t.fragment) != ""
+	switch {
+	case hasID && hasFragment:
+		return errors.New("indique -id o -fragment, no ambos")
+	case !hasID && !hasFragment:
+		return errors.New("indique el ID de la plantilla con -id o el fragmento con -fragment")
+	}
+	return nil
+}
+
+// String describe el objetivo en los mensajes ("la plantilla PC001" o "el fragmento pie").
+func (t target) String() string {
+	if *t.fragment != "" {
+		return "el fragmento " + *t.fragment
+	}
+	return "la plantilla " + *t.id
+}
+
+// restored describe el objetivo restaurado a una versión anterior.
+func (t target) restored() string {
+	if *t.fragment != "" {
+		return fmt.Sprintf("Fragmento %s restaurado", *t.fragment)
+	}
+	return fmt.Sprintf("Plantilla %s restaurada", *t.id)
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	sess, err := connection.NewSession(cliMessageID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error al crear la sesión de AWS:", err)
+		os.Exit(1)
+	}
+
+	dbManager := connection.NewDBManager(connection.NewSecretService(sess), logger.Default.LogMode(logger.Warn))
+	if err := dbManager.InitDB(cliMessageID); err != nil {
+		fmt.Fprintln(os.Stderr, "error al conectar con la base de datos:", err)
+		os.Exit(1)
+	}
+
+	cache, err := newCacheNotifier()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error al crear el cliente de SQS:", err)
+		os.Exit(1)
+	}
+
+	store := repositories{
+		repository.NewVersionRepository(dbManager.GetDB()),
+		repository.NewFragmentoRepository(dbManager.GetDB()),
+	}
+	err = run(os.Args[1:], store, cache, os.Stdout)
+	dbManager.CloseDB(cliMessageID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run ejecuta el subcomando indicado en args. cache puede ser nil si no hay cola configurada.
+func run(args []string, store versionStore, cache cacheNotifier, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("uso: templates versions | snapshot | rollback")
+	}
+
+	switch args[0] {
+	case "versions":
+		return runVersions(args[1:], store, out)
+	case "snapshot":
+		return runSnapshot(args[1:], store, cache, out)
+	case "rollback":
+		return runRollback(args[1:], store, cache, out)
+	default:
+		return fmt.Errorf("subcomando desconocido %q (use versions, snapshot o rollback)", args[0])
+	}
+}
+
+func runVersions(args []string, store versionStore, out io.Writer) error {
+	fs := flag.NewFlagSet("versions", flag.ContinueOnError)
+	fs.SetOutput(out)
+	t := targetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	// Cada fila es versión, activa, fecha de creación y asunto (o tipo, para los fragmentos)
+	var rows [][4]string
+	if *t.fragment != "" {
+		versiones, err := store.ListFragmentoVersions(*t.fragment, *t.locale)
+		if err != nil {
+			return fmt.Errorf("error al listar las versiones: %w", err)
+		}
+		for _, v := range versiones {
+			rows = append(rows, versionRow(v.Version, v.Activa, v.CreatedAt, v.Tipo))
+		}
+	} else {
+		versiones, err := store.ListVersions(*t.id, *t.locale)
+		if err != nil {
+			return fmt.Errorf("error al listar las versiones: %w", err)
+		}
+		for _, v := range versiones {
+			rows = append(rows, versionRow(v.Version, v.Activa, v.CreatedAt, v.Asunto))
+		}
+	}
+	if len(rows) == 0 {
+		fmt.Fprintf(out, "%s no tiene versiones registradas\n", capitalize(t.String()))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if *t.fragment != "" {
+		fmt.Fprintln(w, "VERSION\tACTIVA\tCREADA\tTIPO")
+	} else {
+		fmt.Fprintln(w, "VERSION\tACTIVA\tCREADA\tASUNTO")
+	}
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row[:], "\t"))
+	}
+	return w.Flush()
+}
+
+func versionRow(version int, activa bool, createdAt time.Time, detalle string) [4]string {
+	estado := "no"
+	if activa {
+		estado = "si"
+	}
+	return [4]string{strconv.Itoa(version), estado, createdAt.UTC().Format(time.RFC3339), detalle}
+}
+
+func runSnapshot(args []string, store versionStore, cache cacheNotifier, out io.Writer) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	fs.SetOutput(out)
+	t := targetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	var version int
+	if *t.fragment != "" {
+		snapshot, err := store.SnapshotFragmento(*t.fragment, *t.locale)
+		if err != nil {
+			return fmt.Errorf("error al registrar la versión: %w", err)
+		}
+		version = snapshot.Version
+	} else {
+		snapshot, err := store.SnapshotPlantilla(*t.id, *t.locale)
+		if err != nil {
+			return fmt.Errorf("error al registrar la versión: %w", err)
+		}
+		version = snapshot.Version
+	}
+	logs.LogInfo(fmt.Sprintf("Versión %d registrada para %s", version, t), cliMessageID)
+	fmt.Fprintf(out, "Versión %d registrada y activa para %s\n", version, t)
+	t.invalidate(cache, out)
+	return nil
+}
+
+func runRollback(args []string, store versionStore, cache cacheNotifier, out io.Writer) error {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	fs.SetOutput(out)
+	t := targetFlags(fs)
+	version := fs.Int("version", 0, "versión a restaurar")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	if *version <= 0 {
+		return errors.New("indique la versión a restaurar con -version")
+	}
+
+	var err error
+	if *t.fragment != "" {
+		err = store.RollbackFragmento(*t.fragment, *t.locale, *version)
+	} else {
+		err = store.RollbackPlantilla(*t.id, *t.locale, *version)
+	}
+	if err != nil {
+		return fmt.Errorf("error al restaurar la versión: %w", err)
+	}
+	logs.LogInfo(fmt.Sprintf("%s a la versión %d", t.restored(), *version), cliMessageID)
+	fmt.Fprintf(out, "%s a la versión %d\n", t.restored(), *version)
+	t.invalidate(cache, out)
+	return nil
+}
+
+// invalidate solicita la invalidación de la caché de la plantilla. Los fragmentos no se guardan en la caché.
+func (t target) invalidate(cache cacheNotifier, out io.Writer) {
+	if *t.fragment == "" {
+		invalidateCache(cache, *t.id, out)
+	}
+}
+
+// capitalize pone en mayúscula la primera letra de la descripción del objetivo.
+func capitalize(text string) string {
+	return strings.ToUpper(text[:1]) + text[1:]
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore implementa versionStore en memoria.
+type memoryStore struct {
+	versiones  []models.PlantillaVersion
+	fragmentos []models.FragmentoVersion
+	err        error
+}
+
+func (m *memoryStore) ListVersions(string, string) ([]models.PlantillaVersion, error) {
+	return m.versiones, m.err
+}
+
+func (m *memoryStore) SnapshotPlantilla(idPlantilla, idioma string) (*models.PlantillaVersion, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	version := models.PlantillaVersion{
+		IDPlantilla: idPlantilla, Idioma: idioma, Version: len(m.versiones) + 1, Asunto: "Asunto",
+	}
+	m.activate(0)
+	version.Activa = true
+	m.versiones = append(m.versiones, version)
+	return &version, nil
+}
+
+func (m *memoryStore) RollbackPlantilla(_, _ string, version int) error {
+	if version > len(m.versiones) {
+		return errors.New("la versión no existe")
+	}
+	m.activate(version)
+	return m.err
+}
+
+func (m *memoryStore) ListFragmentoVersions(string, string) ([]models.FragmentoVersion, error) {
+	return m.fragmentos, m.err
+}
+
+func (m *memoryStore) SnapshotFragmento(nombre, idioma string) (*models.FragmentoVersion, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	for i := range m.fragmentos {
+		m.fragmentos[i].Activa = false
+	}
+	version := models.FragmentoVersion{
+		Nombre: nombre, Idioma: idioma, Version: len(m.fragmentos) + 1, Tipo: models.TipoFragmentoParcial, Activa: true,
+	}
+	m.fragmentos = append(m.fragmentos, version)
+	return &version, nil
+}
+
+func (m *memoryStore) RollbackFragmento(_, _ string, version int) error {
+	if version > len(m.fragmentos) {
+		return errors.New("la versión no existe")
+	}
+	for i := range m.fragmentos {
+		m.fragmentos[i].Activa = m.fragmentos[i].Version == version
+	}
+	return m.err
+}
+
+func (m *memoryStore) activate(version int) {
+	for i := range m.versiones {
+		m.versiones[i].Activa = m.versiones[i].Version == version
+	}
+}
+
+// memoryCache registra las invalidaciones solicitadas.
+type memoryCache struct {
+	invalidated []string
+	err         error
+}
+
+func (m *memoryCache) InvalidateCache(idPlantilla string) error {
+	m.invalidated = append(m.invalidated, idPlantilla)
+	return m.err
+}
+
+func TestRunSnapshotListRollback(t *testing.T) {
+	store := &memoryStore{}
+	cache := &memoryCache{}
+	var out bytes.Buffer
+
+	assert.NoError(t, run([]string{"versions", "-id", "PC001"}, store, cache, &out))
+	assert.Contains(t, out.String(), "La plantilla PC001 no tiene versiones registradas")
+
+	out.Reset()
+	assert.NoError(t, run([]string{"snapshot", "-id", "PC001"}, store, cache, &out))
+	assert.NoError(t, run([]string{"snapshot", "-id", "PC001"}, store, cache, &out))
+	assert.Contains(t, out.String(), "Versión 2 registrada y activa para la plantilla PC001")
+
+	out.Reset()
+	assert.NoError(t, run([]string{"rollback", "-id", "PC001", "-version", "1"}, store, cache, &out))
+	assert.Equal(t, "Plantilla PC001 restaurada a la versión 1\n"+
+		"Invalidación de la caché solicitada para la plantilla PC001\n", out.String())
+	assert.Equal(t, []string{"PC001", "PC001", "PC001"}, cache.invalidated)
+
+	out.Reset()
+	store.versiones[0].CreatedAt = time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC)
+	assert.NoError(t, run([]string{"versions", "-id", "PC001"}, store, cache, &out))
+	assert.Contains(t, out.String(), "1        si      2024-10-07T09:00:00Z  Asunto")
+	assert.Contains(t, out.String(), "2        no")
+}
+
+func TestRunFragmentVersions(t *testing.T) {
+	store := &memoryStore{}
+	var out bytes.Buffer
+
+	assert.NoError(t, run([]string{"versions", "-fragment", "pie"}, store, nil, &out))
+	assert.Contains(t, out.String(), "El fragmento pie no tiene versiones registradas")
+
+	out.Reset()
+	assert.NoError(t, run([]string{"snapshot", "-fragment", "pie", "-locale", "en"}, store, nil, &out))
+	assert.NoError(t, run([]string{"snapshot", "-fragment", "pie", "-locale", "en"}, store, nil, &out))
+	assert.Contains(t, out.String(), "Versión 2 registrada y activa para el fragmento pie")
+
+	out.Reset()
+	assert.NoError(t, run([]string{"rollback", "-fragment", "pie", "-version", "1"}, store, nil, &out))
+	assert.Equal(t, "Fragmento pie restaurado a la versión 1\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, run([]string{"versions", "-fragment", "pie"}, store, nil, &out))
+	assert.Contains(t, out.String(), "TIPO")
+	assert.Contains(t, out.String(), "partial")
+	assert.Empty(t, store.versiones)
+}
+
+func TestRunErrors(t *testing.T) {
+	store := &memoryStore{}
+	var out bytes.Buffer
+
+	assert.Error(t, run(nil, store, nil, &out))
+	assert.ErrorContains(t, run([]string{"publish"}, store, nil, &out), "subcomando desconocido")
+	assert.ErrorContains(t, run([]string{"snapshot"}, store, nil, &out), "-id")
+	assert.ErrorContains(t, run([]string{"snapshot", "-id", "PC001", "-fragment", "pie"}, store, nil, &out), "no ambos")
+	assert.ErrorContains(t, run([]string{"rollback", "-id", "PC001"}, store, nil, &out), "-version")
+	assert.ErrorContains(t, run([]string{"rollback", "-id", "PC001", "-version", "4"}, store, nil, &out),
+		"error al restaurar la versión")
+
+	store.err = errors.New("db caída")
+	assert.ErrorContains(t, run([]string{"snapshot", "-id", "PC001"}, store, nil, &out), "db caída")
+}
+
+func TestRunReportsCacheInvalidation(t *testing.T) {
+	store := &memoryStore{}
+	var out bytes.Buffer
+
+	// Sin cola configurada se informa que el cambio espera al TTL
+	assert.NoError(t, run([]string{"snapshot", "-id", "PC001"}, store, nil, &out))
+	assert.Contains(t, out.String(), "SQS_QUEUE_URL no está configurada")
+
+	// Un fallo al encolar no revierte la versión registrada
+	out.Reset()
+	cache := &memoryCache{err: errors.New("cola inexistente")}
+	assert.NoError(t, run([]string{"rollback", "-id", "PC001", "-version", "1"}, store, cache, &out))
+	assert.Contains(t, out.String(), "No se pudo solicitar la invalidación de la caché (cola inexistente)")
+	assert.Equal(t, []string{"PC001"}, cache.invalidated)
+
+	// Los fragmentos no se guardan en la caché
+	out.Reset()
+	cache = &memoryCache{}
+	assert.NoError(t, run([]string{"snapshot", "-fragment", "pie"}, store, cache, &out))
+	assert.Empty(t, cache.invalidated)
+	assert.NotContains(t, out.String(), "caché")
+}
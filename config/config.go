@@ -48,20 +48,17 @@ func (cm *Manager) InitConfig(messageID string) {
 		)
 	}
 
-	// Definir las variables clave que deben estar presentes en el entorno
+	// Definir las variables clave que deben estar presentes en el entorno. Las del proveedor SMTP (SECRETS_SMTP,
+	// SMTP_SERVER, SMTP_PORT) solo se exigen al crear ese proveedor, ya que EMAIL_PROVIDERS puede no incluirlo
 	requiredEnvVars := []string{
 		"APP_ENV",
 		"SERVICE_ENV",
 		"SECRETS_DB",
-		"SECRETS_SMTP",
 		"DB_HOST",
 		"DB_PORT",
 		"DB_NAME",
 		"DB_SCHEMA",
-		"SMTP_SERVER",
-		"SMTP_PORT",
 		"SQS_QUEUE_URL",
-		"SMTP_TIMEOUT",
 		"AWS_REGION",
 	}
 
@@ -19,6 +19,7 @@ import (
 	"gorm.io/gorm"
 	"os"
 	"testing"
+	"time"
 )
 
 /*
@@ -115,8 +116,9 @@ type MockEmailService struct {
 	mock.Mock
 }
 
-func (m *MockEmailService) SendEmail(remitente, destinatarios, asunto, cuerpo string, messageID string) error {
-	args := m.Called(remitente, destinatarios, asunto, cuerpo, messageID)
+func (m *MockEmailService) SendEmail(
+	ctx context.Context, remitente, destinatarios, asunto, cuerpo string, messageID string) error {
+	args := m.Called(ctx, remitente, destinatarios, asunto, cuerpo, messageID)
 	return args.Error(0)
 }
 
@@ -183,6 +185,17 @@ func (m *MockUtilsInterface) SendMessageToQueue(
 	return args.Error(0)
 }
 
+func (m *MockUtilsInterface) SendMessageToQueueWithDelay(
+	ctx context.Context,
+	client awsinternal.SQSAPI,
+	queueURL string,
+	messageBody string,
+	messageID string,
+	delay time.Duration) error {
+	args := m.Called(ctx, client, queueURL, messageBody, messageID, delay)
+	return args.Error(0)
+}
+
 /*
 ======================================================================================================
 ============================================== MockSQSHandler ========================================
@@ -211,6 +224,11 @@ func (m *MockPlantillaRepository) CheckPlantillaExists(idPlantilla string) (bool
 	return args.Bool(0), args.Get(1).(*models.Plantilla), args.Error(2)
 }
 
+func (m *MockPlantillaRepository) FindPlantillaIdioma(idPlantilla, idioma string) (bool, *models.Plantilla, error) {
+	args := m.Called(idPlantilla, idioma)
+	return args.Bool(0), args.Get(1).(*models.Plantilla), args.Error(2)
+}
+
 const (
 	smtpServer      = "smtp.test.com"
 	sqsQueue        = "http://sqs-url"
@@ -331,6 +349,29 @@ func TestInitConfigLoadsEnvVariables(t *testing.T) {
 	)
 }
 
+func TestInitConfigDoesNotRequireSMTPVariables(t *testing.T) {
+	viper.Reset()
+	viper.SetConfigFile(".env")
+	mockLogger := new(MockLogger)
+	mockLogger.On("LogDebug", mock.AnythingOfType("string"), mock.Anything).Return()
+	manager := NewConfigManager(mockLogger)
+	manager.FatalfFn = func(format string, args ...interface{}) {
+		panic(fmt.Sprintf(format, args...))
+	}
+
+	// Con EMAIL_PROVIDERS=ses u outbox no hay configuración SMTP
+	for _, key := range []string{"APP_ENV", "SERVICE_ENV", "SECRETS_DB", "DB_HOST", "DB_PORT", "DB_NAME", "DB_SCHEMA",
+		"SQS_QUEUE_URL", "AWS_REGION"} {
+		viper.Set(key, "valor")
+	}
+	viper.Set("EMAIL_PROVIDERS", "ses")
+
+	assert.NotPanics(t, func() {
+		manager.InitConfig("")
+	})
+	mockLogger.AssertNotCalled(t, "LogError", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestInitConfigEnvFileLoadedSuccessfully(t *testing.T) {
 	// Simular la carga del archivo .env
 	viper.Reset()
@@ -409,6 +450,28 @@ func TestInitApplicationDBInitError(t *testing.T) {
 	assert.EqualError(t, err, "db error")
 }
 
+func TestInitApplicationOutboxDoesNotRequireSMTPSecret(t *testing.T) {
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("SECRETS_DB", "mysecretdb")
+	t.Setenv("SECRETS_SMTP", "")
+	t.Setenv("EMAIL_PROVIDERS", "outbox")
+
+	mockDBManager := new(MockDBManager)
+	mockDBManager.On("InitDB", mock.Anything).Return(errors.New("db error"))
+
+	// Solo se consulta el secreto de la base de datos
+	mockSecretService := new(MockSecretService)
+	mockSecretService.On("GetSecret", "mysecretdb", "messageID").
+		Return(&connection.SecretData{Username: "user", Password: "password"}, nil)
+
+	appContext, err := InitApplication("messageID", mockSecretService, mockDBManager)
+
+	// La inicialización llega hasta la base de datos sin exigir SECRETS_SMTP
+	assert.EqualError(t, err, "db error")
+	assert.Nil(t, appContext)
+	mockSecretService.AssertNumberOfCalls(t, "GetSecret", 1)
+}
+
 func TestCleanupApplicationNoDBManager(t *testing.T) {
 	// Ejecutar CleanupApplication sin DBManager
 	CleanupApplication(nil, "messageID")
@@ -845,3 +908,25 @@ func TestInitializeSQSClientWithLocalstackEndpoint(t *testing.T) {
 	// Verificar que la URL del endpoint se resolvió correctamente
 	assert.Contains(t, client.QueueURL, sqsEndpoint, "La URL del endpoint debería ser la de LocalStack")
 }
+
+func TestInitNotificationHandler(t *testing.T) {
+	mockDBManager := new(MockDBManager)
+	mockDBManager.On("InitDB", "testMessageID").Return(nil)
+	mockDBManager.On("GetDB").Return(&gorm.DB{})
+
+	notificationHandler, err := InitNotificationHandler("testMessageID", mockDBManager)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, notificationHandler)
+	mockDBManager.AssertExpectations(t)
+}
+
+func TestInitNotificationHandlerDBInitError(t *testing.T) {
+	mockDBManager := new(MockDBManager)
+	mockDBManager.On("InitDB", "testMessageID").Return(errors.New("sin conexión"))
+
+	notificationHandler, err := InitNotificationHandler("testMessageID", mockDBManager)
+
+	assert.Error(t, err)
+	assert.Nil(t, notificationHandler)
+}
@@ -49,9 +49,12 @@ func InitApplication(
 		return nil, err
 	}
 
-	_, err = getSecret(secretService, "SECRETS_SMTP", messageID)
-	if err != nil {
-		return nil, err
+	// Las credenciales SMTP solo son necesarias si se utiliza el proveedor smtp (no, por ejemplo, con el outbox)
+	if usesProvider(email.ProviderSMTP) {
+		_, err = getSecret(secretService, "SECRETS_SMTP", messageID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Inicializar el Logger
@@ -67,18 +70,47 @@ func InitApplication(
 
 	logDatabaseConnectionEstablished(messageID)
 
-	// Inicializar el repositorio GORM con la conexión a la base de datos
-	repo := repository.NewPlantillaRepository(dbManager.GetDB())
+	// Inicializar el repositorio GORM con la conexión a la base de datos: las plantillas se sirven desde su versión
+	// activa y, si está habilitada, desde la caché de plantillas
+	versionRepo := repository.NewVersionRepository(dbManager.GetDB())
+	plantillaRepo := repository.NewVersionedPlantillaRepository(
+		repository.NewPlantillaRepository(dbManager.GetDB()), versionRepo)
+	var repo service.PlantillaRepository = plantillaRepo
+	var serviceOptions []service.PlantillaServiceOption
+	cacheTTL, cacheNegativeTTL, err := repository.CacheTTLFromEnv()
+	if err != nil {
+		logs.LogError("Error en la configuración de la caché de plantillas", err, messageID)
+		return nil, err
+	}
+	if cacheTTL > 0 || cacheNegativeTTL > 0 {
+		cache := repository.NewCachedPlantillaRepository(plantillaRepo, cacheTTL, cacheNegativeTTL)
+		repo = cache
+		serviceOptions = append(serviceOptions, service.WithTemplateCache(cache))
+	}
 
-	// Crear una instancia del servicio de correo electrónico utilizando SMTP
-	emailService, smtpErr := email.NewSMTPEmailService(secretService, messageID)
-	if smtpErr != nil {
-		logs.LogError("Error inicializando el servicio SMTP", smtpErr, messageID)
-		return nil, smtpErr
+	// Crear el servicio de correo con la cadena de proveedores configurada (SMTP, SMTP secundario, SES)
+	envioRepo := repository.NewEnvioRepository(dbManager.GetDB())
+	emailService, emailErr := email.NewEmailServiceFromEnv(
+		secretService,
+		envioRepo,
+		messageID,
+		email.WithImageSource(repository.NewImagenRepository(dbManager.GetDB())),
+		email.WithCertificateSource(repository.NewCertificadoRepository(dbManager.GetDB())),
+	)
+	if emailErr != nil {
+		logs.LogError("Error inicializando el servicio de correo", emailErr, messageID)
+		return nil, emailErr
 	}
 
 	// Crear una instancia del servicio PlantillaService
-	plantillaService := service.NewPlantillaService(repo, emailService)
+	serviceOptions = append(serviceOptions,
+		service.WithSuppressionList(repository.NewSupresionRepository(dbManager.GetDB())),
+		service.WithSendRecorder(envioRepo),
+		service.WithParameterSchema(repository.NewParametroRepository(dbManager.GetDB())),
+		service.WithVersionHistory(versionRepo),
+		service.WithFragments(repository.NewFragmentoRepository(dbManager.GetDB())),
+	)
+	plantillaService := service.NewPlantillaService(repo, emailService, serviceOptions...)
 
 	// Inicializar el cliente SQS
 	sqsClient, err := initializeSQSClient(messageID)
@@ -107,6 +139,34 @@ func InitApplication(
 	}, nil
 }
 
+// InitNotificationHandler inicializa el manejador de notificaciones de SES, que solo requiere la base de datos.
+func InitNotificationHandler(
+	messageID string,
+	dbManager connection.DBManagerInterface) (*handler.SESNotificationHandler, error) {
+	if err := dbManager.InitDB(messageID); err != nil {
+		logs.LogError("Error inicializando la base de datos", err, messageID)
+		return nil, err
+	}
+	logDatabaseConnectionEstablished(messageID)
+
+	return handler.NewSESNotificationHandler(
+		repository.NewSupresionRepository(dbManager.GetDB()),
+		repository.NewEventoRepository(dbManager.GetDB()),
+		repository.NewEnvioRepository(dbManager.GetDB()),
+		&logs.LoggerAdapter{},
+	), nil
+}
+
+// usesProvider indica si el proveedor de correo está incluido en EMAIL_PROVIDERS.
+func usesProvider(name string) bool {
+	for _, provider := range email.ProvidersFromEnv() {
+		if provider == name {
+			return true
+		}
+	}
+	return false
+}
+
 // getSecret obtiene un secreto validando que esté configurado en las variables de entorno
 func getSecret(
 	secretService connection.SecretService,
@@ -26,6 +26,21 @@ type SecretServiceImpl struct {
 type SecretData struct {
 	Username string `json:"USERNAME"`
 	Password string `json:"PASSWORD"`
+
+	// Credenciales OAuth2 (client_credentials) para la autenticación SMTP XOAUTH2
+	ClientID     string `json:"CLIENT_ID,omitempty"`
+	ClientSecret string `json:"CLIENT_SECRET,omitempty"`
+	TokenURL     string `json:"TOKEN_URL,omitempty"`
+	Scope        string `json:"SCOPE,omitempty"`
+
+	// Configuración de firma DKIM de un dominio remitente
+	DKIMSelector   string `json:"DKIM_SELECTOR,omitempty"`
+	DKIMDomain     string `json:"DKIM_DOMAIN,omitempty"`
+	DKIMPrivateKey string `json:"DKIM_PRIVATE_KEY,omitempty"`
+
+	// Certificado (PEM, seguido opcionalmente de la cadena) y clave privada para la firma S/MIME
+	SMIMECertificate string `json:"SMIME_CERTIFICATE,omitempty"`
+	SMIMEPrivateKey  string `json:"SMIME_PRIVATE_KEY,omitempty"`
 }
 
 // NewSecretService crea una nueva instancia de SecretService
@@ -0,0 +1,166 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"net/mail"
+	"strings"
+)
+
+// ErrNoRecipients se devuelve cuando la lista de destinatarios está vacía.
+var ErrNoRecipients = errors.New("error: no se especificaron destinatarios")
+
+// AddressValidationError lista las direcciones de correo que no pudieron interpretarse.
+type AddressValidationError struct {
+	Field   string
+	Invalid []string
+}
+
+func (e *AddressValidationError) Error() string {
+	return fmt.Sprintf("error: direcciones de correo inválidas en %s: %s", e.Field, strings.Join(e.Invalid, ", "))
+}
+
+// ParseAddressList interpreta una lista de direcciones con la semántica de net/mail (admite nombres
+// visibles). Acepta ",", ";" y saltos de línea como separadores; las direcciones se normalizan y se
+// eliminan duplicados. Las entradas inválidas se reportan como un error permanente.
+func ParseAddressList(raw string) ([]*mail.Address, error) {
+	return parseAddressList(raw, "destinatarios")
+}
+
+// ParseSender interpreta y valida la dirección del remitente (sobre SMTP y encabezado From).
+func ParseSender(raw string) (*mail.Address, error) {
+	addresses, err := parseAddressList(raw, "remitente")
+	if err != nil {
+		if errors.Is(err, ErrNoRecipients) {
+			return nil, models.NewPermanentError(errors.New("error: no se especificó el remitente"))
+		}
+		return nil, err
+	}
+	if len(addresses) != 1 {
+		return nil, models.NewPermanentError(&AddressValidationError{Field: "remitente", Invalid: []string{raw}})
+	}
+	return addresses[0], nil
+}
+
+func parseAddressList(raw, field string) ([]*mail.Address, error) {
+	var addresses []*mail.Address
+	var invalid []string
+	seen := map[string]bool{}
+
+	for _, entry := range splitAddressList(raw) {
+		address, err := parseAddress(entry)
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		if seen[address.Address] {
+			continue
+		}
+		seen[address.Address] = true
+		addresses = append(addresses, address)
+	}
+
+	if len(invalid) > 0 {
+		return nil, models.NewPermanentError(&AddressValidationError{Field: field, Invalid: invalid})
+	}
+	if len(addresses) == 0 {
+		return nil, models.NewPermanentError(ErrNoRecipients)
+	}
+	return addresses, nil
+}
+
+// splitAddressList separa la lista respetando comillas y corchetes angulares, de modo que
+// un nombre visible como "Pérez, Juan" no se divida.
+func splitAddressList(raw string) []string {
+	var entries []string
+	var current strings.Builder
+	inQuotes, inAngle := false, false
+
+	flush := func() {
+		if entry := strings.TrimSpace(current.String()); entry != "" {
+			entries = append(entries, entry)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '\\' && inQuotes && i+1 < len(raw):
+			current.WriteByte(c)
+			i++
+			current.WriteByte(raw[i])
+			continue
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '<' && !inQuotes:
+			inAngle = true
+		case c == '>' && !inQuotes:
+			inAngle = false
+		case (c == ',' || c == ';' || c == '\n' || c == '\r') && !inQuotes && !inAngle:
+			flush()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush()
+
+	return entries
+}
+
+// parseAddress interpreta y normaliza una dirección: dominio en minúsculas y validación del dominio.
+func parseAddress(entry string) (*mail.Address, error) {
+	address, err := mail.ParseAddress(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	at := strings.LastIndex(address.Address, "@")
+	if at <= 0 {
+		return nil, fmt.Errorf("dirección sin dominio: %s", entry)
+	}
+	local, domain := address.Address[:at], strings.ToLower(address.Address[at+1:])
+	if !isValidDomain(domain) {
+		return nil, fmt.Errorf("dominio inválido: %s", domain)
+	}
+
+	return &mail.Address{Name: strings.TrimSpace(address.Name), Address: local + "@" + domain}, nil
+}
+
+// isValidDomain valida un nombre de dominio con al menos dos etiquetas.
+func isValidDomain(domain string) bool {
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 || len(domain) > 253 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r > 127) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addressStrings devuelve las direcciones sin nombre visible (para el sobre SMTP).
+func addressStrings(addresses []*mail.Address) []string {
+	out := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		out = append(out, address.Address)
+	}
+	return out
+}
+
+// formatAddressList formatea las direcciones para un encabezado (codificando nombres visibles).
+func formatAddressList(addresses []*mail.Address) string {
+	out := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		out = append(out, address.String())
+	}
+	return strings.Join(out, ", ")
+}
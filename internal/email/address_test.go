@@ -0,0 +1,63 @@
+package email
+
+import (
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddressListSeparatorsAndDisplayNames(t *testing.T) {
+	addresses, err := ParseAddressList(
+		" ana@Test.COM ; \"Pérez, Juan\" <juan.perez@test.com>,\nOperaciones <operaciones@test.com>\r\n")
+
+	assert.NoError(t, err)
+	assert.Len(t, addresses, 3)
+	assert.Equal(t, "ana@test.com", addresses[0].Address)
+	assert.Equal(t, "Pérez, Juan", addresses[1].Name)
+	assert.Equal(t, "juan.perez@test.com", addresses[1].Address)
+	assert.Equal(t, "Operaciones", addresses[2].Name)
+}
+
+func TestParseAddressListDeduplicates(t *testing.T) {
+	addresses, err := ParseAddressList("ana@test.com, Ana <ana@TEST.com>,, ana@test.com")
+
+	assert.NoError(t, err)
+	assert.Len(t, addresses, 1)
+	assert.Equal(t, "ana@test.com", addresses[0].Address)
+}
+
+func TestParseAddressListInvalidEntries(t *testing.T) {
+	_, err := ParseAddressList("ana@test.com, juan@, sin-arroba, pedro@localhost")
+
+	assert.Error(t, err)
+	assert.True(t, models.IsPermanentError(err))
+
+	var validationErr *AddressValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{"juan@", "sin-arroba", "pedro@localhost"}, validationErr.Invalid)
+	assert.Contains(t, err.Error(), "juan@, sin-arroba, pedro@localhost")
+}
+
+func TestParseAddressListEmpty(t *testing.T) {
+	_, err := ParseAddressList(" ; , \n")
+
+	assert.ErrorIs(t, err, ErrNoRecipients)
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestParseSender(t *testing.T) {
+	sender, err := ParseSender("GMF <Notificaciones@Bancolombia.com.co>")
+	assert.NoError(t, err)
+	assert.Equal(t, "Notificaciones@bancolombia.com.co", sender.Address)
+	assert.Equal(t, "GMF", sender.Name)
+
+	_, err = ParseSender("a@test.com, b@test.com")
+	assert.Error(t, err)
+	assert.True(t, models.IsPermanentError(err))
+
+	_, err = ParseSender("")
+	assert.Error(t, err)
+	assert.True(t, models.IsPermanentError(err))
+}
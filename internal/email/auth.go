@@ -0,0 +1,257 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mecanismos de autenticación SMTP soportados (variable de entorno SMTP_AUTH).
+const (
+	AuthNone    = "none"
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+	AuthXOAUTH2 = "xoauth2"
+)
+
+// tokenExpiryMargin es el margen con el que se renueva un token antes de su expiración.
+const tokenExpiryMargin = 60 * time.Second
+
+// normalizeAuthMechanism valida el mecanismo configurado. Si no se configura ninguno se usa PLAIN.
+func normalizeAuthMechanism(mechanism string) (string, error) {
+	mechanism = strings.ToLower(strings.TrimSpace(mechanism))
+	switch mechanism {
+	case "":
+		return AuthPlain, nil
+	case AuthNone, AuthPlain, AuthLogin, AuthCRAMMD5, AuthXOAUTH2:
+		return mechanism, nil
+	default:
+		return "", fmt.Errorf("mecanismo de autenticación SMTP no soportado: %s", mechanism)
+	}
+}
+
+// buildAuth construye el smtp.Auth correspondiente al mecanismo configurado.
+// Para el mecanismo "none" devuelve nil, con lo que no se envía el comando AUTH.
+func (s *SMTPEmailService) buildAuth(ctx context.Context, messageID string) (smtp.Auth, error) {
+	mechanism, err := normalizeAuthMechanism(s.authMechanism)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mechanism {
+	case AuthNone:
+		return nil, nil
+	case AuthLogin:
+		return &loginAuth{username: s.username, password: s.password, host: s.server}, nil
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(s.username, s.password), nil
+	case AuthXOAUTH2:
+		if s.tokenSource == nil {
+			return nil, errors.New("error: no hay fuente de tokens configurada para XOAUTH2")
+		}
+		token, err := s.tokenSource.Token(ctx, messageID)
+		if err != nil {
+			return nil, fmt.Errorf("error obteniendo el token XOAUTH2: %w", err)
+		}
+		return &xoauth2Auth{username: s.username, token: token, source: s.tokenSource}, nil
+	default:
+		return smtp.PlainAuth("", s.username, s.password, s.server), nil
+	}
+}
+
+// hasCredentials indica si el servicio tiene las credenciales que exige el mecanismo configurado.
+func (s *SMTPEmailService) hasCredentials() bool {
+	mechanism, err := normalizeAuthMechanism(s.authMechanism)
+	if err != nil {
+		return false
+	}
+
+	switch mechanism {
+	case AuthNone:
+		return true
+	case AuthXOAUTH2:
+		return s.username != "" && s.tokenSource != nil
+	default:
+		return s.username != "" && s.password != ""
+	}
+}
+
+// isLocalhost indica si el servidor es local, caso en el que se permite autenticar sin TLS.
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// loginAuth implementa el mecanismo AUTH LOGIN, que net/smtp no incluye.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("conexión sin cifrar: AUTH LOGIN requiere TLS")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("nombre de host incorrecto")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("desafío AUTH LOGIN inesperado: %s", fromServer)
+	}
+}
+
+// xoauth2Auth implementa el mecanismo AUTH XOAUTH2 con un token de acceso OAuth2. Si el servidor rechaza el
+// token, se invalida en la fuente para que el siguiente envío solicite uno nuevo.
+type xoauth2Auth struct {
+	username string
+	token    string
+	source   tokenSource
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("conexión sin cifrar: AUTH XOAUTH2 requiere TLS")
+	}
+	resp := fmt.Sprintf("user=%s\x01auth=Bearer %s\x01\x01", a.username, a.token)
+	return "XOAUTH2", []byte(resp), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// El servidor envía un JSON con el detalle del error; se responde vacío para cerrar el intercambio.
+		if a.source != nil {
+			a.source.Invalidate(a.token)
+		}
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// tokenSource obtiene tokens de acceso OAuth2 para XOAUTH2.
+type tokenSource interface {
+	Token(ctx context.Context, messageID string) (string, error)
+	// Invalidate descarta el token indicado si sigue siendo el vigente.
+	Invalidate(token string)
+}
+
+// clientCredentialsTokenSource obtiene y renueva tokens con el flujo OAuth2 client_credentials.
+type clientCredentialsTokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scope        string
+	httpClient   *http.Client
+	now          func() time.Time
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// tokenResponse representa la respuesta del endpoint de tokens.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// newClientCredentialsTokenSource crea la fuente de tokens a partir de las credenciales del secreto SMTP.
+func newClientCredentialsTokenSource(
+	secretData *connection.SecretData, timeout time.Duration) (*clientCredentialsTokenSource, error) {
+	if secretData.TokenURL == "" || secretData.ClientID == "" || secretData.ClientSecret == "" {
+		return nil, errors.New("error: el secreto SMTP no contiene TOKEN_URL, CLIENT_ID y CLIENT_SECRET para XOAUTH2")
+	}
+	return &clientCredentialsTokenSource{
+		tokenURL:     secretData.TokenURL,
+		clientID:     secretData.ClientID,
+		clientSecret: secretData.ClientSecret,
+		scope:        secretData.Scope,
+		httpClient:   &http.Client{Timeout: timeout},
+		now:          time.Now,
+	}, nil
+}
+
+// Token devuelve el token vigente o solicita uno nuevo si expiró o está próximo a expirar.
+func (ts *clientCredentialsTokenSource) Token(ctx context.Context, messageID string) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.accessToken != "" && ts.now().Add(tokenExpiryMargin).Before(ts.expiresAt) {
+		return ts.accessToken, nil
+	}
+
+	logs.LogDebug("Solicitando un nuevo token de acceso para XOAUTH2", messageID)
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", ts.clientID)
+	form.Set("client_secret", ts.clientSecret)
+	if ts.scope != "" {
+		form.Set("scope", ts.scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creando la solicitud del token de acceso: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := ts.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error solicitando el token de acceso: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("el endpoint de tokens respondió con estado %d", resp.StatusCode)
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error deserializando la respuesta del endpoint de tokens: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("la respuesta del endpoint de tokens no contiene access_token")
+	}
+
+	ts.accessToken = token.AccessToken
+	ts.expiresAt = ts.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return ts.accessToken, nil
+}
+
+// Invalidate descarta el token en caché si coincide con el rechazado. Si entre tanto ya se obtuvo uno nuevo, se
+// conserva.
+func (ts *clientCredentialsTokenSource) Invalidate(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.accessToken == token {
+		ts.accessToken = ""
+		ts.expiresAt = time.Time{}
+	}
+}
@@ -0,0 +1,280 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gmf_message_processor/connection"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock de tokenSource que devuelve un token fijo.
+type mockTokenSource struct {
+	token       string
+	err         error
+	invalidated []string
+}
+
+func (m *mockTokenSource) Token(ctx context.Context, messageID string) (string, error) {
+	return m.token, m.err
+}
+
+func (m *mockTokenSource) Invalidate(token string) {
+	m.invalidated = append(m.invalidated, token)
+}
+
+func TestNormalizeAuthMechanism(t *testing.T) {
+	mechanism, err := normalizeAuthMechanism("")
+	assert.NoError(t, err)
+	assert.Equal(t, AuthPlain, mechanism)
+
+	mechanism, err = normalizeAuthMechanism(" XOAUTH2 ")
+	assert.NoError(t, err)
+	assert.Equal(t, AuthXOAUTH2, mechanism)
+
+	_, err = normalizeAuthMechanism("gssapi")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no soportado")
+}
+
+func TestBuildAuthByMechanism(t *testing.T) {
+	service := &SMTPEmailService{server: smtpServerTest, username: "user", password: "pass"}
+
+	service.authMechanism = AuthNone
+	auth, err := service.buildAuth(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+
+	service.authMechanism = AuthLogin
+	auth, err = service.buildAuth(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.IsType(t, &loginAuth{}, auth)
+
+	service.authMechanism = AuthCRAMMD5
+	auth, err = service.buildAuth(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	mechanism, _, err := auth.Start(&smtp.ServerInfo{Name: smtpServerTest})
+	assert.NoError(t, err)
+	assert.Equal(t, "CRAM-MD5", mechanism)
+
+	service.authMechanism = AuthXOAUTH2
+	source := &mockTokenSource{token: "token-123"}
+	service.tokenSource = source
+	auth, err = service.buildAuth(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, &xoauth2Auth{username: "user", token: "token-123", source: source}, auth)
+}
+
+func TestBuildAuthXOAUTH2TokenError(t *testing.T) {
+	service := &SMTPEmailService{
+		server:        smtpServerTest,
+		username:      "user",
+		authMechanism: AuthXOAUTH2,
+		tokenSource:   &mockTokenSource{err: errors.New("invalid_client")},
+	}
+
+	_, err := service.buildAuth(context.Background(), testMessageID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_client")
+}
+
+func TestLoginAuthExchange(t *testing.T) {
+	auth := &loginAuth{username: "user", password: "pass", host: smtpServerTest}
+
+	mechanism, initial, err := auth.Start(&smtp.ServerInfo{Name: smtpServerTest, TLS: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "LOGIN", mechanism)
+	assert.Nil(t, initial)
+
+	resp, err := auth.Next([]byte("Username:"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", string(resp))
+
+	resp, err = auth.Next([]byte("Password:"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, "pass", string(resp))
+
+	_, err = auth.Next([]byte("Otro:"), true)
+	assert.Error(t, err)
+}
+
+func TestLoginAuthRequiresTLS(t *testing.T) {
+	auth := &loginAuth{username: "user", password: "pass", host: smtpServerTest}
+
+	_, _, err := auth.Start(&smtp.ServerInfo{Name: smtpServerTest, TLS: false})
+	assert.Error(t, err)
+}
+
+func TestXOAUTH2AuthStart(t *testing.T) {
+	auth := &xoauth2Auth{username: "user@test.com", token: "abc"}
+
+	mechanism, initial, err := auth.Start(&smtp.ServerInfo{Name: smtpServerTest, TLS: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "XOAUTH2", mechanism)
+	assert.Equal(t, "user=user@test.com\x01auth=Bearer abc\x01\x01", string(initial))
+
+	resp, err := auth.Next([]byte(`{"status":"401"}`), true)
+	assert.NoError(t, err)
+	assert.Empty(t, resp)
+}
+
+func TestXOAUTH2AuthInvalidatesRejectedToken(t *testing.T) {
+	source := &mockTokenSource{token: "abc"}
+	auth := &xoauth2Auth{username: "user@test.com", token: "abc", source: source}
+
+	_, err := auth.Next(nil, false)
+	assert.NoError(t, err)
+	assert.Empty(t, source.invalidated)
+
+	_, err = auth.Next([]byte(`{"status":"401"}`), true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, source.invalidated)
+}
+
+func TestClientCredentialsTokenSourceCachesAndRefreshes(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		assert.Equal(t, "client-id", r.Form.Get("client_id"))
+		assert.Equal(t, "https://outlook.office365.com/.default", r.Form.Get("scope"))
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, calls)
+	}))
+	defer server.Close()
+
+	ts, err := newClientCredentialsTokenSource(&connection.SecretData{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		TokenURL:     server.URL,
+		Scope:        "https://outlook.office365.com/.default",
+	}, time.Second)
+	assert.NoError(t, err)
+
+	now := time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC)
+	ts.now = func() time.Time { return now }
+
+	token, err := ts.Token(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// El token sigue vigente: no se vuelve a solicitar
+	token, err = ts.Token(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, 1, calls)
+
+	// Dentro del margen de expiración se solicita uno nuevo
+	now = now.Add(59 * time.Minute)
+	token, err = ts.Token(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+	assert.Equal(t, 2, calls)
+}
+
+func TestClientCredentialsTokenSourceInvalidate(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, calls)
+	}))
+	defer server.Close()
+
+	ts, err := newClientCredentialsTokenSource(&connection.SecretData{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		TokenURL:     server.URL,
+	}, time.Second)
+	assert.NoError(t, err)
+
+	token, err := ts.Token(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// Un token distinto al vigente no descarta la caché
+	ts.Invalidate("token-0")
+	token, err = ts.Token(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// El token rechazado obliga a solicitar uno nuevo aunque no haya expirado
+	ts.Invalidate("token-1")
+	token, err = ts.Token(context.Background(), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+	assert.Equal(t, 2, calls)
+}
+
+func TestClientCredentialsTokenSourceErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	ts, err := newClientCredentialsTokenSource(&connection.SecretData{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		TokenURL:     server.URL,
+	}, time.Second)
+	assert.NoError(t, err)
+
+	_, err = ts.Token(context.Background(), testMessageID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestNewSMTPEmailServiceXOAUTH2MissingCredentials(t *testing.T) {
+	mockSecretService := new(MockSecretService)
+	mockSecretService.On("GetSecret", secretName, testMessageID).
+		Return(&connection.SecretData{Username: "user"}, nil)
+
+	t.Setenv("SECRETS_SMTP", secretName)
+	t.Setenv("SMTP_SERVER", smtpServerTest)
+	t.Setenv("SMTP_PORT", "587")
+	t.Setenv("SMTP_AUTH", "xoauth2")
+
+	service, err := NewSMTPEmailService(mockSecretService, testMessageID)
+	assert.Error(t, err)
+	assert.Nil(t, service)
+	assert.Contains(t, err.Error(), "CLIENT_ID")
+}
+
+func TestNewSMTPEmailServiceInvalidAuthMechanism(t *testing.T) {
+	mockSecretService := new(MockSecretService)
+	mockSecretService.On("GetSecret", secretName, testMessageID).
+		Return(&connection.SecretData{Username: "user", Password: "pass"}, nil)
+
+	t.Setenv("SECRETS_SMTP", secretName)
+	t.Setenv("SMTP_AUTH", "ntlm")
+
+	service, err := NewSMTPEmailService(mockSecretService, testMessageID)
+	assert.Error(t, err)
+	assert.Nil(t, service)
+}
+
+// Test que verifica que sin autenticación no se exigen credenciales y no se envía AUTH.
+func TestSMTPEmailServiceSendEmailWithoutAuth(t *testing.T) {
+	var usedAuth smtp.Auth = &loginAuth{}
+	service := &SMTPEmailService{
+		server:        smtpServerTest,
+		port:          "25",
+		authMechanism: AuthNone,
+		sendMail: func(_ context.Context, addr string, a smtp.Auth, from string, to []string,
+			msg []byte) ([]RecipientResult, error) {
+			usedAuth = a
+			return acceptAll(to), nil
+		},
+		timeout: 10 * time.Second,
+	}
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+	assert.NoError(t, err)
+	assert.Nil(t, usedAuth)
+}
@@ -0,0 +1,288 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// RecipientStatus es el resultado de la entrega para un destinatario.
+type RecipientStatus string
+
+const (
+	RecipientAccepted RecipientStatus = "accepted"
+	RecipientRejected RecipientStatus = "rejected"
+	RecipientDeferred RecipientStatus = "deferred"
+)
+
+// RecipientResult contiene el resultado de la entrega para un destinatario junto con la respuesta del servidor.
+type RecipientResult struct {
+	Address      string
+	Status       RecipientStatus
+	Code         int
+	EnhancedCode string
+	Message      string
+	// Action es la política aplicada a la respuesta de un destinatario no aceptado.
+	Action ReplyAction
+}
+
+// DeliveryError indica que al menos un destinatario no fue aceptado por el servidor.
+type DeliveryError struct {
+	Results []RecipientResult
+	// RetryAfter es el retraso sugerido para reintentar los destinatarios diferidos, o 0 para usar el habitual.
+	RetryAfter time.Duration
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf(
+		"error: entrega parcial del correo electrónico (aceptados: %d, rechazados: %d, diferidos: %d)",
+		len(e.recipients(RecipientAccepted)),
+		len(e.recipients(RecipientRejected)),
+		len(e.recipients(RecipientDeferred)),
+	)
+}
+
+// DeferredRecipients devuelve los destinatarios con error temporal, que deben reintentarse.
+func (e *DeliveryError) DeferredRecipients() []string {
+	return e.recipients(RecipientDeferred)
+}
+
+// RejectedRecipients devuelve los destinatarios rechazados de forma permanente.
+func (e *DeliveryError) RejectedRecipients() []string {
+	return e.recipients(RecipientRejected)
+}
+
+// RetryDelay devuelve el retraso con el que deben reintentarse los destinatarios diferidos.
+func (e *DeliveryError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+func (e *DeliveryError) recipients(status RecipientStatus) []string {
+	var out []string
+	for _, result := range e.Results {
+		if result.Status == status {
+			out = append(out, result.Address)
+		}
+	}
+	return out
+}
+
+// TransportError indica que el mensaje no llegó al proveedor por un fallo de conexión, un timeout o una respuesta
+// temporal del servidor. A diferencia de los errores de configuración local, justifica probar otro proveedor.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// newTransportError marca un error como fallo de transporte.
+func newTransportError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransportError{Err: err}
+}
+
+// IsTransportError indica si el error (o alguno de los errores que envuelve) es un fallo de transporte.
+func IsTransportError(err error) bool {
+	var transportErr *TransportError
+	return errors.As(err, &transportErr)
+}
+
+// newRecipientResult clasifica la respuesta del servidor para un destinatario según la clase del código. La
+// política de respuestas (ReplyPolicy) puede luego reclasificarlo.
+func newRecipientResult(address string, code int, message string) RecipientResult {
+	result := RecipientResult{Address: address, Code: code}
+	result.EnhancedCode, result.Message = splitEnhancedCode(message)
+
+	switch {
+	case code >= 200 && code < 300:
+		result.Status = RecipientAccepted
+	case code >= 400 && code < 500:
+		result.Status = RecipientDeferred
+	default:
+		result.Status = RecipientRejected
+	}
+	return result
+}
+
+// replyFromError extrae el código y el texto de una respuesta SMTP. Devuelve false si el error no
+// es una respuesta del servidor (por ejemplo, un error de conexión).
+func replyFromError(err error) (int, string, bool) {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code, protoErr.Msg, true
+	}
+	return 0, "", false
+}
+
+// sendMailPerRecipient realiza la conversación SMTP (como smtp.SendMail) pero registra el resultado de
+// RCPT TO para cada destinatario, de modo que un rechazo no impida la entrega al resto.
+func sendMailPerRecipient(
+	ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) ([]RecipientResult, error) {
+	return sendMailWithTLSConfig(ctx, nil, addr, a, from, to, msg)
+}
+
+// newSendMailFunc devuelve un smtpSendMailFunc que usa tlsConfig en STARTTLS, por ejemplo para confiar en el
+// certificado autofirmado de un servidor de pruebas.
+func newSendMailFunc(tlsConfig *tls.Config) smtpSendMailFunc {
+	return func(
+		ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) ([]RecipientResult, error) {
+		return sendMailWithTLSConfig(ctx, tlsConfig, addr, a, from, to, msg)
+	}
+}
+
+func sendMailWithTLSConfig(
+	ctx context.Context,
+	tlsConfig *tls.Config,
+	addr string,
+	a smtp.Auth,
+	from string,
+	to []string,
+	msg []byte) ([]RecipientResult, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialContext(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, replyError(StageConnect, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		config := &tls.Config{ServerName: host}
+		if tlsConfig != nil {
+			config = tlsConfig.Clone()
+			if config.ServerName == "" {
+				config.ServerName = host
+			}
+		}
+		if err = c.StartTLS(config); err != nil {
+			return nil, replyError(StageStartTLS, err)
+		}
+	}
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return nil, errors.New("smtp: el servidor no soporta AUTH")
+		}
+		if err = c.Auth(a); err != nil {
+			return nil, replyError(StageAuth, err)
+		}
+	}
+	if err = c.Mail(from); err != nil {
+		return nil, replyError(StageMail, err)
+	}
+
+	results := make([]RecipientResult, 0, len(to))
+	accepted := 0
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			code, message, ok := replyFromError(err)
+			if !ok {
+				return nil, err
+			}
+			results = append(results, newRecipientResult(rcpt, code, message))
+			continue
+		}
+		results = append(results, RecipientResult{Address: rcpt, Status: RecipientAccepted, Code: 250})
+		accepted++
+	}
+
+	// Sin destinatarios aceptados no se envía DATA
+	if accepted == 0 {
+		_ = c.Reset()
+		_ = c.Quit()
+		return results, nil
+	}
+
+	if err := writeData(c, msg); err != nil {
+		code, message, ok := replyFromError(err)
+		if !ok {
+			return nil, err
+		}
+		// El rechazo de DATA aplica a todos los destinatarios aceptados en RCPT
+		for i := range results {
+			if results[i].Status == RecipientAccepted {
+				results[i] = newRecipientResult(results[i].Address, code, message)
+			}
+		}
+		return results, nil
+	}
+
+	_ = c.Quit()
+	return results, nil
+}
+
+// dialContext abre la conexión respetando el contexto: el plazo del contexto se aplica a cada lectura y escritura,
+// y su cancelación cierra la conexión, lo que aborta el comando SMTP en curso (incluido DATA, de modo que un
+// envío cancelado no se entrega tarde).
+func dialContext(ctx context.Context, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &contextConn{Conn: conn, stop: closeOnDone(ctx, conn)}, nil
+}
+
+// closeOnDone cierra la conexión cuando se cancela el contexto. La función devuelta detiene la vigilancia.
+func closeOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// contextConn detiene la vigilancia del contexto al cerrar la conexión.
+type contextConn struct {
+	net.Conn
+	stop func()
+}
+
+func (c *contextConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// writeData envía el contenido del mensaje con el comando DATA.
+func writeData(c *smtp.Client, msg []byte) error {
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,194 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startScriptedSMTPServer inicia un servidor SMTP mínimo que responde a RCPT TO según rcptReplies
+// (por defecto "250 OK") y devuelve su dirección.
+func startScriptedSMTPServer(t *testing.T, rcptReplies map[string]string, dataReply string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error iniciando el servidor SMTP de prueba: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		write("220 localhost ESMTP")
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				write("250-localhost")
+				write("250 8BITMIME")
+			case strings.HasPrefix(command, "MAIL FROM"):
+				write("250 2.1.0 OK")
+			case strings.HasPrefix(command, "RCPT TO"):
+				address := strings.ToLower(strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+				if reply, ok := rcptReplies[address]; ok {
+					write(reply)
+				} else {
+					write("250 2.1.5 OK")
+				}
+			case command == "DATA":
+				write("354 Start mail input")
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+				}
+				write(dataReply)
+			case command == "RSET":
+				write("250 OK")
+			case command == "QUIT":
+				write("221 Bye")
+				return
+			default:
+				write("502 Command not implemented")
+			}
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestSendMailPerRecipientMixedResults(t *testing.T) {
+	addr := startScriptedSMTPServer(t, map[string]string{
+		"noexiste@test.com": "550 5.1.1 <noexiste@test.com>: Recipient address rejected",
+		"lleno@test.com":    "452 4.2.2 Mailbox full",
+	}, "250 2.0.0 Queued")
+
+	results, err := sendMailPerRecipient(
+		context.Background(), addr, nil, senderEmailTest,
+		[]string{"ok@test.com", "noexiste@test.com", "lleno@test.com"},
+		[]byte("Subject: x\r\n\r\nHola\r\n"),
+	)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, RecipientAccepted, results[0].Status)
+	assert.Equal(t, RecipientResult{
+		Address:      "noexiste@test.com",
+		Status:       RecipientRejected,
+		Code:         550,
+		EnhancedCode: "5.1.1",
+		Message:      "<noexiste@test.com>: Recipient address rejected",
+	}, results[1])
+	assert.Equal(t, RecipientDeferred, results[2].Status)
+	assert.Equal(t, 452, results[2].Code)
+	assert.Equal(t, "4.2.2", results[2].EnhancedCode)
+}
+
+func TestSendMailPerRecipientDataDeferred(t *testing.T) {
+	addr := startScriptedSMTPServer(t, nil, "451 4.3.0 Try again later")
+
+	results, err := sendMailPerRecipient(
+		context.Background(), addr, nil, senderEmailTest, []string{"a@test.com", "b@test.com"},
+		[]byte("Subject: x\r\n\r\nHola\r\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, RecipientDeferred, results[0].Status)
+	assert.Equal(t, RecipientDeferred, results[1].Status)
+	assert.Equal(t, 451, results[1].Code)
+}
+
+func TestSendMailPerRecipientAllRejectedSkipsData(t *testing.T) {
+	addr := startScriptedSMTPServer(t, map[string]string{
+		"a@test.com": "550 5.1.1 Unknown user",
+	}, "554 no debería llegar a DATA")
+
+	results, err := sendMailPerRecipient(
+		context.Background(), addr, nil, senderEmailTest, []string{"a@test.com"}, []byte("Subject: x\r\n\r\nHola\r\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, RecipientRejected, results[0].Status)
+}
+
+func TestDeliveryOutcomeWithDeferredRecipients(t *testing.T) {
+	err := deliveryOutcome([]RecipientResult{
+		{Address: "a@test.com", Status: RecipientAccepted, Code: 250},
+		{Address: "b@test.com", Status: RecipientRejected, Code: 550},
+		{Address: "c@test.com", Status: RecipientDeferred, Code: 452},
+	}, NewReplyPolicy(nil, maxDeferDelay), testMessageID)
+
+	var deliveryErr *DeliveryError
+	assert.True(t, errors.As(err, &deliveryErr))
+	assert.False(t, models.IsPermanentError(err))
+	assert.Equal(t, []string{"c@test.com"}, deliveryErr.DeferredRecipients())
+	assert.Equal(t, []string{"b@test.com"}, deliveryErr.RejectedRecipients())
+	assert.Contains(t, err.Error(), "aceptados: 1, rechazados: 1, diferidos: 1")
+}
+
+func TestDeliveryOutcomeOnlyRejectedIsPermanent(t *testing.T) {
+	err := deliveryOutcome([]RecipientResult{
+		{Address: "a@test.com", Status: RecipientAccepted, Code: 250},
+		{Address: "b@test.com", Status: RecipientRejected, Code: 550},
+	}, NewReplyPolicy(nil, maxDeferDelay), testMessageID)
+
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestDeliveryOutcomeAllAccepted(t *testing.T) {
+	err := deliveryOutcome(acceptAll([]string{"a@test.com"}), NewReplyPolicy(nil, maxDeferDelay), testMessageID)
+
+	assert.NoError(t, err)
+}
+
+// Test que verifica que el servicio SMTP devuelve los destinatarios diferidos.
+func TestSMTPEmailServiceSendEmailPartialDelivery(t *testing.T) {
+	service := &SMTPEmailService{
+		server:   smtpServerTest,
+		port:     "587",
+		username: "user",
+		password: "pass",
+		sendMail: func(_ context.Context, addr string, a smtp.Auth, from string, to []string,
+			msg []byte) ([]RecipientResult, error) {
+			return []RecipientResult{
+				{Address: to[0], Status: RecipientAccepted, Code: 250},
+				newRecipientResult(to[1], 421, "4.7.0 Try again later"),
+			}, nil
+		},
+		timeout: 10 * time.Second,
+	}
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com, b@test.com", testSubject, testBody,
+		testMessageID)
+
+	var deliveryErr *DeliveryError
+	assert.True(t, errors.As(err, &deliveryErr))
+	assert.Equal(t, []string{"b@test.com"}, deliveryErr.DeferredRecipients())
+}
+
+func TestIsTransportError(t *testing.T) {
+	err := fmt.Errorf("envío fallido: %w", newTransportError(errors.New("connection refused")))
+	assert.True(t, IsTransportError(err))
+	assert.EqualError(t, err, "envío fallido: connection refused")
+
+	assert.False(t, IsTransportError(errors.New("configuración incompleta")))
+	assert.Nil(t, newTransportError(nil))
+}
@@ -0,0 +1,294 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"os"
+	"strings"
+	"time"
+)
+
+// Algoritmos de firma DKIM soportados.
+const (
+	DKIMAlgorithmRSA     = "rsa-sha256"
+	DKIMAlgorithmEd25519 = "ed25519-sha256"
+)
+
+// dkimSignedHeaders son los encabezados que se incluyen en la firma cuando están presentes.
+var dkimSignedHeaders = []string{
+	"From", "To", "Cc", "Subject", "Date", "Message-ID",
+	"MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+}
+
+// dkimKey contiene la configuración de firma de un dominio remitente.
+type dkimKey struct {
+	domain    string
+	selector  string
+	algorithm string
+	signer    crypto.Signer
+}
+
+// DKIMSigner firma los mensajes con DKIM (RFC 6376) usando la clave configurada para el dominio del remitente.
+type DKIMSigner struct {
+	keys map[string]*dkimKey
+	now  func() time.Time
+}
+
+// NewDKIMSignerFromEnv carga las claves DKIM definidas en DKIM_SECRETS.
+// El formato es una lista "dominio=nombre-del-secreto" separada por comas. Si la variable no está
+// configurada devuelve nil, nil y los mensajes se envían sin firma DKIM.
+func NewDKIMSignerFromEnv(secretService connection.SecretService, messageID string) (*DKIMSigner, error) {
+	config := strings.TrimSpace(os.Getenv("DKIM_SECRETS"))
+	if config == "" {
+		return nil, nil
+	}
+
+	signer := &DKIMSigner{keys: map[string]*dkimKey{}, now: time.Now}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("entrada DKIM_SECRETS inválida: %s", entry)
+		}
+		senderDomain := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		secretData, err := secretService.GetSecret(strings.TrimSpace(parts[1]), messageID)
+		if err != nil {
+			logs.LogError(fmt.Sprintf("Error al obtener el secreto DKIM del dominio %s", senderDomain), err, messageID)
+			return nil, err
+		}
+
+		key, err := newDKIMKey(secretData, senderDomain)
+		if err != nil {
+			logs.LogError(fmt.Sprintf("Configuración DKIM inválida para el dominio %s", senderDomain), err, messageID)
+			return nil, err
+		}
+		signer.keys[senderDomain] = key
+	}
+
+	return signer, nil
+}
+
+// newDKIMKey construye la clave DKIM a partir del secreto. Si el secreto no define DKIM_DOMAIN
+// se firma con el dominio del remitente.
+func newDKIMKey(secretData *connection.SecretData, senderDomain string) (*dkimKey, error) {
+	if secretData.DKIMSelector == "" || secretData.DKIMPrivateKey == "" {
+		return nil, errors.New("el secreto DKIM debe contener DKIM_SELECTOR y DKIM_PRIVATE_KEY")
+	}
+
+	signer, algorithm, err := parseDKIMPrivateKey(secretData.DKIMPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(secretData.DKIMDomain))
+	if domain == "" {
+		domain = senderDomain
+	}
+
+	return &dkimKey{
+		domain:    domain,
+		selector:  secretData.DKIMSelector,
+		algorithm: algorithm,
+		signer:    signer,
+	}, nil
+}
+
+// parseDKIMPrivateKey interpreta una clave privada PEM (PKCS#1 o PKCS#8) RSA o Ed25519.
+func parseDKIMPrivateKey(pemKey string) (crypto.Signer, string, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, "", errors.New("la clave privada DKIM no está en formato PEM")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, "", fmt.Errorf("error interpretando la clave privada DKIM: %w", err)
+		}
+		return key, DKIMAlgorithmRSA, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("error interpretando la clave privada DKIM: %w", err)
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, DKIMAlgorithmRSA, nil
+	case ed25519.PrivateKey:
+		return k, DKIMAlgorithmEd25519, nil
+	default:
+		return nil, "", fmt.Errorf("tipo de clave DKIM no soportado: %T", key)
+	}
+}
+
+// Sign agrega el encabezado DKIM-Signature al mensaje si hay una clave para el dominio del remitente.
+func (d *DKIMSigner) Sign(raw []byte, msg *Message, messageID string) ([]byte, error) {
+	domain := senderDomain(msg.From.Address)
+	key, ok := d.keys[domain]
+	if !ok {
+		logs.LogDebug(fmt.Sprintf("No hay clave DKIM configurada para el dominio %s", domain), messageID)
+		return raw, nil
+	}
+
+	header, err := d.signatureHeader(raw, key)
+	if err != nil {
+		return nil, err
+	}
+
+	logs.LogDebug(fmt.Sprintf("Mensaje firmado con DKIM (d=%s, s=%s)", key.domain, key.selector), messageID)
+	return append([]byte(header), raw...), nil
+}
+
+// signatureHeader calcula el encabezado DKIM-Signature completo (con CRLF final) para el mensaje.
+func (d *DKIMSigner) signatureHeader(raw []byte, key *dkimKey) (string, error) {
+	headers, body := splitMessage(raw)
+
+	bodyHash := sha256.Sum256(canonicalizeBodyRelaxed(body))
+
+	var signedNames []string
+	var signedData bytes.Buffer
+	for _, name := range dkimSignedHeaders {
+		if value, ok := lastHeader(headers, name); ok {
+			signedNames = append(signedNames, strings.ToLower(name))
+			signedData.WriteString(canonicalizeHeaderRelaxed(name, value))
+		}
+	}
+
+	now := time.Now
+	if d.now != nil {
+		now = d.now
+	}
+
+	value := fmt.Sprintf(
+		"v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		key.algorithm,
+		key.domain,
+		key.selector,
+		now().Unix(),
+		strings.Join(signedNames, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+	// El propio encabezado DKIM-Signature se firma con b= vacío y sin CRLF final
+	signedData.WriteString(strings.TrimSuffix(canonicalizeHeaderRelaxed("DKIM-Signature", value), "\r\n"))
+
+	digest := sha256.Sum256(signedData.Bytes())
+
+	var signature []byte
+	var err error
+	switch key.algorithm {
+	case DKIMAlgorithmEd25519:
+		// RFC 8463: Ed25519 puro sobre el hash SHA-256 de los datos firmados
+		signature, err = key.signer.Sign(rand.Reader, digest[:], crypto.Hash(0))
+	default:
+		signature, err = key.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error calculando la firma DKIM: %w", err)
+	}
+
+	return "DKIM-Signature: " + value + base64.StdEncoding.EncodeToString(signature) + "\r\n", nil
+}
+
+// rawHeader es un encabezado del mensaje con su valor sin desplegar.
+type rawHeader struct {
+	name  string
+	value string
+}
+
+// splitMessage separa los encabezados (desplegando continuaciones) y el cuerpo del mensaje.
+func splitMessage(raw []byte) ([]rawHeader, []byte) {
+	headerPart, body := raw, []byte{}
+	if idx := bytes.Index(raw, []byte("\r\n\r\n")); idx >= 0 {
+		headerPart, body = raw[:idx+2], raw[idx+4:]
+	}
+
+	var headers []rawHeader
+	for _, line := range strings.Split(string(headerPart), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			headers[len(headers)-1].value += "\r\n" + line
+			continue
+		}
+		if colon := strings.Index(line, ":"); colon > 0 {
+			headers = append(headers, rawHeader{name: line[:colon], value: line[colon+1:]})
+		}
+	}
+	return headers, body
+}
+
+// lastHeader devuelve la última instancia del encabezado indicado.
+func lastHeader(headers []rawHeader, name string) (string, bool) {
+	for i := len(headers) - 1; i >= 0; i-- {
+		if strings.EqualFold(headers[i].name, name) {
+			return headers[i].value, true
+		}
+	}
+	return "", false
+}
+
+// canonicalizeHeaderRelaxed aplica la canonicalización "relaxed" de encabezados (RFC 6376, 3.4.2).
+func canonicalizeHeaderRelaxed(name, value string) string {
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// canonicalizeBodyRelaxed aplica la canonicalización "relaxed" del cuerpo (RFC 6376, 3.4.4).
+func canonicalizeBodyRelaxed(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	var out []string
+	for _, line := range lines {
+		line = strings.TrimRightFunc(line, isWSP)
+		out = append(out, collapseWSP(line))
+	}
+
+	// Eliminar las líneas vacías al final del cuerpo
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(out, "\r\n") + "\r\n")
+}
+
+// collapseWSP reduce cada secuencia de espacios o tabulaciones a un único espacio.
+func collapseWSP(line string) string {
+	var b strings.Builder
+	inWSP := false
+	for _, r := range line {
+		if isWSP(r) {
+			if !inWSP {
+				b.WriteByte(' ')
+			}
+			inWSP = true
+			continue
+		}
+		inWSP = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
@@ -0,0 +1,212 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"gmf_message_processor/connection"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var dkimSenderTest = &mail.Address{Name: "Notificaciones", Address: "notificaciones@bancolombia.com.co"}
+
+// Ejemplo de la sección 3.4.5 del RFC 6376.
+func TestCanonicalizeRelaxedRFCExample(t *testing.T) {
+	headers, body := splitMessage([]byte("A: X\r\nB : Y\t\r\n\tZ  \r\n\r\n C \r\nD \t E\r\n\r\n\r\n"))
+
+	assert.Len(t, headers, 2)
+	assert.Equal(t, "a:X\r\n", canonicalizeHeaderRelaxed(headers[0].name, headers[0].value))
+	assert.Equal(t, "b:Y Z\r\n", canonicalizeHeaderRelaxed(headers[1].name, headers[1].value))
+	assert.Equal(t, " C\r\nD E\r\n", string(canonicalizeBodyRelaxed(body)))
+}
+
+func TestCanonicalizeBodyRelaxedEmpty(t *testing.T) {
+	assert.Empty(t, canonicalizeBodyRelaxed([]byte("\r\n\r\n")))
+}
+
+func TestDKIMSignerRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+
+	signer := newTestDKIMSigner(t, &connection.SecretData{
+		DKIMSelector:   "gmf2024",
+		DKIMPrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+	})
+
+	signed := signTestMessage(t, signer)
+
+	tags := parseDKIMTags(t, signed)
+	assert.Equal(t, DKIMAlgorithmRSA, tags["a"])
+	assert.Equal(t, "bancolombia.com.co", tags["d"])
+	assert.Equal(t, "gmf2024", tags["s"])
+	assert.Equal(t, "from:to:subject:date:message-id:mime-version:content-type:content-transfer-encoding", tags["h"])
+
+	data, signature := dkimSignedData(t, signed, tags)
+	digest := sha256.Sum256(data)
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+}
+
+func TestDKIMSignerEd25519(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+
+	signer := newTestDKIMSigner(t, &connection.SecretData{
+		DKIMSelector:   "gmfed",
+		DKIMDomain:     "correo.bancolombia.com.co",
+		DKIMPrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+
+	signed := signTestMessage(t, signer)
+
+	tags := parseDKIMTags(t, signed)
+	assert.Equal(t, DKIMAlgorithmEd25519, tags["a"])
+	assert.Equal(t, "correo.bancolombia.com.co", tags["d"])
+
+	data, signature := dkimSignedData(t, signed, tags)
+	digest := sha256.Sum256(data)
+	assert.True(t, ed25519.Verify(public, digest[:], signature))
+}
+
+func TestDKIMSignerUnknownDomainNotSigned(t *testing.T) {
+	signer := &DKIMSigner{keys: map[string]*dkimKey{}}
+	raw := []byte("From: a@otro.com\r\n\r\nHola\r\n")
+
+	signed, err := signer.Sign(raw, &Message{From: &mail.Address{Address: "a@otro.com"}}, testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, raw, signed)
+}
+
+func TestNewDKIMSignerFromEnv(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+
+	mockSecretService := new(MockSecretService)
+	mockSecretService.On("GetSecret", "gmf-dkim", testMessageID).Return(&connection.SecretData{
+		DKIMSelector:   "gmfed",
+		DKIMPrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}, nil)
+
+	t.Setenv("DKIM_SECRETS", "Bancolombia.com.co=gmf-dkim")
+
+	signer, err := NewDKIMSignerFromEnv(mockSecretService, testMessageID)
+	assert.NoError(t, err)
+	assert.Contains(t, signer.keys, "bancolombia.com.co")
+	mockSecretService.AssertExpectations(t)
+}
+
+func TestNewDKIMSignerFromEnvNotConfigured(t *testing.T) {
+	t.Setenv("DKIM_SECRETS", "")
+
+	signer, err := NewDKIMSignerFromEnv(new(MockSecretService), testMessageID)
+	assert.NoError(t, err)
+	assert.Nil(t, signer)
+}
+
+func TestNewDKIMSignerFromEnvInvalidEntry(t *testing.T) {
+	t.Setenv("DKIM_SECRETS", "bancolombia.com.co")
+
+	_, err := NewDKIMSignerFromEnv(new(MockSecretService), testMessageID)
+	assert.Error(t, err)
+}
+
+func TestParseDKIMPrivateKeyInvalid(t *testing.T) {
+	_, _, err := parseDKIMPrivateKey("no es PEM")
+	assert.Error(t, err)
+}
+
+func newTestDKIMSigner(t *testing.T, secretData *connection.SecretData) *DKIMSigner {
+	key, err := newDKIMKey(secretData, "bancolombia.com.co")
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	return &DKIMSigner{
+		keys: map[string]*dkimKey{"bancolombia.com.co": key},
+		now:  func() time.Time { return time.Unix(1728292740, 0) },
+	}
+}
+
+func signTestMessage(t *testing.T, signer *DKIMSigner) []byte {
+	composer := NewComposer(signer)
+	signed, err := composer.Compose(&Message{
+		From:      dkimSenderTest,
+		To:        []*mail.Address{{Address: recipientEmailTest}},
+		Subject:   "Archivo rechazado",
+		HTMLBody:  "<p>Hola  mundo</p>\r\n",
+		MessageID: testMessageID,
+	}, testMessageID)
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	assert.True(t, bytes.HasPrefix(signed, []byte("DKIM-Signature: ")))
+	return signed
+}
+
+// parseDKIMTags extrae las etiquetas del encabezado DKIM-Signature.
+func parseDKIMTags(t *testing.T, signed []byte) map[string]string {
+	headers, _ := splitMessage(signed)
+	value, ok := lastHeader(headers, "DKIM-Signature")
+	if !ok {
+		t.Fatalf("El mensaje no contiene el encabezado DKIM-Signature")
+	}
+
+	tags := map[string]string{}
+	for _, tag := range strings.Split(value, ";") {
+		parts := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		if len(parts) == 2 {
+			tags[parts[0]] = parts[1]
+		}
+	}
+	return tags
+}
+
+// dkimSignedData reconstruye los datos firmados como lo haría un verificador.
+func dkimSignedData(t *testing.T, signed []byte, tags map[string]string) ([]byte, []byte) {
+	headers, body := splitMessage(signed)
+
+	bodyHash := sha256.Sum256(canonicalizeBodyRelaxed(body))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(bodyHash[:]), tags["bh"])
+
+	var data bytes.Buffer
+	for _, name := range strings.Split(tags["h"], ":") {
+		value, ok := lastHeader(headers, name)
+		if !ok {
+			t.Fatalf("El mensaje no contiene el encabezado firmado %s", name)
+		}
+		data.WriteString(canonicalizeHeaderRelaxed(name, value))
+	}
+
+	dkimValue, _ := lastHeader(headers, "DKIM-Signature")
+	withoutSignature := strings.TrimSuffix(dkimValue, tags["b"])
+	data.WriteString(strings.TrimSuffix(canonicalizeHeaderRelaxed("DKIM-Signature", withoutSignature), "\r\n"))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Fatalf("Error inesperado: %v", err)
+	}
+	return data.Bytes(), signature
+}
@@ -0,0 +1,249 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"os"
+	"strings"
+)
+
+// Nombres de los proveedores que se pueden configurar en EMAIL_PROVIDERS.
+const (
+	ProviderSMTP          = "smtp"
+	ProviderSMTPSecondary = "smtp_secondary"
+	ProviderSES           = "ses"
+	// ProviderOutbox escribe los correos en disco en lugar de enviarlos (desarrollo local y pruebas).
+	ProviderOutbox = "outbox"
+)
+
+// Provider asocia un servicio de correo con el nombre con el que se registra en los logs y en la base de datos.
+type Provider struct {
+	Name    string
+	Service EmailServiceInterface
+}
+
+// SendRecorder registra cada intento de envío y el proveedor que lo realizó.
+type SendRecorder interface {
+	RecordSend(envio *models.EnvioCorreo) error
+}
+
+// providerIDSender lo implementan los proveedores que asignan su propio identificador al correo (SES), con el que
+// reportan después las entregas, rebotes y quejas.
+type providerIDSender interface {
+	SendEmailWithID(ctx context.Context, remitente, destinatarios, asunto, cuerpo, messageID string) (string, error)
+}
+
+// FailoverEmailService prueba los proveedores en orden y cambia al siguiente ante errores temporales o de conexión.
+type FailoverEmailService struct {
+	providers []Provider
+	recorder  SendRecorder
+}
+
+// NewFailoverEmailService crea el servicio compuesto. recorder puede ser nil si no se requiere registrar los envíos.
+func NewFailoverEmailService(recorder SendRecorder, providers ...Provider) *FailoverEmailService {
+	return &FailoverEmailService{providers: providers, recorder: recorder}
+}
+
+// NewEmailServiceFromEnv construye la cadena de proveedores definida en EMAIL_PROVIDERS (por defecto "smtp") y,
+// en ambientes no productivos, la envuelve en el modo sandbox salvo que solo se utilice el outbox. Las opciones se
+// aplican al Composer de cada proveedor.
+func NewEmailServiceFromEnv(
+	secretService connection.SecretService,
+	recorder SendRecorder,
+	messageID string,
+	opts ...ComposerOption) (EmailServiceInterface, error) {
+	failover, err := newFailoverEmailServiceFromEnv(secretService, recorder, messageID, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// El outbox no envía correos, por lo que no requiere redirigir los destinatarios
+	if environment, ok := sandboxEnvironmentFromEnv(); ok && !onlyOutbox(ProvidersFromEnv()) {
+		recipient := os.Getenv("SANDBOX_RECIPIENT")
+		if recipient == "" {
+			logs.LogWarn(fmt.Sprintf(
+				"Modo sandbox activo en %s sin SANDBOX_RECIPIENT: los correos no se enviarán", environment), messageID)
+		}
+		return NewSandboxEmailService(failover, environment, recipient), nil
+	}
+	return failover, nil
+}
+
+// ProvidersFromEnv devuelve los nombres de los proveedores definidos en EMAIL_PROVIDERS, en orden.
+func ProvidersFromEnv() []string {
+	var names []string
+	for _, name := range strings.Split(os.Getenv("EMAIL_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{ProviderSMTP}
+	}
+	return names
+}
+
+// onlyOutbox indica si todos los proveedores configurados escriben en el outbox, es decir, si ningún correo sale
+// a la red.
+func onlyOutbox(names []string) bool {
+	for _, name := range names {
+		if name != ProviderOutbox {
+			return false
+		}
+	}
+	return true
+}
+
+func newFailoverEmailServiceFromEnv(
+	secretService connection.SecretService,
+	recorder SendRecorder,
+	messageID string,
+	opts ...ComposerOption) (*FailoverEmailService, error) {
+	names := ProvidersFromEnv()
+
+	var providers []Provider
+	for _, name := range names {
+		var service EmailServiceInterface
+		var err error
+		switch name {
+		case ProviderSMTP:
+			service, err = NewSMTPEmailService(secretService, messageID, opts...)
+		case ProviderSMTPSecondary:
+			service, err = NewSecondarySMTPEmailService(secretService, messageID, opts...)
+		case ProviderSES:
+			service, err = NewSESEmailService(secretService, messageID, opts...)
+		case ProviderOutbox:
+			service, err = NewOutboxEmailServiceFromEnv(secretService, messageID, opts...)
+		default:
+			err = fmt.Errorf("error: proveedor de correo %q no soportado", name)
+		}
+		if err != nil {
+			logs.LogError(fmt.Sprintf("Error inicializando el proveedor de correo %s", name), err, messageID)
+			return nil, err
+		}
+		providers = append(providers, Provider{Name: name, Service: service})
+	}
+
+	logs.LogDebug(fmt.Sprintf("Proveedores de correo configurados: %s", strings.Join(names, ",")), messageID)
+	return NewFailoverEmailService(recorder, providers...), nil
+}
+
+// SendEmail envía el correo con el primer proveedor disponible. Solo los fallos de transporte (conexión, timeout o
+// respuestas temporales) pasan al siguiente proveedor; una entrega parcial continúa con él únicamente para los
+// destinatarios diferidos. Cualquier otro error, como uno de configuración local, detiene la cadena.
+func (f *FailoverEmailService) SendEmail(
+	ctx context.Context, remitente, destinatarios, asunto, cuerpo, messageID string) error {
+	if len(f.providers) == 0 {
+		return fmt.Errorf("error: no hay proveedores de correo configurados")
+	}
+
+	pending := destinatarios
+	narrowed := false
+	var lastErr error
+
+	for i, provider := range f.providers {
+		providerID, err := sendWithProviderID(ctx, provider.Service, remitente, pending, asunto, cuerpo, messageID)
+		f.record(provider.Name, remitente, pending, providerID, err, messageID)
+
+		if err == nil {
+			logs.LogInfo(fmt.Sprintf("Correo entregado por el proveedor %s", provider.Name), messageID)
+			return nil
+		}
+		lastErr = err
+
+		if models.IsPermanentError(err) {
+			logs.LogError(fmt.Sprintf("Error permanente en el proveedor %s, no se probarán otros proveedores",
+				provider.Name), err, messageID)
+			return err
+		}
+
+		// Con el contexto cancelado o vencido no tiene sentido probar otro proveedor
+		if ctx.Err() != nil {
+			logs.LogError(fmt.Sprintf("Envío cancelado en el proveedor %s", provider.Name), ctx.Err(), messageID)
+			return err
+		}
+
+		var deliveryErr *DeliveryError
+		if errors.As(err, &deliveryErr) {
+			pending = strings.Join(deliveryErr.DeferredRecipients(), ",")
+			narrowed = true
+		} else if !IsTransportError(err) {
+			// Otro proveedor no corrige un error local y podría duplicar un envío ya aceptado
+			logs.LogError(fmt.Sprintf("Error en el proveedor %s no relacionado con la entrega, no se probarán "+
+				"otros proveedores", provider.Name), err, messageID)
+			break
+		}
+
+		if i < len(f.providers)-1 {
+			logs.LogWarn(fmt.Sprintf("Fallo en el proveedor %s, se intenta con %s: %v",
+				provider.Name, f.providers[i+1].Name, err), messageID)
+		}
+	}
+
+	// Si un proveedor anterior entregó parte de los destinatarios, sólo los pendientes deben reintentarse
+	var deliveryErr *DeliveryError
+	if narrowed && !errors.As(lastErr, &deliveryErr) {
+		return pendingDeliveryError(pending, lastErr)
+	}
+	return lastErr
+}
+
+// sendWithProviderID envía el correo con el proveedor y devuelve el identificador que este le asignó, o "" si el
+// proveedor no asigna uno.
+func sendWithProviderID(ctx context.Context, service EmailServiceInterface, remitente, destinatarios, asunto, cuerpo,
+	messageID string) (string, error) {
+	if sender, ok := service.(providerIDSender); ok {
+		return sender.SendEmailWithID(ctx, remitente, destinatarios, asunto, cuerpo, messageID)
+	}
+	return "", service.SendEmail(ctx, remitente, destinatarios, asunto, cuerpo, messageID)
+}
+
+// record registra el intento de envío con el identificador del proveedor, si lo asignó. Un fallo al registrar no
+// afecta el envío.
+func (f *FailoverEmailService) record(
+	provider, remitente, destinatarios, providerID string, sendErr error, messageID string) {
+	if f.recorder == nil {
+		return
+	}
+
+	envio := &models.EnvioCorreo{
+		MessageID:          messageID,
+		Proveedor:          provider,
+		ProveedorMessageID: providerID,
+		Remitente:          remitente,
+		Destinatarios:      destinatarios,
+		Estado:             models.EstadoEnvioEntregado,
+	}
+	if sendErr != nil {
+		envio.Estado = models.EstadoEnvioFallido
+		envio.Error = sendErr.Error()
+	}
+
+	if err := f.recorder.RecordSend(envio); err != nil {
+		logs.LogWarn(fmt.Sprintf("No fue posible registrar el envío del proveedor %s: %v", provider, err), messageID)
+	}
+}
+
+// pendingDeliveryError marca como diferidos los destinatarios que ningún proveedor logró entregar. Conserva el
+// retraso de reintento sugerido por la respuesta SMTP, si la hay.
+func pendingDeliveryError(pending string, cause error) *DeliveryError {
+	var results []RecipientResult
+	for _, address := range strings.Split(pending, ",") {
+		results = append(results, RecipientResult{
+			Address: address,
+			Status:  RecipientDeferred,
+			Message: cause.Error(),
+		})
+	}
+	deliveryErr := &DeliveryError{Results: results}
+	var smtpErr *SMTPError
+	if errors.As(cause, &smtpErr) {
+		deliveryErr.RetryAfter = smtpErr.RetryAfter
+	}
+	return deliveryErr
+}
@@ -0,0 +1,302 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock de EmailServiceInterface para simular un proveedor.
+type MockEmailProvider struct {
+	mock.Mock
+}
+
+func (m *MockEmailProvider) SendEmail(
+	ctx context.Context, remitente, destinatarios, asunto, cuerpo, messageID string) error {
+	args := m.Called(ctx, remitente, destinatarios, asunto, cuerpo, messageID)
+	return args.Error(0)
+}
+
+// Mock de SendRecorder que guarda los envíos registrados.
+type mockSendRecorder struct {
+	envios []*models.EnvioCorreo
+	err    error
+}
+
+func (m *mockSendRecorder) RecordSend(envio *models.EnvioCorreo) error {
+	m.envios = append(m.envios, envio)
+	return m.err
+}
+
+func TestFailoverEmailServiceFirstProviderDelivers(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID).Return(nil)
+
+	recorder := &mockSendRecorder{}
+	service := NewFailoverEmailService(recorder,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSES, Service: secondary},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.NoError(t, err)
+	secondary.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+	assert.Len(t, recorder.envios, 1)
+	assert.Equal(t, ProviderSMTP, recorder.envios[0].Proveedor)
+	assert.Equal(t, models.EstadoEnvioEntregado, recorder.envios[0].Estado)
+}
+
+func TestFailoverEmailServiceRecordsSESMessageID(t *testing.T) {
+	primary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID).Return(newTransportError(errors.New("connection refused")))
+	client := new(MockSESClient)
+	client.On("SendRawEmail", mock.Anything, mock.Anything).
+		Return(&ses.SendRawEmailOutput{MessageId: aws.String("ses-1")}, nil)
+
+	recorder := &mockSendRecorder{}
+	service := NewFailoverEmailService(recorder,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSES, Service: &SESEmailService{client: client, timeout: 10 * time.Second}},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.NoError(t, err)
+	assert.Len(t, recorder.envios, 2)
+	assert.Empty(t, recorder.envios[0].ProveedorMessageID)
+	assert.Equal(t, ProviderSES, recorder.envios[1].Proveedor)
+	assert.Equal(t, "ses-1", recorder.envios[1].ProveedorMessageID)
+}
+
+func TestFailoverEmailServiceSwitchesOnConnectionError(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, recipientEmailTest, testSubject, testBody, testMessageID).
+		Return(newTransportError(errors.New("dial tcp: connection refused")))
+	secondary.On("SendEmail", mock.Anything, senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID).Return(nil)
+
+	recorder := &mockSendRecorder{err: errors.New("tabla no disponible")}
+	service := NewFailoverEmailService(recorder,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSMTPSecondary, Service: secondary},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	// El fallo al registrar no afecta el envío
+	assert.NoError(t, err)
+	assert.Len(t, recorder.envios, 2)
+	assert.Equal(t, models.EstadoEnvioFallido, recorder.envios[0].Estado)
+	assert.Contains(t, recorder.envios[0].Error, "connection refused")
+	assert.Equal(t, ProviderSMTPSecondary, recorder.envios[1].Proveedor)
+	assert.Equal(t, models.EstadoEnvioEntregado, recorder.envios[1].Estado)
+}
+
+func TestFailoverEmailServiceStopsOnPermanentError(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, recipientEmailTest, testSubject, testBody, testMessageID).
+		Return(models.NewPermanentError(errors.New("remitente inválido")))
+
+	service := NewFailoverEmailService(nil,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSES, Service: secondary},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.True(t, models.IsPermanentError(err))
+	secondary.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+}
+
+func TestFailoverEmailServiceStopsOnLocalError(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, recipientEmailTest, testSubject, testBody, testMessageID).
+		Return(errors.New("error: clave privada DKIM inválida"))
+
+	service := NewFailoverEmailService(nil,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSES, Service: secondary},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	// El mensaje se reintenta, pero sin pasar por otro proveedor
+	assert.EqualError(t, err, "error: clave privada DKIM inválida")
+	assert.False(t, models.IsPermanentError(err))
+	secondary.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+}
+
+func TestFailoverEmailServiceContinuesWithDeferredRecipients(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, "a@test.com,b@test.com", testSubject, testBody, testMessageID).
+		Return(&DeliveryError{Results: []RecipientResult{
+			{Address: "a@test.com", Status: RecipientAccepted, Code: 250},
+			{Address: "b@test.com", Status: RecipientDeferred, Code: 421},
+		}})
+	secondary.On("SendEmail", mock.Anything, senderEmailTest, "b@test.com", testSubject, testBody,
+		testMessageID).Return(nil)
+
+	service := NewFailoverEmailService(nil,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSES, Service: secondary},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com,b@test.com", testSubject, testBody,
+		testMessageID)
+
+	assert.NoError(t, err)
+	secondary.AssertExpectations(t)
+}
+
+func TestFailoverEmailServiceAllProvidersFailAfterPartialDelivery(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, "a@test.com,b@test.com", testSubject, testBody, testMessageID).
+		Return(&DeliveryError{Results: []RecipientResult{
+			{Address: "a@test.com", Status: RecipientAccepted, Code: 250},
+			{Address: "b@test.com", Status: RecipientDeferred, Code: 421},
+		}})
+	secondary.On("SendEmail", mock.Anything, senderEmailTest, "b@test.com", testSubject, testBody, testMessageID).
+		Return(newTransportError(errors.New("error: timeout al enviar correo electrónico")))
+
+	service := NewFailoverEmailService(nil,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSES, Service: secondary},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com,b@test.com", testSubject, testBody,
+		testMessageID)
+
+	// Sólo el destinatario pendiente debe reintentarse
+	var deliveryErr *DeliveryError
+	assert.True(t, errors.As(err, &deliveryErr))
+	assert.Equal(t, []string{"b@test.com"}, deliveryErr.DeferredRecipients())
+}
+
+func TestFailoverEmailServiceKeepsRetryDelayForPendingRecipients(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, "a@test.com,b@test.com", testSubject, testBody,
+		testMessageID).
+		Return(&DeliveryError{Results: []RecipientResult{
+			{Address: "a@test.com", Status: RecipientAccepted, Code: 250},
+			{Address: "b@test.com", Status: RecipientDeferred, Code: 421},
+		}})
+	secondary.On("SendEmail", mock.Anything, senderEmailTest, "b@test.com", testSubject, testBody, testMessageID).
+		Return(&SMTPError{Stage: StageMail, Code: 421, Action: ActionDefer, RetryAfter: 10 * time.Minute})
+
+	service := NewFailoverEmailService(nil,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSMTPSecondary, Service: secondary},
+	)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com,b@test.com", testSubject, testBody,
+		testMessageID)
+
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		t.Fatalf("Se esperaba un DeliveryError, se obtuvo %v", err)
+	}
+	assert.Equal(t, []string{"b@test.com"}, deliveryErr.DeferredRecipients())
+	assert.Equal(t, 10*time.Minute, deliveryErr.RetryDelay())
+}
+
+func TestFailoverEmailServiceAllProvidersFail(t *testing.T) {
+	primary := new(MockEmailProvider)
+	primary.On("SendEmail", mock.Anything, senderEmailTest, recipientEmailTest, testSubject, testBody, testMessageID).
+		Return(newTransportError(errors.New("error: timeout al enviar correo electrónico")))
+
+	service := NewFailoverEmailService(nil, Provider{Name: ProviderSMTP, Service: primary})
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timeout")
+	assert.False(t, models.IsPermanentError(err))
+}
+
+func TestFailoverEmailServiceStopsOnCancelledContext(t *testing.T) {
+	primary := new(MockEmailProvider)
+	secondary := new(MockEmailProvider)
+	ctx, cancel := context.WithCancel(context.Background())
+	primary.On("SendEmail", ctx, senderEmailTest, recipientEmailTest, testSubject, testBody, testMessageID).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(errors.New("error: envío de correo electrónico cancelado: context canceled"))
+
+	service := NewFailoverEmailService(nil,
+		Provider{Name: ProviderSMTP, Service: primary},
+		Provider{Name: ProviderSES, Service: secondary},
+	)
+
+	err := service.SendEmail(ctx, senderEmailTest, recipientEmailTest, testSubject, testBody, testMessageID)
+
+	assert.Error(t, err)
+	primary.AssertExpectations(t)
+	secondary.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+}
+
+func TestNewEmailServiceFromEnvUnknownProvider(t *testing.T) {
+	mockSecretService := new(MockSecretService)
+	t.Setenv("EMAIL_PROVIDERS", "smtp,postal")
+	t.Setenv("SECRETS_SMTP", secretName)
+	t.Setenv("SMTP_SERVER", smtpServerTest)
+	t.Setenv("SMTP_PORT", "587")
+	mockSecretService.On("GetSecret", secretName, testMessageID).Return(testSMTPSecret(), nil)
+
+	service, err := NewEmailServiceFromEnv(mockSecretService, nil, testMessageID)
+
+	assert.Error(t, err)
+	assert.Nil(t, service)
+	assert.Contains(t, err.Error(), "postal")
+}
+
+func TestNewEmailServiceFromEnvProviders(t *testing.T) {
+	mockSecretService := new(MockSecretService)
+	t.Setenv("EMAIL_PROVIDERS", " smtp , smtp_secondary ")
+	t.Setenv("SECRETS_SMTP", secretName)
+	t.Setenv("SMTP_SERVER", smtpServerTest)
+	t.Setenv("SMTP_PORT", "587")
+	t.Setenv("SECRETS_SMTP_SECONDARY", "secondary-secret")
+	t.Setenv("SMTP_SECONDARY_SERVER", "smtp.backup.com")
+	t.Setenv("SMTP_SECONDARY_PORT", "465")
+	t.Setenv("APP_ENV", "production")
+	mockSecretService.On("GetSecret", secretName, testMessageID).Return(testSMTPSecret(), nil)
+	mockSecretService.On("GetSecret", "secondary-secret", testMessageID).Return(testSMTPSecret(), nil)
+
+	emailService, err := NewEmailServiceFromEnv(mockSecretService, nil, testMessageID)
+
+	assert.NoError(t, err)
+	service, ok := emailService.(*FailoverEmailService)
+	if !ok {
+		t.Fatalf("Se esperaba un FailoverEmailService fuera del modo sandbox, se obtuvo %T", emailService)
+	}
+	assert.Len(t, service.providers, 2)
+	assert.Equal(t, ProviderSMTPSecondary, service.providers[1].Name)
+	assert.Equal(t, "smtp.backup.com", service.providers[1].Service.(*SMTPEmailService).server)
+	assert.Equal(t, "465", service.providers[1].Service.(*SMTPEmailService).port)
+}
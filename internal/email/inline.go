@@ -0,0 +1,104 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ImageSource obtiene las imágenes que las plantillas referencian con URLs cid:. Devuelve nil si la imagen no
+// existe.
+type ImageSource interface {
+	FindImage(contentID string) (*models.ImagenCorreo, error)
+}
+
+// InlineImage es una imagen incluida en el correo como parte de multipart/related.
+type InlineImage struct {
+	ContentID   string
+	ContentType string
+	Data        []byte
+}
+
+// cidPattern reconoce las referencias cid:<nombre> del cuerpo HTML (en src, url() o background).
+var cidPattern = regexp.MustCompile(`(?i)\bcid:([^"'\s()<>]+)`)
+
+// DirImageSource obtiene las imágenes de un directorio local (INLINE_IMAGES_DIR): cid:logo.png se resuelve como
+// <dir>/logo.png.
+type DirImageSource struct {
+	dir string
+}
+
+// NewDirImageSource crea la fuente de imágenes para el directorio indicado.
+func NewDirImageSource(dir string) *DirImageSource {
+	return &DirImageSource{dir: dir}
+}
+
+// FindImage lee la imagen del directorio. El nombre no puede contener rutas.
+func (s *DirImageSource) FindImage(contentID string) (*models.ImagenCorreo, error) {
+	if contentID != filepath.Base(contentID) || strings.HasPrefix(contentID, ".") {
+		return nil, fmt.Errorf("error: nombre de imagen inline inválido %q", contentID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, contentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(contentID))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return &models.ImagenCorreo{Nombre: contentID, ContentType: contentType, Contenido: data}, nil
+}
+
+// resolveInlineImages busca cada imagen referenciada con cid: en las fuentes configuradas, en orden. Una imagen
+// que no existe se registra como advertencia y el correo se envía sin ella; un error al consultar la fuente se
+// devuelve para que el envío se reintente.
+func (c *Composer) resolveInlineImages(msg *Message, messageID string) error {
+	seen := map[string]bool{}
+	for _, match := range cidPattern.FindAllStringSubmatch(msg.HTMLBody, -1) {
+		contentID := match[1]
+		if seen[contentID] {
+			continue
+		}
+		seen[contentID] = true
+
+		image, err := c.findImage(contentID)
+		if err != nil {
+			logs.LogError(fmt.Sprintf("Error obteniendo la imagen inline %s", contentID), err, messageID)
+			return err
+		}
+		if image == nil {
+			logs.LogWarn(fmt.Sprintf("La imagen inline %s no existe, el correo se envía sin ella", contentID),
+				messageID)
+			continue
+		}
+
+		msg.Inline = append(msg.Inline, InlineImage{
+			ContentID:   contentID,
+			ContentType: image.ContentType,
+			Data:        image.Contenido,
+		})
+	}
+	return nil
+}
+
+func (c *Composer) findImage(contentID string) (*models.ImagenCorreo, error) {
+	for _, source := range c.images {
+		image, err := source.FindImage(contentID)
+		if err != nil || image != nil {
+			return image, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,156 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"gmf_message_processor/internal/models"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pngLogo es una imagen PNG mínima (1x1) utilizada en las pruebas.
+var pngLogo = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+// mockImageSource devuelve las imágenes configuradas en memoria.
+type mockImageSource struct {
+	images  map[string]*models.ImagenCorreo
+	err     error
+	queried []string
+}
+
+func (m *mockImageSource) FindImage(contentID string) (*models.ImagenCorreo, error) {
+	m.queried = append(m.queried, contentID)
+	return m.images[contentID], m.err
+}
+
+func inlineTestMessage(body string) *Message {
+	return &Message{
+		From:      &mail.Address{Address: senderEmailTest},
+		To:        []*mail.Address{{Address: recipientEmailTest}},
+		Subject:   testSubject,
+		HTMLBody:  body,
+		MessageID: testMessageID,
+	}
+}
+
+func TestComposeEmbedsInlineImages(t *testing.T) {
+	source := &mockImageSource{images: map[string]*models.ImagenCorreo{
+		"logo.png": {Nombre: "logo.png", ContentType: "image/png", Contenido: pngLogo},
+	}}
+	composer := NewComposer()
+	WithImageSource(source)(composer)
+
+	raw, err := composer.Compose(inlineTestMessage(
+		`<img src="cid:logo.png"><div style="background:url(cid:logo.png)"></div>`), testMessageID)
+	assert.NoError(t, err)
+	// La misma imagen referenciada dos veces se consulta e incluye una sola vez
+	assert.Equal(t, []string{"logo.png"}, source.queried)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("El mensaje compuesto no es válido: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/related", mediaType)
+	assert.Equal(t, "text/html", params["type"])
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	htmlPart, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("Error leyendo la parte HTML: %v", err)
+	}
+	html, _ := io.ReadAll(htmlPart)
+	assert.Contains(t, string(html), `<img src="cid:logo.png">`)
+
+	imagePart, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("Error leyendo la parte de la imagen: %v", err)
+	}
+	assert.Equal(t, "<logo.png>", imagePart.Header.Get("Content-Id"))
+	assert.Equal(t, `inline; filename=logo.png`, imagePart.Header.Get("Content-Disposition"))
+	assert.Equal(t, "base64", imagePart.Header.Get("Content-Transfer-Encoding"))
+
+	_, err = reader.NextPart()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestComposeMissingInlineImageSendsWithoutIt(t *testing.T) {
+	composer := NewComposer()
+	WithImageSource(&mockImageSource{})(composer)
+
+	raw, err := composer.Compose(inlineTestMessage(`<img src="cid:no-existe.png">`), testMessageID)
+
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), "Content-Type: text/html; charset=\"UTF-8\"\r\n")
+}
+
+func TestComposeInlineImageSourceError(t *testing.T) {
+	composer := NewComposer()
+	WithImageSource(&mockImageSource{err: errors.New("conexión cerrada")})(composer)
+
+	_, err := composer.Compose(inlineTestMessage(`<img src="cid:logo.png">`), testMessageID)
+
+	assert.Error(t, err)
+}
+
+func TestComposeWithoutImageSourceKeepsSinglePart(t *testing.T) {
+	raw, err := NewComposer().Compose(inlineTestMessage(`<img src="cid:logo.png">`), testMessageID)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "multipart/related")
+}
+
+func TestDirImageSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), pngLogo, 0o644); err != nil {
+		t.Fatalf("Error escribiendo la imagen de prueba: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "firma"), pngLogo, 0o644); err != nil {
+		t.Fatalf("Error escribiendo la imagen de prueba: %v", err)
+	}
+	source := NewDirImageSource(dir)
+
+	image, err := source.FindImage("logo.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", image.ContentType)
+	assert.Equal(t, pngLogo, image.Contenido)
+
+	// Sin extensión el tipo se detecta a partir del contenido
+	image, err = source.FindImage("firma")
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", image.ContentType)
+
+	image, err = source.FindImage("otro.png")
+	assert.NoError(t, err)
+	assert.Nil(t, image)
+
+	_, err = source.FindImage("../secreto.png")
+	assert.Error(t, err)
+}
+
+func TestNewComposerFromEnvImageSources(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), pngLogo, 0o644); err != nil {
+		t.Fatalf("Error escribiendo la imagen de prueba: %v", err)
+	}
+	t.Setenv("DKIM_SECRETS", "")
+	t.Setenv("INLINE_IMAGES_DIR", dir)
+	db := &mockImageSource{}
+
+	composer, err := NewComposerFromEnv(new(MockSecretService), testMessageID, WithImageSource(db))
+
+	assert.NoError(t, err)
+	// La base de datos se consulta primero; si no tiene la imagen se usa el directorio
+	raw, err := composer.Compose(inlineTestMessage(`<img src="cid:logo.png">`), testMessageID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logo.png"}, db.queried)
+	assert.Contains(t, string(raw), "Content-Id: <logo.png>")
+}
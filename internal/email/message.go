@@ -0,0 +1,304 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message representa un correo electrónico antes de componerse en formato MIME.
+type Message struct {
+	From     *mail.Address
+	To       []*mail.Address
+	Cc       []*mail.Address
+	Bcc      []*mail.Address
+	Subject  string
+	HTMLBody string
+	// MessageID es el MessageId del mensaje SQS que originó el correo.
+	MessageID string
+	Date      time.Time
+	// Inline son las imágenes referenciadas con cid: en HTMLBody. Si hay alguna, el cuerpo se compone como
+	// multipart/related.
+	Inline []InlineImage
+	// SMIME indica si el cuerpo debe firmarse y/o cifrarse con S/MIME.
+	SMIME SMIMEOptions
+}
+
+// Recipients devuelve todos los destinatarios del sobre SMTP (To, Cc y Bcc).
+func (m *Message) Recipients() []string {
+	recipients := make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc))
+	recipients = append(recipients, addressStrings(m.To)...)
+	recipients = append(recipients, addressStrings(m.Cc)...)
+	return append(recipients, addressStrings(m.Bcc)...)
+}
+
+// MessageSigner firma un mensaje MIME ya compuesto (por ejemplo DKIM).
+type MessageSigner interface {
+	Sign(raw []byte, msg *Message, messageID string) ([]byte, error)
+}
+
+// Composer compone el mensaje MIME y aplica los firmantes configurados.
+// Todos los backends de envío utilizan el mismo Composer antes de entregar el mensaje.
+type Composer struct {
+	signers      []MessageSigner
+	images       []ImageSource
+	smimeSigner  *SMIMESigner
+	certificates CertificateSource
+}
+
+// ComposerOption configura dependencias opcionales del Composer.
+type ComposerOption func(*Composer)
+
+// WithImageSource agrega una fuente de imágenes inline (por ejemplo, la tabla cgd_correos_imagenes).
+func WithImageSource(source ImageSource) ComposerOption {
+	return func(c *Composer) {
+		c.images = append(c.images, source)
+	}
+}
+
+// NewComposer crea un Composer con los firmantes indicados.
+func NewComposer(signers ...MessageSigner) *Composer {
+	return &Composer{signers: signers}
+}
+
+// NewComposerFromEnv crea el Composer según la configuración del entorno (DKIM_SECRETS, SECRETS_SMIME e
+// INLINE_IMAGES_DIR). Las fuentes de imágenes recibidas en opts se consultan antes que el directorio local.
+func NewComposerFromEnv(
+	secretService connection.SecretService, messageID string, opts ...ComposerOption) (*Composer, error) {
+	var signers []MessageSigner
+
+	dkimSigner, err := NewDKIMSignerFromEnv(secretService, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if dkimSigner != nil {
+		signers = append(signers, dkimSigner)
+	}
+
+	smimeSigner, err := NewSMIMESignerFromEnv(secretService, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	composer := NewComposer(signers...)
+	composer.smimeSigner = smimeSigner
+	for _, opt := range opts {
+		opt(composer)
+	}
+	if dir := os.Getenv("INLINE_IMAGES_DIR"); dir != "" {
+		composer.images = append(composer.images, NewDirImageSource(dir))
+	}
+	return composer, nil
+}
+
+// Compose construye el mensaje MIME, lo protege con S/MIME si el mensaje lo pide y lo firma con cada firmante
+// configurado.
+func (c *Composer) Compose(msg *Message, messageID string) ([]byte, error) {
+	if c != nil && len(c.images) > 0 && len(msg.Inline) == 0 {
+		if err := c.resolveInlineImages(msg, messageID); err != nil {
+			return nil, err
+		}
+	}
+
+	entity, err := buildEntity(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// La firma y el cifrado S/MIME protegen el cuerpo; los encabezados del mensaje quedan fuera
+	if msg.SMIME.Sign || msg.SMIME.Encrypt {
+		entity, err = c.protectEntity(entity, msg, messageID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	raw := append(buildHeaders(msg), entity...)
+
+	if c == nil {
+		return raw, nil
+	}
+
+	for _, signer := range c.signers {
+		raw, err = signer.Sign(raw, msg, messageID)
+		if err != nil {
+			logs.LogError("Error firmando el mensaje", err, messageID)
+			return nil, err
+		}
+	}
+
+	return raw, nil
+}
+
+// buildHeaders escribe los encabezados del mensaje, sin los de contenido.
+func buildHeaders(msg *Message) []byte {
+	date := msg.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", msg.From.String())
+	writeHeader(&buf, "To", formatAddressList(msg.To))
+	if len(msg.Cc) > 0 {
+		writeHeader(&buf, "Cc", formatAddressList(msg.Cc))
+	}
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	writeHeader(&buf, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", buildMessageIDHeader(msg.From.Address, msg.MessageID, date))
+	writeHeader(&buf, "MIME-Version", "1.0")
+	return buf.Bytes()
+}
+
+// buildEntity construye la entidad MIME del cuerpo (sus encabezados de contenido seguidos del contenido), con el
+// HTML codificado en quoted-printable.
+func buildEntity(msg *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if len(msg.Inline) > 0 {
+		if err := writeRelatedBody(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	writeHeader(&buf, "Content-Type", "text/html; charset=\"UTF-8\"")
+	writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	if err := writeQuotedPrintable(&buf, msg.HTMLBody); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeRelatedBody escribe el cuerpo como multipart/related: la parte HTML seguida de cada imagen inline,
+// identificada con el Content-ID que referencia el HTML.
+func writeRelatedBody(buf *bytes.Buffer, msg *Message) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	htmlPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=\"UTF-8\""},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeQuotedPrintable(htmlPart, msg.HTMLBody); err != nil {
+		return err
+	}
+
+	for _, image := range msg.Inline {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(image.ContentType, map[string]string{"name": image.ContentID})},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Id":                {"<" + image.ContentID + ">"},
+			"Content-Disposition":       {mime.FormatMediaType("inline", map[string]string{"filename": image.ContentID})},
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeBase64Lines(part, image.Data); err != nil {
+			return fmt.Errorf("error codificando la imagen inline %s: %w", image.ContentID, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	writeHeader(buf, "Content-Type", mime.FormatMediaType("multipart/related",
+		map[string]string{"boundary": writer.Boundary(), "type": "text/html"}))
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return nil
+}
+
+// writeQuotedPrintable escribe el cuerpo HTML codificado en quoted-printable.
+func writeQuotedPrintable(w io.Writer, html string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(html)); err != nil {
+		return fmt.Errorf("error codificando el cuerpo del correo: %w", err)
+	}
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("error codificando el cuerpo del correo: %w", err)
+	}
+	return nil
+}
+
+// writeBase64Lines escribe los datos en base64 en líneas de 76 caracteres (RFC 2045).
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\r\n")
+}
+
+// buildMessageIDHeader genera el Message-ID incluyendo el MessageId de SQS para poder correlacionar el correo.
+func buildMessageIDHeader(from, messageID string, date time.Time) string {
+	domain := senderDomain(from)
+	if domain == "" {
+		domain = "localhost"
+	}
+	if messageID == "" {
+		messageID = "gmf"
+	}
+	return fmt.Sprintf("<%s.%d@%s>", messageID, date.UnixNano(), domain)
+}
+
+// MessageIDFromHeader extrae el MessageId de SQS de un encabezado Message-ID generado por buildMessageIDHeader.
+// Devuelve false si el encabezado no tiene ese formato.
+func MessageIDFromHeader(header string) (string, bool) {
+	value := strings.Trim(strings.TrimSpace(header), "<>")
+	at := strings.LastIndex(value, "@")
+	if at <= 0 {
+		return "", false
+	}
+
+	local := value[:at]
+	dot := strings.LastIndex(local, ".")
+	if dot <= 0 || dot == len(local)-1 {
+		return "", false
+	}
+	for _, r := range local[dot+1:] {
+		if r < '0' || r > '9' {
+			return "", false
+		}
+	}
+
+	messageID := local[:dot]
+	if messageID == "gmf" {
+		return "", false
+	}
+	return messageID, true
+}
+
+// senderDomain devuelve el dominio (en minúsculas) de la dirección del remitente.
+func senderDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(address[at+1:]))
+}
@@ -0,0 +1,63 @@
+package email
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposeBuildsMIMEMessage(t *testing.T) {
+	raw, err := NewComposer().Compose(&Message{
+		From:      &mail.Address{Name: "Notificación GMF", Address: senderEmailTest},
+		To:        []*mail.Address{{Address: recipientEmailTest}, {Address: "otro@test.com"}},
+		Cc:        []*mail.Address{{Address: "copia@test.com"}},
+		Bcc:       []*mail.Address{{Address: "oculto@test.com"}},
+		Subject:   "Notificación de rechazo",
+		HTMLBody:  "<p>Código: EPCM002</p>",
+		MessageID: testMessageID,
+		Date:      time.Date(2024, 10, 7, 9, 19, 0, 0, time.UTC),
+	}, testMessageID)
+	assert.NoError(t, err)
+
+	msg := string(raw)
+	assert.Contains(t, msg, "From: =?utf-8?q?Notificaci=C3=B3n_GMF?= <sender@test.com>\r\n")
+	assert.Contains(t, msg, "To: <recipient@test.com>, <otro@test.com>\r\n")
+	assert.Contains(t, msg, "Cc: <copia@test.com>\r\n")
+	assert.NotContains(t, msg, "oculto@test.com")
+	assert.Contains(t, msg, "Subject: =?UTF-8?q?Notificaci=C3=B3n_de_rechazo?=\r\n")
+	assert.Contains(t, msg, "Message-ID: <test-message-id.")
+	assert.Contains(t, msg, "@test.com>\r\n")
+	assert.Contains(t, msg, "Content-Transfer-Encoding: quoted-printable\r\n")
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\n<p>C=C3=B3digo: EPCM002</p>"))
+}
+
+func TestMessageRecipients(t *testing.T) {
+	msg := &Message{
+		To:  []*mail.Address{{Address: "a@test.com"}},
+		Cc:  []*mail.Address{{Address: "b@test.com"}},
+		Bcc: []*mail.Address{{Address: "c@test.com"}},
+	}
+	assert.Equal(t, []string{"a@test.com", "b@test.com", "c@test.com"}, msg.Recipients())
+}
+
+func TestSenderDomain(t *testing.T) {
+	assert.Equal(t, "bancolombia.com.co", senderDomain("notificaciones@Bancolombia.com.co"))
+	assert.Equal(t, "test.com", senderDomain("sender@test.com"))
+	assert.Equal(t, "", senderDomain("sin-arroba"))
+}
+
+func TestMessageIDFromHeader(t *testing.T) {
+	header := buildMessageIDHeader(senderEmailTest, "e9cf1877-7fd5-4fba", time.Unix(0, 1728310777900000000))
+
+	messageID, ok := MessageIDFromHeader(header)
+	assert.True(t, ok)
+	assert.Equal(t, "e9cf1877-7fd5-4fba", messageID)
+
+	_, ok = MessageIDFromHeader("<0100018f-abc@email.amazonses.com>")
+	assert.False(t, ok)
+	_, ok = MessageIDFromHeader(buildMessageIDHeader(senderEmailTest, "", time.Now()))
+	assert.False(t, ok)
+}
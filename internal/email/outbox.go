@@ -0,0 +1,164 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultOutboxDir es el directorio del outbox si no se define OUTBOX_DIR.
+const defaultOutboxDir = "outbox"
+
+// OutboxMetadata es el contenido del archivo JSON que acompaña a cada correo escrito en el outbox.
+type OutboxMetadata struct {
+	MessageID       string    `json:"message_id"`
+	MessageIDHeader string    `json:"message_id_header"`
+	From            string    `json:"from"`
+	To              []string  `json:"to"`
+	Subject         string    `json:"subject"`
+	Recipients      []string  `json:"recipients"`
+	File            string    `json:"file"`
+	Size            int       `json:"size"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// OutboxEmailService implementa EmailService escribiendo cada mensaje, ya compuesto (y firmado si aplica), como
+// un archivo .eml junto con un archivo .json de metadatos. Se utiliza en desarrollo local y en pruebas de
+// integración para inspeccionar el correo exacto sin acceso a la red.
+type OutboxEmailService struct {
+	dir      string
+	composer *Composer
+	now      func() time.Time
+}
+
+// NewOutboxEmailService crea el servicio que escribe los correos en dir.
+func NewOutboxEmailService(dir string, composer *Composer) *OutboxEmailService {
+	return &OutboxEmailService{dir: dir, composer: composer, now: time.Now}
+}
+
+// NewOutboxEmailServiceFromEnv crea el servicio con el directorio OUTBOX_DIR (por defecto "outbox").
+func NewOutboxEmailServiceFromEnv(
+	secretService connection.SecretService, messageID string, opts ...ComposerOption) (*OutboxEmailService, error) {
+	dir := os.Getenv("OUTBOX_DIR")
+	if dir == "" {
+		dir = defaultOutboxDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logs.LogError(fmt.Sprintf("No fue posible crear el directorio del outbox %s", dir), err, messageID)
+		return nil, err
+	}
+
+	composer, err := NewComposerFromEnv(secretService, messageID, opts...)
+	if err != nil {
+		logs.LogError("Error inicializando la composición de mensajes", err, messageID)
+		return nil, err
+	}
+
+	return NewOutboxEmailService(dir, composer), nil
+}
+
+// SendEmail compone el mensaje y lo escribe en el outbox como <timestamp>_<messageID>.eml y .json.
+func (s *OutboxEmailService) SendEmail(
+	ctx context.Context, remitente, destinatarios, asunto, cuerpo, messageID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	from, err := ParseSender(remitente)
+	if err != nil {
+		logs.LogError("Remitente inválido", err, messageID)
+		return err
+	}
+	to, err := ParseAddressList(destinatarios)
+	if err != nil {
+		logs.LogError("Destinatarios inválidos", err, messageID)
+		return err
+	}
+
+	now := s.now()
+	message := &Message{
+		From:      from,
+		To:        to,
+		Subject:   asunto,
+		HTMLBody:  cuerpo,
+		MessageID: messageID,
+		Date:      now,
+		SMIME:     SMIMEFromContext(ctx),
+	}
+	raw, err := s.composer.Compose(message, messageID)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s_%s", now.UTC().Format("20060102T150405.000000000Z"), outboxFileName(messageID))
+	emlPath := filepath.Join(s.dir, name+".eml")
+	if err := writeFileAtomic(emlPath, raw); err != nil {
+		logs.LogError("Error escribiendo el correo en el outbox", err, messageID)
+		return fmt.Errorf("error escribiendo el correo en el outbox: %w", err)
+	}
+
+	metadata := OutboxMetadata{
+		MessageID:       messageID,
+		MessageIDHeader: messageIDHeader(raw),
+		From:            from.Address,
+		To:              addressStrings(to),
+		Subject:         asunto,
+		Recipients:      message.Recipients(),
+		File:            filepath.Base(emlPath),
+		Size:            len(raw),
+		CreatedAt:       now.UTC(),
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializando los metadatos del outbox: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, name+".json"), data); err != nil {
+		logs.LogError("Error escribiendo los metadatos en el outbox", err, messageID)
+		return fmt.Errorf("error escribiendo los metadatos en el outbox: %w", err)
+	}
+
+	logs.LogInfo(fmt.Sprintf("Correo escrito en el outbox: %s", emlPath), messageID)
+	return nil
+}
+
+// outboxFileName reemplaza los caracteres que no son seguros en un nombre de archivo.
+func outboxFileName(messageID string) string {
+	if messageID == "" {
+		return "gmf"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, messageID)
+}
+
+// messageIDHeader devuelve el encabezado Message-ID del mensaje compuesto.
+func messageIDHeader(raw []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return ""
+	}
+	return msg.Header.Get("Message-Id")
+}
+
+// writeFileAtomic escribe el archivo con un nombre temporal y lo renombra, para que quien lea el outbox nunca
+// encuentre un archivo a medio escribir.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,120 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readOutbox devuelve los archivos .eml y .json escritos en el directorio.
+func readOutbox(t *testing.T, dir string) ([]byte, OutboxMetadata) {
+	emls, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	jsons, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(emls) != 1 || len(jsons) != 1 {
+		t.Fatalf("Se esperaba un .eml y un .json en el outbox, se encontraron %d y %d", len(emls), len(jsons))
+	}
+
+	raw, err := os.ReadFile(emls[0])
+	if err != nil {
+		t.Fatalf("Error leyendo el .eml: %v", err)
+	}
+	data, err := os.ReadFile(jsons[0])
+	if err != nil {
+		t.Fatalf("Error leyendo el .json: %v", err)
+	}
+	var metadata OutboxMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatalf("Error interpretando los metadatos: %v", err)
+	}
+	return raw, metadata
+}
+
+func TestOutboxEmailServiceWritesMessageAndMetadata(t *testing.T) {
+	dir := t.TempDir()
+	service := NewOutboxEmailService(dir, NewComposer())
+	service.now = func() time.Time { return time.Date(2024, 10, 7, 14, 30, 0, 0, time.UTC) }
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com; B <b@test.com>", testSubject,
+		testBody, "sqs/123")
+
+	assert.NoError(t, err)
+	raw, metadata := readOutbox(t, dir)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("El .eml no es un mensaje válido: %v", err)
+	}
+	assert.Equal(t, testSubject, msg.Header.Get("Subject"))
+	assert.Equal(t, "sqs/123", metadata.MessageID)
+	assert.Equal(t, msg.Header.Get("Message-Id"), metadata.MessageIDHeader)
+	assert.Equal(t, senderEmailTest, metadata.From)
+	assert.Equal(t, []string{"a@test.com", "b@test.com"}, metadata.Recipients)
+	assert.Equal(t, "20241007T143000.000000000Z_sqs_123.eml", metadata.File)
+	assert.Equal(t, len(raw), metadata.Size)
+
+	// No deben quedar archivos temporales
+	tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.Empty(t, tmp)
+}
+
+func TestOutboxEmailServiceInvalidRecipient(t *testing.T) {
+	dir := t.TempDir()
+	service := NewOutboxEmailService(dir, NewComposer())
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "no-es-un-correo", testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files)
+}
+
+func TestOutboxEmailServiceUnwritableDir(t *testing.T) {
+	service := NewOutboxEmailService(filepath.Join(t.TempDir(), "no", "existe"), NewComposer())
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "outbox")
+}
+
+func TestNewEmailServiceFromEnvOutboxSkipsSandbox(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	t.Setenv("EMAIL_PROVIDERS", "outbox")
+	t.Setenv("OUTBOX_DIR", dir)
+	t.Setenv("APP_ENV", "local")
+	t.Setenv("SANDBOX_ENVS", "")
+	t.Setenv("SANDBOX_RECIPIENT", "")
+
+	emailService, err := NewEmailServiceFromEnv(new(MockSecretService), nil, testMessageID)
+
+	assert.NoError(t, err)
+	failover, ok := emailService.(*FailoverEmailService)
+	if !ok {
+		t.Fatalf("Se esperaba un FailoverEmailService con solo el outbox, se obtuvo %T", emailService)
+	}
+	assert.Equal(t, ProviderOutbox, failover.providers[0].Name)
+	assert.DirExists(t, dir)
+
+	err = emailService.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+	assert.NoError(t, err)
+	raw, _ := readOutbox(t, dir)
+	assert.Contains(t, string(raw), recipientEmailTest)
+}
+
+func TestProvidersFromEnv(t *testing.T) {
+	t.Setenv("EMAIL_PROVIDERS", "")
+	assert.Equal(t, []string{ProviderSMTP}, ProvidersFromEnv())
+
+	t.Setenv("EMAIL_PROVIDERS", " SES, ,outbox ")
+	assert.Equal(t, []string{ProviderSES, ProviderOutbox}, ProvidersFromEnv())
+}
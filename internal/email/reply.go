@@ -0,0 +1,224 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplyAction es la política que se aplica a una respuesta de error del servidor SMTP.
+type ReplyAction string
+
+const (
+	// ActionRetry reintenta el envío con el retraso habitual de la cola.
+	ActionRetry ReplyAction = "retry"
+	// ActionDefer reintenta el envío con un retraso mayor (por ejemplo, ante límites de envío del servidor).
+	ActionDefer ReplyAction = "defer"
+	// ActionDrop descarta al destinatario sin reintentarlo; el resto del mensaje continúa.
+	ActionDrop ReplyAction = "drop"
+	// ActionFail marca el mensaje completo como fallido de forma permanente.
+	ActionFail ReplyAction = "fail"
+)
+
+// Etapas de la conversación SMTP en las que el servidor puede responder con un error.
+const (
+	StageConnect  = "CONNECT"
+	StageStartTLS = "STARTTLS"
+	StageAuth     = "AUTH"
+	StageMail     = "MAIL"
+	StageRcpt     = "RCPT"
+	StageData     = "DATA"
+)
+
+// maxDeferDelay es el retraso máximo que admite SQS para un mensaje (15 minutos).
+const maxDeferDelay = 15 * time.Minute
+
+// SMTPError es una respuesta de error del servidor SMTP con sus códigos básico y extendido (RFC 3463).
+type SMTPError struct {
+	Stage        string
+	Code         int
+	EnhancedCode string
+	Message      string
+	Action       ReplyAction
+	// RetryAfter es el retraso sugerido para el reintento cuando Action es ActionDefer.
+	RetryAfter time.Duration
+}
+
+func (e *SMTPError) Error() string {
+	code := strconv.Itoa(e.Code)
+	if e.EnhancedCode != "" {
+		code += " " + e.EnhancedCode
+	}
+	return fmt.Sprintf("error SMTP en %s (%s): %s", e.Stage, code, e.Message)
+}
+
+// RetryDelay devuelve el retraso con el que debe reintentarse el mensaje, o 0 para usar el habitual.
+func (e *SMTPError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// enhancedCodePattern reconoce el código de estado extendido (RFC 3463) al inicio de la respuesta.
+var enhancedCodePattern = regexp.MustCompile(`^([245]\.\d{1,3}\.\d{1,3})\s*`)
+
+// splitEnhancedCode separa el código extendido del texto de la respuesta.
+func splitEnhancedCode(message string) (string, string) {
+	if match := enhancedCodePattern.FindStringSubmatch(message); match != nil {
+		return match[1], strings.TrimSpace(message[len(match[0]):])
+	}
+	return "", message
+}
+
+// replyError convierte la respuesta de error del servidor en un SMTPError de la etapa indicada. Los errores que no
+// son respuestas del servidor (por ejemplo, de conexión) se devuelven sin cambios.
+func replyError(stage string, err error) error {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return err
+	}
+	enhanced, message := splitEnhancedCode(protoErr.Msg)
+	return &SMTPError{Stage: stage, Code: protoErr.Code, EnhancedCode: enhanced, Message: message}
+}
+
+// ReplyPolicy asigna una acción a cada respuesta de error según su código. Se busca primero el código extendido
+// (por ejemplo, "5.1.1"), luego el básico ("550") y por último la clase ("5xx").
+type ReplyPolicy struct {
+	actions    map[string]ReplyAction
+	deferDelay time.Duration
+}
+
+// defaultReplyActions son las políticas por defecto. Los buzones o dominios inexistentes se descartan, las
+// respuestas de límite de envío o buzón lleno se difieren y el resto sigue la clase de la respuesta.
+var defaultReplyActions = map[string]ReplyAction{
+	"4xx":    ActionRetry,
+	"5xx":    ActionDrop,
+	"421":    ActionDefer,
+	"450":    ActionDefer,
+	"452":    ActionDefer,
+	"552":    ActionDefer,
+	"4.7.0":  ActionDefer,
+	"4.2.2":  ActionDefer,
+	"5.2.2":  ActionDefer,
+	"5.1.1":  ActionDrop,
+	"5.1.2":  ActionDrop,
+	"5.1.10": ActionDrop,
+}
+
+// NewReplyPolicy crea una política con los valores por defecto, reemplazados por overrides.
+func NewReplyPolicy(overrides map[string]ReplyAction, deferDelay time.Duration) *ReplyPolicy {
+	actions := make(map[string]ReplyAction, len(defaultReplyActions)+len(overrides))
+	for code, action := range defaultReplyActions {
+		actions[code] = action
+	}
+	for code, action := range overrides {
+		actions[code] = action
+	}
+	return &ReplyPolicy{actions: actions, deferDelay: deferDelay}
+}
+
+// ReplyPolicyFromEnv lee SMTP_REPLY_POLICIES (pares código=acción separados por comas, por ejemplo
+// "550=fail,4.7.1=defer") y SMTP_DEFER_DELAY (segundos, por defecto 900).
+func ReplyPolicyFromEnv() (*ReplyPolicy, error) {
+	overrides, err := parseReplyActions(os.Getenv("SMTP_REPLY_POLICIES"))
+	if err != nil {
+		return nil, err
+	}
+
+	deferDelay := maxDeferDelay
+	if raw := strings.TrimSpace(os.Getenv("SMTP_DEFER_DELAY")); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxDeferDelay {
+			return nil, fmt.Errorf("error: SMTP_DEFER_DELAY debe ser un número de segundos entre 0 y %d: %q",
+				int(maxDeferDelay.Seconds()), raw)
+		}
+		deferDelay = time.Duration(seconds) * time.Second
+	}
+	return NewReplyPolicy(overrides, deferDelay), nil
+}
+
+// replyCodePattern reconoce los códigos admitidos en la configuración: básico, extendido o clase.
+var replyCodePattern = regexp.MustCompile(`^([45]\d\d|[45]xx|[45]\.\d{1,3}\.\d{1,3})$`)
+
+func parseReplyActions(raw string) (map[string]ReplyAction, error) {
+	actions := map[string]ReplyAction{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		code, action, ok := strings.Cut(entry, "=")
+		code = strings.ToLower(strings.TrimSpace(code))
+		action = strings.ToLower(strings.TrimSpace(action))
+		if !ok || !replyCodePattern.MatchString(code) {
+			return nil, fmt.Errorf("error: política SMTP inválida en SMTP_REPLY_POLICIES: %q", entry)
+		}
+		switch ReplyAction(action) {
+		case ActionRetry, ActionDefer, ActionDrop, ActionFail:
+			actions[code] = ReplyAction(action)
+		default:
+			return nil, fmt.Errorf("error: acción SMTP %q no soportada (retry, defer, drop o fail)", action)
+		}
+	}
+	return actions, nil
+}
+
+// Action devuelve la acción configurada para la respuesta.
+func (p *ReplyPolicy) Action(code int, enhancedCode string) ReplyAction {
+	basic := strconv.Itoa(code)
+	for _, key := range []string{enhancedCode, basic, basic[:1] + "xx"} {
+		if action, ok := p.actions[key]; ok && key != "" {
+			return action
+		}
+	}
+	if code >= 400 && code < 500 {
+		return ActionRetry
+	}
+	return ActionFail
+}
+
+// retryAfter devuelve el retraso del reintento para la acción.
+func (p *ReplyPolicy) retryAfter(action ReplyAction) time.Duration {
+	if action == ActionDefer {
+		return p.deferDelay
+	}
+	return 0
+}
+
+// classifyResults aplica la política a los destinatarios no aceptados: retry y defer los dejan diferidos, drop
+// y fail rechazados.
+func (p *ReplyPolicy) classifyResults(results []RecipientResult) {
+	for i := range results {
+		if results[i].Status == RecipientAccepted {
+			continue
+		}
+		action := p.Action(results[i].Code, results[i].EnhancedCode)
+		results[i].Action = action
+		if action == ActionRetry || action == ActionDefer {
+			results[i].Status = RecipientDeferred
+		} else {
+			results[i].Status = RecipientRejected
+		}
+	}
+}
+
+// classifyError aplica la política a un error de la conversación SMTP. Las respuestas a MAIL, RCPT y DATA con
+// acción drop o fail afectan a todos los destinatarios y se marcan como permanentes; las de las etapas de sesión
+// (conexión, STARTTLS, AUTH) no dependen del mensaje y siempre se reintentan, de modo que pueda probarse otro
+// proveedor.
+func (p *ReplyPolicy) classifyError(smtpErr *SMTPError) (permanent bool) {
+	action := p.Action(smtpErr.Code, smtpErr.EnhancedCode)
+	switch smtpErr.Stage {
+	case StageMail, StageRcpt, StageData:
+	default:
+		if action == ActionDrop || action == ActionFail {
+			action = ActionRetry
+		}
+	}
+	smtpErr.Action = action
+	smtpErr.RetryAfter = p.retryAfter(action)
+	return action == ActionDrop || action == ActionFail
+}
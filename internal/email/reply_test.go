@@ -0,0 +1,107 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyPolicyDefaultActions(t *testing.T) {
+	policy := NewReplyPolicy(nil, maxDeferDelay)
+
+	tests := []struct {
+		code     int
+		enhanced string
+		want     ReplyAction
+	}{
+		{550, "5.1.1", ActionDrop},
+		{550, "5.7.1", ActionDrop},
+		{421, "4.7.0", ActionDefer},
+		{421, "", ActionDefer},
+		{451, "4.3.0", ActionRetry},
+		{452, "4.2.2", ActionDefer},
+		{552, "", ActionDefer},
+		{554, "", ActionDrop},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.code, tt.enhanced), func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.Action(tt.code, tt.enhanced))
+		})
+	}
+}
+
+func TestReplyPolicyFromEnvOverrides(t *testing.T) {
+	t.Setenv("SMTP_REPLY_POLICIES", " 5.7.1=FAIL, 451=defer ,4xx=drop")
+	t.Setenv("SMTP_DEFER_DELAY", "300")
+
+	policy, err := ReplyPolicyFromEnv()
+
+	assert.NoError(t, err)
+	// El código extendido tiene prioridad sobre el básico y éste sobre la clase
+	assert.Equal(t, ActionFail, policy.Action(550, "5.7.1"))
+	assert.Equal(t, ActionDrop, policy.Action(550, "5.1.1"))
+	assert.Equal(t, ActionDefer, policy.Action(451, "4.3.0"))
+	assert.Equal(t, ActionDrop, policy.Action(454, ""))
+	assert.Equal(t, ActionDefer, policy.Action(421, "4.7.0"))
+	assert.Equal(t, 5*time.Minute, policy.retryAfter(ActionDefer))
+	assert.Zero(t, policy.retryAfter(ActionRetry))
+}
+
+func TestReplyPolicyFromEnvInvalid(t *testing.T) {
+	for _, tt := range []struct{ policies, delay, want string }{
+		{"550=ignore", "", "ignore"},
+		{"550", "", "550"},
+		{"250=drop", "", "250"},
+		{"", "3600", "SMTP_DEFER_DELAY"},
+		{"", "abc", "SMTP_DEFER_DELAY"},
+	} {
+		t.Setenv("SMTP_REPLY_POLICIES", tt.policies)
+		t.Setenv("SMTP_DEFER_DELAY", tt.delay)
+
+		_, err := ReplyPolicyFromEnv()
+
+		if err == nil {
+			t.Fatalf("Se esperaba un error para %q / %q", tt.policies, tt.delay)
+		}
+		assert.Contains(t, err.Error(), tt.want)
+	}
+}
+
+func TestReplyErrorParsesCodes(t *testing.T) {
+	err := replyError(StageMail, &textproto.Error{Code: 550, Msg: "5.1.8 Bad sender address"})
+
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) {
+		t.Fatalf("Se esperaba un SMTPError, se obtuvo %v", err)
+	}
+	assert.Equal(t, StageMail, smtpErr.Stage)
+	assert.Equal(t, 550, smtpErr.Code)
+	assert.Equal(t, "5.1.8", smtpErr.EnhancedCode)
+	assert.Equal(t, "Bad sender address", smtpErr.Message)
+	assert.Equal(t, "error SMTP en MAIL (550 5.1.8): Bad sender address", smtpErr.Error())
+
+	// Los errores que no son respuestas del servidor no se modifican
+	connErr := errors.New("connection refused")
+	assert.Equal(t, connErr, replyError(StageConnect, connErr))
+}
+
+func TestReplyPolicyClassifyErrorSessionStagesAreRetried(t *testing.T) {
+	policy := NewReplyPolicy(nil, maxDeferDelay)
+
+	authErr := &SMTPError{Stage: StageAuth, Code: 535, EnhancedCode: "5.7.8"}
+	assert.False(t, policy.classifyError(authErr))
+	assert.Equal(t, ActionRetry, authErr.Action)
+
+	busyErr := &SMTPError{Stage: StageConnect, Code: 421, EnhancedCode: "4.7.0"}
+	assert.False(t, policy.classifyError(busyErr))
+	assert.Equal(t, ActionDefer, busyErr.Action)
+	assert.Equal(t, maxDeferDelay, busyErr.RetryDelay())
+
+	senderErr := &SMTPError{Stage: StageMail, Code: 550, EnhancedCode: "5.1.8"}
+	assert.True(t, policy.classifyError(senderErr))
+	assert.Equal(t, ActionDrop, senderErr.Action)
+}
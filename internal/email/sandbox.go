@@ -0,0 +1,130 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"html"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+// defaultSandboxEnvironments son los valores de APP_ENV en los que se activa el modo sandbox si no se
+// define SANDBOX_ENVS.
+const defaultSandboxEnvironments = "local,dev,development,qa,test"
+
+// SandboxEmailService redirige todos los destinatarios a una dirección de pruebas antes de delegar en el
+// servicio real, de modo que en ambientes no productivos no se envíen correos a buzones reales. Como
+// reemplaza la lista completa de destinatarios (To, CC y BCC) aplica a cualquier backend.
+type SandboxEmailService struct {
+	next        EmailServiceInterface
+	environment string
+	recipient   string
+}
+
+// NewSandboxEmailService crea el decorador para el ambiente y la dirección de pruebas indicados.
+func NewSandboxEmailService(next EmailServiceInterface, environment, recipient string) *SandboxEmailService {
+	return &SandboxEmailService{
+		next:        next,
+		environment: strings.ToUpper(environment),
+		recipient:   strings.TrimSpace(recipient),
+	}
+}
+
+// sandboxEnvironmentFromEnv indica si APP_ENV corresponde a un ambiente sandbox (SANDBOX_ENVS).
+func sandboxEnvironmentFromEnv() (string, bool) {
+	appEnv := strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV")))
+	environments := os.Getenv("SANDBOX_ENVS")
+	if environments == "" {
+		environments = defaultSandboxEnvironments
+	}
+
+	for _, environment := range strings.Split(environments, ",") {
+		if appEnv != "" && appEnv == strings.ToLower(strings.TrimSpace(environment)) {
+			return appEnv, true
+		}
+	}
+	return appEnv, false
+}
+
+// SendEmail reemplaza los destinatarios por la dirección de pruebas, antepone el ambiente al asunto y agrega
+// un aviso visible con los destinatarios originales.
+func (s *SandboxEmailService) SendEmail(
+	ctx context.Context, remitente, destinatarios, asunto, cuerpo, messageID string) error {
+	// Sin dirección de pruebas no se envía nada: es preferible fallar a entregar a buzones reales
+	if s.recipient == "" {
+		return models.NewPermanentError(errors.New(
+			"error: modo sandbox activo sin SANDBOX_RECIPIENT configurado, el correo no se enviará"))
+	}
+
+	original, err := ParseAddressList(destinatarios)
+	if err != nil {
+		logs.LogError("Destinatarios inválidos", err, messageID)
+		return err
+	}
+
+	logs.LogInfo(fmt.Sprintf("Modo sandbox (%s): destinatarios %s redirigidos a %s",
+		s.environment, strings.Join(addressStrings(original), ", "), s.recipient), messageID)
+
+	err = s.next.SendEmail(
+		ctx,
+		remitente,
+		s.recipient,
+		fmt.Sprintf("[%s] %s", s.environment, asunto),
+		sandboxBanner(s.environment, original)+cuerpo,
+		messageID,
+	)
+	return s.originalRecipients(err, original)
+}
+
+// originalRecipients traduce el resultado de la dirección de pruebas a los destinatarios originales, a los que
+// representa. Así un reintento de los destinatarios diferidos se hace sobre los de la plantilla y no sobre
+// SANDBOX_RECIPIENT, que la plantilla no contiene.
+func (s *SandboxEmailService) originalRecipients(err error, original []*mail.Address) error {
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		return err
+	}
+
+	results := make([]RecipientResult, 0, len(deliveryErr.Results))
+	for _, result := range deliveryErr.Results {
+		if !strings.EqualFold(result.Address, s.recipient) {
+			results = append(results, result)
+			continue
+		}
+		for _, address := range original {
+			mapped := result
+			mapped.Address = address.Address
+			results = append(results, mapped)
+		}
+	}
+	// Se modifica el error original para conservar lo que lo envuelve (por ejemplo, un error permanente)
+	deliveryErr.Results = results
+	return err
+}
+
+// sandboxBanner construye el aviso HTML que se antepone al cuerpo del correo.
+func sandboxBanner(environment string, original []*mail.Address) string {
+	return fmt.Sprintf(
+		`<div style="border:2px dashed #c0392b;background:#fdecea;color:#c0392b;padding:8px;margin-bottom:12px;`+
+			`font-family:monospace">Correo de prueba (%s). Destinatarios originales: %s</div>`,
+		html.EscapeString(environment),
+		html.EscapeString(strings.Join(displayAddresses(original), ", ")),
+	)
+}
+
+// displayAddresses formatea las direcciones para mostrarlas en el cuerpo (sin codificación MIME).
+func displayAddresses(addresses []*mail.Address) []string {
+	out := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if address.Name == "" {
+			out = append(out, address.Address)
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s <%s>", address.Name, address.Address))
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"gmf_message_processor/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sandboxRecipientTest = "qa-inbox@test.com"
+
+func TestSandboxEmailServiceRedirectsRecipients(t *testing.T) {
+	next := new(MockEmailProvider)
+	next.On("SendEmail", mock.Anything, senderEmailTest, sandboxRecipientTest, "[QA] "+testSubject,
+		mock.MatchedBy(func(cuerpo string) bool {
+			return strings.HasPrefix(cuerpo, "<div") &&
+				strings.Contains(cuerpo, "Correo de prueba (QA)") &&
+				strings.Contains(cuerpo, "Ana &lt;Pérez&gt; &lt;ana@test.com&gt;, B@test.com") &&
+				strings.HasSuffix(cuerpo, testBody)
+		}),
+		testMessageID,
+	).Return(nil)
+
+	service := NewSandboxEmailService(next, "qa", sandboxRecipientTest)
+	err := service.SendEmail(context.Background(), senderEmailTest, `"Ana <Pérez>" <ana@test.com>; B@Test.com`,
+		testSubject, testBody, testMessageID)
+
+	assert.NoError(t, err)
+	next.AssertExpectations(t)
+}
+
+func TestSandboxEmailServiceWithoutRecipientDoesNotSend(t *testing.T) {
+	next := new(MockEmailProvider)
+	service := NewSandboxEmailService(next, "dev", "")
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "SANDBOX_RECIPIENT")
+	next.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything)
+}
+
+func TestSandboxEnvironmentFromEnv(t *testing.T) {
+	t.Setenv("SANDBOX_ENVS", "")
+
+	t.Setenv("APP_ENV", "QA")
+	environment, ok := sandboxEnvironmentFromEnv()
+	assert.True(t, ok)
+	assert.Equal(t, "qa", environment)
+
+	t.Setenv("APP_ENV", "production")
+	_, ok = sandboxEnvironmentFromEnv()
+	assert.False(t, ok)
+
+	t.Setenv("APP_ENV", "")
+	_, ok = sandboxEnvironmentFromEnv()
+	assert.False(t, ok)
+
+	t.Setenv("SANDBOX_ENVS", "staging")
+	t.Setenv("APP_ENV", "staging")
+	_, ok = sandboxEnvironmentFromEnv()
+	assert.True(t, ok)
+}
+
+func TestNewEmailServiceFromEnvWrapsSandbox(t *testing.T) {
+	mockSecretService := new(MockSecretService)
+	t.Setenv("EMAIL_PROVIDERS", "smtp")
+	t.Setenv("SECRETS_SMTP", secretName)
+	t.Setenv("SMTP_SERVER", smtpServerTest)
+	t.Setenv("SMTP_PORT", "587")
+	t.Setenv("APP_ENV", "dev")
+	t.Setenv("SANDBOX_ENVS", "")
+	t.Setenv("SANDBOX_RECIPIENT", sandboxRecipientTest)
+	mockSecretService.On("GetSecret", secretName, testMessageID).Return(testSMTPSecret(), nil)
+
+	emailService, err := NewEmailServiceFromEnv(mockSecretService, nil, testMessageID)
+
+	assert.NoError(t, err)
+	sandbox, ok := emailService.(*SandboxEmailService)
+	if !ok {
+		t.Fatalf("Se esperaba un SandboxEmailService en APP_ENV=dev, se obtuvo %T", emailService)
+	}
+	assert.Equal(t, "DEV", sandbox.environment)
+	assert.Equal(t, sandboxRecipientTest, sandbox.recipient)
+	assert.IsType(t, &FailoverEmailService{}, sandbox.next)
+}
+
+func TestSandboxEmailServiceReportsOriginalRecipients(t *testing.T) {
+	next := new(MockEmailProvider)
+	next.On("SendEmail", mock.Anything, senderEmailTest, sandboxRecipientTest, mock.Anything, mock.Anything,
+		testMessageID).
+		Return(&DeliveryError{Results: []RecipientResult{
+			{Address: sandboxRecipientTest, Status: RecipientDeferred, Code: 451},
+		}, RetryAfter: time.Minute})
+
+	service := NewSandboxEmailService(next, "qa", sandboxRecipientTest)
+	err := service.SendEmail(context.Background(), senderEmailTest, `"Ana" <ana@test.com>, b@test.com`,
+		testSubject, testBody, testMessageID)
+
+	var deliveryErr *DeliveryError
+	assert.True(t, errors.As(err, &deliveryErr))
+	assert.Equal(t, []string{"ana@test.com", "b@test.com"}, deliveryErr.DeferredRecipients())
+	assert.Equal(t, time.Minute, deliveryErr.RetryDelay())
+}
@@ -0,0 +1,156 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+	"github.com/aws/smithy-go"
+)
+
+// SESAPI define las operaciones de Amazon SES que utiliza el servicio de correo.
+type SESAPI interface {
+	SendRawEmail(
+		ctx context.Context,
+		input *ses.SendRawEmailInput,
+		opts ...func(*ses.Options)) (*ses.SendRawEmailOutput, error)
+}
+
+// sesPermanentErrorCodes son los códigos de error de SES que no se resuelven reintentando ni cambiando de proveedor.
+var sesPermanentErrorCodes = map[string]bool{
+	"MessageRejected":                    true,
+	"MailFromDomainNotVerifiedException": true,
+	"ConfigurationSetDoesNotExist":       true,
+	"InvalidParameterValue":              true,
+}
+
+// SESEmailService implementa EmailService utilizando Amazon SES (SendRawEmail).
+type SESEmailService struct {
+	client           SESAPI
+	composer         *Composer
+	configurationSet string
+	timeout          time.Duration
+}
+
+// NewSESEmailService crea el servicio SES con la región AWS_REGION y, si se define, el endpoint SES_ENDPOINT.
+func NewSESEmailService(
+	secretService connection.SecretService, messageID string, opts ...ComposerOption) (*SESEmailService, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1" // Región por defecto
+	}
+
+	options := []func(*awsConfig.LoadOptions) error{awsConfig.WithRegion(region)}
+	if endpoint := os.Getenv("SES_ENDPOINT"); endpoint != "" {
+		options = append(options, awsConfig.WithEndpointResolver(
+			aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
+				return aws.Endpoint{URL: endpoint, SigningRegion: region}, nil
+			}),
+		))
+	}
+
+	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(), options...)
+	if err != nil {
+		logs.LogError("Error cargando la configuración de AWS para SES", err, messageID)
+		return nil, fmt.Errorf("unable to load AWS SDK config: %v", err)
+	}
+
+	composer, err := NewComposerFromEnv(secretService, messageID, opts...)
+	if err != nil {
+		logs.LogError("Error inicializando la composición de mensajes", err, messageID)
+		return nil, err
+	}
+
+	return &SESEmailService{
+		client:           ses.NewFromConfig(cfg),
+		composer:         composer,
+		configurationSet: os.Getenv("SES_CONFIGURATION_SET"),
+		timeout:          timeoutFromEnv("SES_TIMEOUT"),
+	}, nil
+}
+
+// SendEmail compone el mensaje y lo entrega a SES como mensaje MIME sin procesar.
+func (s *SESEmailService) SendEmail(ctx context.Context, remitente, destinatarios, asunto, cuerpo,
+	messageID string) error {
+	_, err := s.SendEmailWithID(ctx, remitente, destinatarios, asunto, cuerpo, messageID)
+	return err
+}
+
+// SendEmailWithID es como SendEmail y además devuelve el MessageId que SES asignó al correo, con el que SES reporta
+// después las entregas, rebotes y quejas.
+func (s *SESEmailService) SendEmailWithID(ctx context.Context, remitente, destinatarios, asunto, cuerpo,
+	messageID string) (string, error) {
+	from, err := ParseSender(remitente)
+	if err != nil {
+		logs.LogError("Remitente inválido", err, messageID)
+		return "", err
+	}
+	to, err := ParseAddressList(destinatarios)
+	if err != nil {
+		logs.LogError("Destinatarios inválidos", err, messageID)
+		return "", err
+	}
+
+	message := &Message{
+		From:      from,
+		To:        to,
+		Subject:   asunto,
+		HTMLBody:  cuerpo,
+		MessageID: messageID,
+		SMIME:     SMIMEFromContext(ctx),
+	}
+	raw, err := s.composer.Compose(message, messageID)
+	if err != nil {
+		return "", err
+	}
+
+	input := &ses.SendRawEmailInput{
+		Source:       aws.String(from.Address),
+		Destinations: message.Recipients(),
+		RawMessage:   &types.RawMessage{Data: raw},
+	}
+	if s.configurationSet != "" {
+		input.ConfigurationSetName = aws.String(s.configurationSet)
+	}
+
+	logs.LogInfo("Inicia consumo de Amazon SES para envío de correo", messageID)
+	startTime := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	output, err := s.client.SendRawEmail(ctx, input)
+
+	duration := time.Since(startTime).Milliseconds()
+	if err != nil {
+		logs.LogError(fmt.Sprintf(
+			"Fin consumo de Amazon SES para envío de correo, duración %d ms", duration), err, messageID)
+		return "", classifySESError(err)
+	}
+
+	sesMessageID := aws.ToString(output.MessageId)
+	logs.LogInfo(fmt.Sprintf(
+		"Fin consumo de Amazon SES para envío de correo, duración %d ms, SES MessageId: %s",
+		duration, sesMessageID),
+		messageID,
+	)
+	return sesMessageID, nil
+}
+
+// classifySESError marca como permanentes los rechazos de SES que no dependen de la disponibilidad del servicio; el
+// resto son fallos de transporte.
+func classifySESError(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && sesPermanentErrorCodes[apiErr.ErrorCode()] {
+		return models.NewPermanentError(fmt.Errorf("error: SES rechazó el mensaje: %w", err))
+	}
+	return newTransportError(fmt.Errorf("error enviando el correo electrónico por SES: %w", err))
+}
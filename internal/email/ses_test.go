@@ -0,0 +1,109 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/models"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock del cliente de SES.
+type MockSESClient struct {
+	mock.Mock
+}
+
+func (m *MockSESClient) SendRawEmail(
+	ctx context.Context,
+	input *ses.SendRawEmailInput,
+	opts ...func(*ses.Options)) (*ses.SendRawEmailOutput, error) {
+	args := m.Called(ctx, input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ses.SendRawEmailOutput), args.Error(1)
+}
+
+// testSMTPSecret devuelve credenciales SMTP válidas para las pruebas.
+func testSMTPSecret() *connection.SecretData {
+	return &connection.SecretData{Username: "user", Password: "pass"}
+}
+
+func TestSESEmailServiceSendEmailSuccess(t *testing.T) {
+	client := new(MockSESClient)
+	client.On("SendRawEmail", mock.Anything, mock.MatchedBy(func(input *ses.SendRawEmailInput) bool {
+		return aws.ToString(input.Source) == senderEmailTest &&
+			assert.ObjectsAreEqual([]string{"a@test.com", "b@test.com"}, input.Destinations) &&
+			aws.ToString(input.ConfigurationSetName) == "eventos"
+	})).Return(&ses.SendRawEmailOutput{MessageId: aws.String("ses-1")}, nil)
+
+	service := &SESEmailService{client: client, configurationSet: "eventos", timeout: 10 * time.Second}
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com, b@test.com", testSubject, testBody,
+		testMessageID)
+
+	assert.NoError(t, err)
+	client.AssertExpectations(t)
+	input := client.Calls[0].Arguments.Get(1).(*ses.SendRawEmailInput)
+	assert.Contains(t, string(input.RawMessage.Data), "Subject: Test Subject")
+}
+
+func TestSESEmailServiceSendEmailWithIDReturnsSESMessageID(t *testing.T) {
+	client := new(MockSESClient)
+	client.On("SendRawEmail", mock.Anything, mock.Anything).
+		Return(&ses.SendRawEmailOutput{MessageId: aws.String("ses-1")}, nil)
+
+	service := &SESEmailService{client: client, timeout: 10 * time.Second}
+
+	sesMessageID, err := service.SendEmailWithID(context.Background(), senderEmailTest, "a@test.com", testSubject,
+		testBody, testMessageID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ses-1", sesMessageID)
+}
+
+func TestSESEmailServiceSendEmailRejectedIsPermanent(t *testing.T) {
+	client := new(MockSESClient)
+	client.On("SendRawEmail", mock.Anything, mock.Anything).
+		Return(nil, &types.MessageRejected{Message: aws.String("Email address is not verified")})
+
+	service := &SESEmailService{client: client, timeout: 10 * time.Second}
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "SES rechazó el mensaje")
+}
+
+func TestSESEmailServiceSendEmailTransientError(t *testing.T) {
+	client := new(MockSESClient)
+	client.On("SendRawEmail", mock.Anything, mock.Anything).
+		Return(nil, errors.New("Throttling: Maximum sending rate exceeded"))
+
+	service := &SESEmailService{client: client, timeout: 10 * time.Second}
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	assert.False(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "Throttling")
+}
+
+func TestSESEmailServiceSendEmailInvalidRecipients(t *testing.T) {
+	client := new(MockSESClient)
+	service := &SESEmailService{client: client, timeout: 10 * time.Second}
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "", testSubject, testBody, testMessageID)
+
+	assert.True(t, models.IsPermanentError(err))
+	client.AssertNotCalled(t, "SendRawEmail", mock.Anything, mock.Anything)
+}
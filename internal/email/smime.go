@@ -0,0 +1,520 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SMIMEOptions indica si el correo debe firmarse y/o cifrarse con S/MIME. Se configura por plantilla.
+type SMIMEOptions struct {
+	Sign    bool
+	Encrypt bool
+}
+
+type smimeContextKey struct{}
+
+// WithSMIME devuelve un contexto que indica a los proveedores de correo cómo proteger el mensaje con S/MIME.
+func WithSMIME(ctx context.Context, options SMIMEOptions) context.Context {
+	return context.WithValue(ctx, smimeContextKey{}, options)
+}
+
+// SMIMEFromContext devuelve las opciones S/MIME del contexto (sin firma ni cifrado si no se configuraron).
+func SMIMEFromContext(ctx context.Context) SMIMEOptions {
+	options, _ := ctx.Value(smimeContextKey{}).(SMIMEOptions)
+	return options
+}
+
+// CertificateSource obtiene el certificado S/MIME de un destinatario. Devuelve nil si no existe.
+type CertificateSource interface {
+	FindCertificate(direccion string) (*models.CertificadoCorreo, error)
+}
+
+// SMIMESigner contiene el certificado y la clave con los que se firman los correos.
+type SMIMESigner struct {
+	certificate *x509.Certificate
+	// chain son los certificados intermedios que se incluyen en la firma.
+	chain []*x509.Certificate
+	key   crypto.Signer
+	now   func() time.Time
+}
+
+// NewSMIMESignerFromEnv carga el certificado de firma del secreto SECRETS_SMIME. Si la variable no está
+// configurada devuelve nil, nil y las plantillas que piden firma fallan de forma permanente.
+func NewSMIMESignerFromEnv(secretService connection.SecretService, messageID string) (*SMIMESigner, error) {
+	secretName := strings.TrimSpace(os.Getenv("SECRETS_SMIME"))
+	if secretName == "" {
+		return nil, nil
+	}
+
+	secretData, err := secretService.GetSecret(secretName, messageID)
+	if err != nil {
+		logs.LogError("Error al obtener el secreto S/MIME", err, messageID)
+		return nil, err
+	}
+	signer, err := NewSMIMESigner(secretData.SMIMECertificate, secretData.SMIMEPrivateKey)
+	if err != nil {
+		logs.LogError("Configuración S/MIME inválida", err, messageID)
+		return nil, err
+	}
+	return signer, nil
+}
+
+// NewSMIMESigner crea el firmante a partir del certificado PEM (el primero es el del firmante y los siguientes,
+// si los hay, la cadena) y la clave privada PEM (PKCS#1, PKCS#8 o SEC 1), RSA o ECDSA.
+func NewSMIMESigner(certificatePEM, privateKeyPEM string) (*SMIMESigner, error) {
+	if certificatePEM == "" || privateKeyPEM == "" {
+		return nil, errors.New("el secreto S/MIME debe contener SMIME_CERTIFICATE y SMIME_PRIVATE_KEY")
+	}
+
+	certificates, err := parseCertificatesPEM(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parseSMIMEPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKeyMatches(certificates[0].PublicKey, key.Public()) {
+		return nil, errors.New("la clave privada S/MIME no corresponde al certificado")
+	}
+
+	return &SMIMESigner{certificate: certificates[0], chain: certificates[1:], key: key, now: time.Now}, nil
+}
+
+// WithSMIMESigner configura el certificado con el que se firman los correos.
+func WithSMIMESigner(signer *SMIMESigner) ComposerOption {
+	return func(c *Composer) {
+		c.smimeSigner = signer
+	}
+}
+
+// WithCertificateSource configura la fuente de certificados de los destinatarios (por ejemplo, la tabla
+// cgd_correos_certificados).
+func WithCertificateSource(source CertificateSource) ComposerOption {
+	return func(c *Composer) {
+		c.certificates = source
+	}
+}
+
+// protectEntity firma y/o cifra la entidad MIME del cuerpo. Se firma antes de cifrar, de modo que la firma
+// también quede protegida. Una configuración faltante (sin certificado de firma o sin el certificado de algún
+// destinatario) es un error permanente: el correo no debe enviarse sin la protección que pide la plantilla.
+func (c *Composer) protectEntity(entity []byte, msg *Message, messageID string) ([]byte, error) {
+	var err error
+	if msg.SMIME.Sign {
+		if c == nil || c.smimeSigner == nil {
+			return nil, models.NewPermanentError(
+				errors.New("error: la plantilla requiere firma S/MIME y SECRETS_SMIME no está configurado"))
+		}
+		entity, err = c.smimeSigner.sign(entity)
+		if err != nil {
+			logs.LogError("Error firmando el mensaje con S/MIME", err, messageID)
+			return nil, err
+		}
+	}
+
+	if msg.SMIME.Encrypt {
+		recipients, err := c.recipientCertificates(msg, messageID)
+		if err != nil {
+			return nil, err
+		}
+		entity, err = encryptEntity(entity, recipients)
+		if err != nil {
+			logs.LogError("Error cifrando el mensaje con S/MIME", err, messageID)
+			return nil, err
+		}
+	}
+	return entity, nil
+}
+
+// recipientCertificates busca el certificado de cada destinatario (To, Cc y Bcc).
+func (c *Composer) recipientCertificates(msg *Message, messageID string) ([]*x509.Certificate, error) {
+	if c == nil || c.certificates == nil {
+		return nil, models.NewPermanentError(
+			errors.New("error: la plantilla requiere cifrado S/MIME y no hay fuente de certificados configurada"))
+	}
+
+	var certificates []*x509.Certificate
+	var missing []string
+	for _, address := range msg.Recipients() {
+		record, err := c.certificates.FindCertificate(address)
+		if err != nil {
+			logs.LogError(fmt.Sprintf("Error obteniendo el certificado S/MIME de %s", address), err, messageID)
+			return nil, err
+		}
+		if record == nil {
+			missing = append(missing, address)
+			continue
+		}
+
+		parsed, err := parseCertificatesPEM(record.Certificado)
+		if err != nil {
+			return nil, models.NewPermanentError(fmt.Errorf("error: certificado S/MIME inválido para %s: %w", address, err))
+		}
+		certificate := parsed[0]
+		if _, ok := certificate.PublicKey.(*rsa.PublicKey); !ok {
+			return nil, models.NewPermanentError(
+				fmt.Errorf("error: el certificado S/MIME de %s no es RSA (%T)", address, certificate.PublicKey))
+		}
+		if now := time.Now(); now.After(certificate.NotAfter) || now.Before(certificate.NotBefore) {
+			return nil, models.NewPermanentError(fmt.Errorf("error: el certificado S/MIME de %s no está vigente", address))
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	if len(missing) > 0 {
+		return nil, models.NewPermanentError(
+			fmt.Errorf("error: no hay certificado S/MIME para los destinatarios: %s", strings.Join(missing, ", ")))
+	}
+	return certificates, nil
+}
+
+// Identificadores de objeto de CMS (RFC 5652) y de los algoritmos utilizados.
+var (
+	oidData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidAES256CBC              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// contentInfo.Content es el contenido etiquetado como [0] EXPLICIT.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// encapsulatedContentInfo no incluye el contenido: la firma es separada (multipart/signed).
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []keyTransRecipientInfo `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type keyTransRecipientInfo struct {
+	Version                int
+	RID                    issuerAndSerialNumber
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"optional,tag:0"`
+}
+
+// sign envuelve la entidad en multipart/signed (RFC 8551) con una firma CMS separada en SHA-256. La entidad se
+// escribe sin modificar como primera parte, ya que la firma se calcula sobre sus bytes exactos.
+func (s *SMIMESigner) sign(entity []byte) ([]byte, error) {
+	signature, err := s.detachedSignature(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	var out bytes.Buffer
+	writeHeader(&out, "Content-Type", mime.FormatMediaType("multipart/signed", map[string]string{
+		"protocol": "application/pkcs7-signature",
+		"micalg":   "sha-256",
+		"boundary": boundary,
+	}))
+	out.WriteString("\r\n--" + boundary + "\r\n")
+	out.Write(entity)
+	out.WriteString("\r\n--" + boundary + "\r\n")
+	writeHeader(&out, "Content-Type", `application/pkcs7-signature; name="smime.p7s"`)
+	writeHeader(&out, "Content-Transfer-Encoding", "base64")
+	writeHeader(&out, "Content-Disposition", `attachment; filename="smime.p7s"`)
+	out.WriteString("\r\n")
+	if err := writeBase64Lines(&out, signature); err != nil {
+		return nil, err
+	}
+	out.WriteString("--" + boundary + "--\r\n")
+	return out.Bytes(), nil
+}
+
+// detachedSignature genera el SignedData de CMS para el contenido, con los atributos firmados contentType,
+// messageDigest y signingTime.
+func (s *SMIMESigner) detachedSignature(content []byte) ([]byte, error) {
+	digest := sha256.Sum256(content)
+	attributes, err := marshalSignedAttributes(
+		attributeValue{oidAttributeContentType, oidData},
+		attributeValue{oidAttributeMessageDigest, digest[:]},
+		attributeValue{oidAttributeSigningTime, s.now().UTC()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// La firma se calcula sobre los atributos codificados como SET OF (RFC 5652, sección 5.4)
+	attributesDigest := sha256.Sum256(append([]byte{0x31}, attributes.FullBytes[1:]...))
+	signature, err := s.key.Sign(rand.Reader, attributesDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("error firmando con la clave S/MIME: %w", err)
+	}
+
+	signatureAlgorithm := pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	if _, ok := s.key.(*ecdsa.PrivateKey); ok {
+		signatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	}
+
+	var certificates []byte
+	for _, certificate := range append([]*x509.Certificate{s.certificate}, s.chain...) {
+		certificates = append(certificates, certificate.Raw...)
+	}
+
+	sha256Algorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	signed := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Algorithm},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certificates,
+		},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                issuerAndSerial(s.certificate),
+			DigestAlgorithm:    sha256Algorithm,
+			SignedAttrs:        attributes,
+			SignatureAlgorithm: signatureAlgorithm,
+			Signature:          signature,
+		}},
+	}
+	return marshalContentInfo(oidSignedData, signed)
+}
+
+type attributeValue struct {
+	oid   asn1.ObjectIdentifier
+	value interface{}
+}
+
+// marshalSignedAttributes codifica los atributos como [0] IMPLICIT SET OF Attribute, ordenados según DER.
+func marshalSignedAttributes(values ...attributeValue) (asn1.RawValue, error) {
+	encoded := make([][]byte, 0, len(values))
+	for _, v := range values {
+		value, err := asn1.Marshal(v.value)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		attr, err := asn1.Marshal(attribute{
+			Type:   v.oid,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		encoded = append(encoded, attr)
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+
+	raw := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bytes.Join(encoded, nil)}
+	fullBytes, err := asn1.Marshal(raw)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	raw.FullBytes = fullBytes
+	return raw, nil
+}
+
+// encryptEntity cifra la entidad con AES-256-CBC y una clave de contenido transportada con RSA para cada
+// destinatario (EnvelopedData de CMS), y la devuelve como application/pkcs7-mime.
+func encryptEntity(entity []byte, recipients []*x509.Certificate) ([]byte, error) {
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(entity)%aes.BlockSize
+	plaintext := append(append([]byte{}, entity...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	ivParameter, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	enveloped := envelopedData{
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType: oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParameter},
+			},
+			EncryptedContent: ciphertext,
+		},
+	}
+	for _, certificate := range recipients {
+		encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, certificate.PublicKey.(*rsa.PublicKey), key)
+		if err != nil {
+			return nil, fmt.Errorf("error cifrando la clave de contenido: %w", err)
+		}
+		enveloped.RecipientInfos = append(enveloped.RecipientInfos, keyTransRecipientInfo{
+			RID:                    issuerAndSerial(certificate),
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		})
+	}
+
+	der, err := marshalContentInfo(oidEnvelopedData, enveloped)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	writeHeader(&out, "Content-Type", `application/pkcs7-mime; smime-type=enveloped-data; name="smime.p7m"`)
+	writeHeader(&out, "Content-Transfer-Encoding", "base64")
+	writeHeader(&out, "Content-Disposition", `attachment; filename="smime.p7m"`)
+	out.WriteString("\r\n")
+	if err := writeBase64Lines(&out, der); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func marshalContentInfo(contentType asn1.ObjectIdentifier, content interface{}) ([]byte, error) {
+	inner, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner},
+	})
+}
+
+func issuerAndSerial(certificate *x509.Certificate) issuerAndSerialNumber {
+	return issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: certificate.RawIssuer},
+		SerialNumber: certificate.SerialNumber,
+	}
+}
+
+// parseCertificatesPEM interpreta uno o más certificados PEM.
+func parseCertificatesPEM(data string) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error interpretando el certificado S/MIME: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("el certificado S/MIME no está en formato PEM")
+	}
+	return certificates, nil
+}
+
+// parseSMIMEPrivateKey interpreta una clave privada PEM (PKCS#1, PKCS#8 o SEC 1) RSA o ECDSA.
+func parseSMIMEPrivateKey(pemKey string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("la clave privada S/MIME no está en formato PEM")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error interpretando la clave privada S/MIME: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("tipo de clave S/MIME no soportado: %T", key)
+	}
+}
+
+func publicKeyMatches(certificateKey, privateKeyPublic crypto.PublicKey) bool {
+	key, ok := certificateKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(privateKeyPublic)
+}
@@ -0,0 +1,389 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/models"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockCertificateSource devuelve los certificados PEM configurados en memoria.
+type mockCertificateSource map[string]string
+
+func (m mockCertificateSource) FindCertificate(direccion string) (*models.CertificadoCorreo, error) {
+	certificate, ok := m[direccion]
+	if !ok {
+		return nil, nil
+	}
+	return &models.CertificadoCorreo{Direccion: direccion, Certificado: certificate}, nil
+}
+
+// testCertificate es un certificado autofirmado generado para las pruebas.
+type testCertificate struct {
+	certificatePEM string
+	keyPEM         string
+	key            crypto.Signer
+	certificate    *x509.Certificate
+}
+
+func newTestCertificate(t *testing.T, address string, key crypto.Signer) *testCertificate {
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: address},
+		EmailAddresses: []string{address},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Error creando el certificado de prueba: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error interpretando el certificado de prueba: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error codificando la clave de prueba: %v", err)
+	}
+
+	return &testCertificate{
+		certificatePEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		keyPEM:         string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		key:            key,
+		certificate:    certificate,
+	}
+}
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generando la clave RSA de prueba: %v", err)
+	}
+	return key
+}
+
+func newSMIMETestComposer(t *testing.T, signer *testCertificate, certificates mockCertificateSource) *Composer {
+	composer := NewComposer()
+	if signer != nil {
+		smimeSigner, err := NewSMIMESigner(signer.certificatePEM, signer.keyPEM)
+		if err != nil {
+			t.Fatalf("Error creando el firmante S/MIME: %v", err)
+		}
+		WithSMIMESigner(smimeSigner)(composer)
+	}
+	WithCertificateSource(certificates)(composer)
+	return composer
+}
+
+// splitTestMessage separa los encabezados del mensaje compuesto de la entidad MIME del cuerpo (que comienza en
+// Content-Type).
+func splitTestMessage(t *testing.T, raw []byte) (string, []byte) {
+	index := bytes.Index(raw, []byte("\r\nContent-Type:"))
+	if index < 0 {
+		t.Fatalf("El mensaje no tiene Content-Type:\n%s", raw)
+	}
+	return string(raw[:index+2]), raw[index+2:]
+}
+
+// verifyTestSignature comprueba la firma separada sobre content y devuelve el certificado incluido.
+func verifyTestSignature(t *testing.T, content, der []byte) *x509.Certificate {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		t.Fatalf("Error interpretando ContentInfo: %v", err)
+	}
+	assert.True(t, info.ContentType.Equal(oidSignedData))
+
+	var signed signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		t.Fatalf("Error interpretando SignedData: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(signed.Certificates.Bytes)
+	if err != nil {
+		t.Fatalf("Error interpretando el certificado de la firma: %v", err)
+	}
+	if len(signed.SignerInfos) != 1 {
+		t.Fatalf("Se esperaba un firmante, se obtuvieron %d", len(signed.SignerInfos))
+	}
+	signer := signed.SignerInfos[0]
+
+	// El atributo messageDigest debe corresponder al contenido firmado
+	var attributes []attribute
+	setBytes := append([]byte{0x31}, signer.SignedAttrs.FullBytes[1:]...)
+	if _, err := asn1.UnmarshalWithParams(setBytes, &attributes, "set"); err != nil {
+		t.Fatalf("Error interpretando los atributos firmados: %v", err)
+	}
+	contentDigest := sha256.Sum256(content)
+	digestFound := false
+	for _, attr := range attributes {
+		if attr.Type.Equal(oidAttributeMessageDigest) {
+			var digest []byte
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+				t.Fatalf("Error interpretando messageDigest: %v", err)
+			}
+			assert.Equal(t, contentDigest[:], digest)
+			digestFound = true
+		}
+	}
+	assert.True(t, digestFound, "La firma no incluye el atributo messageDigest")
+
+	attributesDigest := sha256.Sum256(setBytes)
+	switch key := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		assert.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, attributesDigest[:], signer.Signature))
+	case *ecdsa.PublicKey:
+		assert.True(t, ecdsa.VerifyASN1(key, attributesDigest[:], signer.Signature))
+	default:
+		t.Fatalf("Tipo de clave inesperado %T", key)
+	}
+	return certificate
+}
+
+// decryptTestEnvelope descifra el EnvelopedData con la clave del destinatario.
+func decryptTestEnvelope(t *testing.T, der []byte, recipient *testCertificate) []byte {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		t.Fatalf("Error interpretando ContentInfo: %v", err)
+	}
+	assert.True(t, info.ContentType.Equal(oidEnvelopedData))
+
+	var enveloped envelopedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &enveloped); err != nil {
+		t.Fatalf("Error interpretando EnvelopedData: %v", err)
+	}
+
+	var key []byte
+	for _, recipientInfo := range enveloped.RecipientInfos {
+		if recipientInfo.RID.SerialNumber.Cmp(recipient.certificate.SerialNumber) != 0 {
+			continue
+		}
+		var err error
+		key, err = rsa.DecryptPKCS1v15(rand.Reader, recipient.key.(*rsa.PrivateKey), recipientInfo.EncryptedKey)
+		if err != nil {
+			t.Fatalf("Error descifrando la clave de contenido: %v", err)
+		}
+	}
+	if key == nil {
+		t.Fatalf("El mensaje no está cifrado para %s", recipient.certificate.Subject.CommonName)
+	}
+
+	var iv []byte
+	algorithm := enveloped.EncryptedContentInfo.ContentEncryptionAlgorithm
+	assert.True(t, algorithm.Algorithm.Equal(oidAES256CBC))
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &iv); err != nil {
+		t.Fatalf("Error interpretando el IV: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Clave de contenido inválida: %v", err)
+	}
+	plaintext := make([]byte, len(enveloped.EncryptedContentInfo.EncryptedContent))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, enveloped.EncryptedContentInfo.EncryptedContent)
+	return plaintext[:len(plaintext)-int(plaintext[len(plaintext)-1])]
+}
+
+// decodeTestBase64Entity devuelve el contenido en base64 de una entidad MIME (sin sus encabezados).
+func decodeTestBase64Entity(t *testing.T, entity []byte) []byte {
+	_, body, found := bytes.Cut(entity, []byte("\r\n\r\n"))
+	if !found {
+		t.Fatalf("Entidad MIME sin cuerpo:\n%s", entity)
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(body), "\r\n", ""))
+	if err != nil {
+		t.Fatalf("Error decodificando base64: %v", err)
+	}
+	return der
+}
+
+func smimeTestMessage(options SMIMEOptions) *Message {
+	msg := inlineTestMessage("<p>Rechazo del archivo pagos_2024.csv</p>")
+	msg.SMIME = options
+	return msg
+}
+
+func TestComposeSMIMESigned(t *testing.T) {
+	for name, key := range map[string]crypto.Signer{
+		"rsa":   newTestRSAKey(t),
+		"ecdsa": func() crypto.Signer { k, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader); return k }(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			signer := newTestCertificate(t, senderEmailTest, key)
+			composer := newSMIMETestComposer(t, signer, nil)
+
+			raw, err := composer.Compose(smimeTestMessage(SMIMEOptions{Sign: true}), testMessageID)
+
+			if err != nil {
+				t.Fatalf("Error componiendo el mensaje: %v", err)
+			}
+			headers, entity := splitTestMessage(t, raw)
+			assert.Contains(t, headers, "Subject: "+testSubject)
+
+			contentType, body, _ := bytes.Cut(entity, []byte("\r\n\r\n"))
+			mediaType, params, err := mime.ParseMediaType(strings.TrimPrefix(string(contentType), "Content-Type: "))
+			assert.NoError(t, err)
+			assert.Equal(t, "multipart/signed", mediaType)
+			assert.Equal(t, "application/pkcs7-signature", params["protocol"])
+			assert.Equal(t, "sha-256", params["micalg"])
+
+			// La firma cubre los bytes exactos de la primera parte
+			delimiter := []byte("\r\n--" + params["boundary"])
+			parts := bytes.Split(append([]byte("\r\n"), body...), delimiter)
+			if len(parts) != 4 {
+				t.Fatalf("Se esperaban dos partes en multipart/signed, se obtuvo:\n%s", body)
+			}
+			signedContent := bytes.TrimPrefix(parts[1], []byte("\r\n"))
+			assert.Contains(t, string(signedContent), "Content-Type: text/html")
+
+			signature := decodeTestBase64Entity(t, bytes.TrimPrefix(parts[2], []byte("\r\n")))
+			certificate := verifyTestSignature(t, signedContent, signature)
+			assert.Equal(t, signer.certificate.SerialNumber, certificate.SerialNumber)
+
+			// El mensaje es un multipart válido para un lector MIME
+			reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+			for i := 0; i < 2; i++ {
+				part, err := reader.NextPart()
+				assert.NoError(t, err)
+				_, _ = io.Copy(io.Discard, part)
+			}
+		})
+	}
+}
+
+func TestComposeSMIMEEncrypted(t *testing.T) {
+	first := newTestCertificate(t, recipientEmailTest, newTestRSAKey(t))
+	second := newTestCertificate(t, "copia@test.com", newTestRSAKey(t))
+	composer := newSMIMETestComposer(t, nil, mockCertificateSource{
+		recipientEmailTest: first.certificatePEM,
+		"copia@test.com":   second.certificatePEM,
+	})
+	msg := smimeTestMessage(SMIMEOptions{Encrypt: true})
+	msg.Cc = []*mail.Address{{Address: "copia@test.com"}}
+
+	raw, err := composer.Compose(msg, testMessageID)
+
+	if err != nil {
+		t.Fatalf("Error componiendo el mensaje: %v", err)
+	}
+	_, entity := splitTestMessage(t, raw)
+	assert.True(t, bytes.HasPrefix(entity,
+		[]byte(`Content-Type: application/pkcs7-mime; smime-type=enveloped-data; name="smime.p7m"`)))
+	assert.NotContains(t, string(raw), "pagos_2024.csv")
+
+	// Cada destinatario puede descifrar el cuerpo original
+	for _, recipient := range []*testCertificate{first, second} {
+		plaintext := decryptTestEnvelope(t, decodeTestBase64Entity(t, entity), recipient)
+		assert.True(t, bytes.HasPrefix(plaintext, []byte("Content-Type: text/html")))
+		assert.Contains(t, string(plaintext), "pagos_2024.csv")
+	}
+}
+
+func TestComposeSMIMESignedAndEncrypted(t *testing.T) {
+	signer := newTestCertificate(t, senderEmailTest, newTestRSAKey(t))
+	recipient := newTestCertificate(t, recipientEmailTest, newTestRSAKey(t))
+	composer := newSMIMETestComposer(t, signer, mockCertificateSource{recipientEmailTest: recipient.certificatePEM})
+
+	raw, err := composer.Compose(smimeTestMessage(SMIMEOptions{Sign: true, Encrypt: true}), testMessageID)
+
+	if err != nil {
+		t.Fatalf("Error componiendo el mensaje: %v", err)
+	}
+	_, entity := splitTestMessage(t, raw)
+	plaintext := decryptTestEnvelope(t, decodeTestBase64Entity(t, entity), recipient)
+	// Se firma antes de cifrar: el contenido cifrado es el multipart/signed
+	assert.True(t, bytes.HasPrefix(plaintext, []byte("Content-Type: multipart/signed")))
+}
+
+func TestComposeSMIMEMissingRecipientCertificateIsPermanent(t *testing.T) {
+	recipient := newTestCertificate(t, recipientEmailTest, newTestRSAKey(t))
+	composer := newSMIMETestComposer(t, nil, mockCertificateSource{recipientEmailTest: recipient.certificatePEM})
+	msg := smimeTestMessage(SMIMEOptions{Encrypt: true})
+	msg.To = append(msg.To, &mail.Address{Address: "sincert@test.com"})
+
+	_, err := composer.Compose(msg, testMessageID)
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "sincert@test.com")
+}
+
+func TestComposeSMIMEWithoutSignerIsPermanent(t *testing.T) {
+	composer := newSMIMETestComposer(t, nil, nil)
+
+	_, err := composer.Compose(smimeTestMessage(SMIMEOptions{Sign: true}), testMessageID)
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "SECRETS_SMIME")
+}
+
+func TestComposeWithoutSMIMEIsUnchanged(t *testing.T) {
+	composer := newSMIMETestComposer(t, newTestCertificate(t, senderEmailTest, newTestRSAKey(t)), nil)
+
+	raw, err := composer.Compose(smimeTestMessage(SMIMEOptions{}), testMessageID)
+
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), "Content-Type: text/html; charset=\"UTF-8\"")
+	assert.NotContains(t, string(raw), "pkcs7")
+}
+
+func TestNewSMIMESignerKeyMismatch(t *testing.T) {
+	certificate := newTestCertificate(t, senderEmailTest, newTestRSAKey(t))
+	other := newTestCertificate(t, senderEmailTest, newTestRSAKey(t))
+
+	_, err := NewSMIMESigner(certificate.certificatePEM, other.keyPEM)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no corresponde")
+}
+
+func TestNewSMIMESignerFromEnv(t *testing.T) {
+	signer := newTestCertificate(t, senderEmailTest, newTestRSAKey(t))
+	mockSecretService := new(MockSecretService)
+	mockSecretService.On("GetSecret", "smime-secret", testMessageID).Return(&connection.SecretData{
+		SMIMECertificate: signer.certificatePEM,
+		SMIMEPrivateKey:  signer.keyPEM,
+	}, nil)
+
+	t.Setenv("SECRETS_SMIME", "")
+	notConfigured, err := NewSMIMESignerFromEnv(mockSecretService, testMessageID)
+	assert.NoError(t, err)
+	assert.Nil(t, notConfigured)
+
+	t.Setenv("SECRETS_SMIME", "smime-secret")
+	configured, err := NewSMIMESignerFromEnv(mockSecretService, testMessageID)
+	assert.NoError(t, err)
+	if configured == nil {
+		t.Fatalf("Se esperaba un firmante S/MIME")
+	}
+	assert.Equal(t, signer.certificate.SerialNumber, configured.certificate.SerialNumber)
+}
+
+func TestSMIMEFromContext(t *testing.T) {
+	assert.Equal(t, SMIMEOptions{}, SMIMEFromContext(context.Background()))
+
+	ctx := WithSMIME(context.Background(), SMIMEOptions{Encrypt: true})
+	assert.Equal(t, SMIMEOptions{Encrypt: true}, SMIMEFromContext(ctx))
+}
@@ -2,42 +2,72 @@ package email
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gmf_message_processor/connection"
 	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
 	"net/smtp"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
-// EmailServiceInterface define los métodos que debe implementar un servicio de correo electrónico.
+// EmailServiceInterface define los métodos que debe implementar un servicio de correo electrónico. La cancelación o
+// el vencimiento del contexto aborta el envío en curso.
 type EmailServiceInterface interface {
-	SendEmail(remitente, destinatarios, asunto, cuerpo, messageID string) error
+	SendEmail(ctx context.Context, remitente, destinatarios, asunto, cuerpo, messageID string) error
 }
 
-var ctx = context.TODO()
-
 // SMTPEmailService implementa EmailService utilizando SMTP.
 type SMTPEmailService struct {
-	server   string
-	port     string
-	username string
-	password string
-	sendMail smtpSendMailFunc
-	timeout  time.Duration
+	server        string
+	port          string
+	username      string
+	password      string
+	authMechanism string
+	tokenSource   tokenSource
+	composer      *Composer
+	policy        *ReplyPolicy
+	sendMail      smtpSendMailFunc
+	timeout       time.Duration
 }
 
-// smtpSendMailFunc es una función de envío de correo electrónico SMTP.
+// smtpSendMailFunc es una función de envío de correo electrónico SMTP que devuelve el resultado por destinatario.
+// Debe abortar la conversación cuando se cancela el contexto.
 type smtpSendMailFunc func(
-	addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) ([]RecipientResult, error)
 
 // NewSMTPEmailService crea una nueva instancia de SMTPEmailService usando SecretService para obtener las credenciales SMTP.
-func NewSMTPEmailService(secretService connection.SecretService, messageID string) (*SMTPEmailService, error) {
-	secretName := os.Getenv("SECRETS_SMTP")
+func NewSMTPEmailService(
+	secretService connection.SecretService, messageID string, opts ...ComposerOption) (*SMTPEmailService, error) {
+	return newSMTPEmailServiceFromEnv(secretService, "SMTP", messageID, opts...)
+}
+
+// NewSecondarySMTPEmailService crea el servicio SMTP de respaldo a partir de las variables SMTP_SECONDARY_*.
+func NewSecondarySMTPEmailService(
+	secretService connection.SecretService, messageID string, opts ...ComposerOption) (*SMTPEmailService, error) {
+	return newSMTPEmailServiceFromEnv(secretService, "SMTP_SECONDARY", messageID, opts...)
+}
+
+// newSMTPEmailServiceFromEnv lee la configuración de las variables SECRETS_<prefix>, <prefix>_SERVER,
+// <prefix>_PORT, <prefix>_TIMEOUT y <prefix>_AUTH. El servidor y el puerto son obligatorios.
+func newSMTPEmailServiceFromEnv(
+	secretService connection.SecretService,
+	prefix string,
+	messageID string,
+	opts ...ComposerOption) (*SMTPEmailService, error) {
+	server, port := os.Getenv(prefix+"_SERVER"), os.Getenv(prefix+"_PORT")
+	if server == "" || port == "" {
+		err := fmt.Errorf("error: las variables %s_SERVER y %s_PORT son obligatorias para el proveedor SMTP",
+			prefix, prefix)
+		logs.LogError("Configuración SMTP incompleta", err, messageID)
+		return nil, err
+	}
+
+	secretName := os.Getenv("SECRETS_" + prefix)
 	secretData, err := secretService.GetSecret(secretName, messageID) // Pasar el messageID
 	if err != nil {
 		logs.LogError("Error al obtener las credenciales SMTP desde Secrets Manager", err, messageID)
@@ -45,57 +75,106 @@ func NewSMTPEmailService(secretService connection.SecretService, messageID strin
 	}
 
 	// Leer el timeout desde las variables de entorno, o usar el valor por defecto (15 segundos)
-	timeoutStr := viper.GetString("SMTP_TIMEOUT")
-	timeoutValue, err := strconv.Atoi(timeoutStr)
+	timeout := timeoutFromEnv(prefix + "_TIMEOUT")
+
+	// Mecanismo de autenticación: none, plain (por defecto), login, cram-md5 o xoauth2
+	authMechanism, err := normalizeAuthMechanism(os.Getenv(prefix + "_AUTH"))
 	if err != nil {
-		timeoutValue = 15 // Valor por defecto en segundos
+		logs.LogError("Mecanismo de autenticación SMTP inválido", err, messageID)
+		return nil, err
+	}
+
+	// Composición MIME compartida (incluye la firma DKIM si está configurada)
+	composer, err := NewComposerFromEnv(secretService, messageID, opts...)
+	if err != nil {
+		logs.LogError("Error inicializando la composición de mensajes", err, messageID)
+		return nil, err
 	}
 
-	return &SMTPEmailService{
-		server:   os.Getenv("SMTP_SERVER"),
-		port:     os.Getenv("SMTP_PORT"),
-		username: secretData.Username,
-		password: secretData.Password,
-		sendMail: smtp.SendMail,
-		timeout:  time.Duration(timeoutValue) * time.Second, // Convertir a duración en segundos
-	}, nil
+	// Políticas por código de respuesta SMTP (reintentar, diferir, descartar o fallar)
+	policy, err := ReplyPolicyFromEnv()
+	if err != nil {
+		logs.LogError("Políticas de respuesta SMTP inválidas", err, messageID)
+		return nil, err
+	}
+
+	service := &SMTPEmailService{
+		server:        server,
+		port:          port,
+		username:      secretData.Username,
+		password:      secretData.Password,
+		authMechanism: authMechanism,
+		composer:      composer,
+		policy:        policy,
+		sendMail:      sendMailPerRecipient,
+		timeout:       timeout,
+	}
+
+	if authMechanism == AuthXOAUTH2 {
+		tokens, err := newClientCredentialsTokenSource(secretData, timeout)
+		if err != nil {
+			logs.LogError("Credenciales OAuth2 incompletas en el secreto SMTP", err, messageID)
+			return nil, err
+		}
+		service.tokenSource = tokens
+	}
+
+	return service, nil
+}
+
+// timeoutFromEnv lee un timeout en segundos con Viper, o usa el valor por defecto (15 segundos).
+func timeoutFromEnv(key string) time.Duration {
+	timeoutValue, err := strconv.Atoi(viper.GetString(key))
+	if err != nil {
+		timeoutValue = 15 // Valor por defecto en segundos
+	}
+	return time.Duration(timeoutValue) * time.Second // Convertir a duración en segundos
 }
 
 // SendEmail envía el correo con el timeout configurable.
 func (s *SMTPEmailService) SendEmail(
+	ctx context.Context,
 	remitente,
 	destinatarios,
 	asunto,
 	cuerpo string,
 	messageID string) error {
 	// Validar la configuración SMTP
-	if s.server == "" || s.port == "" || s.username == "" || s.password == "" {
+	if s.server == "" || s.port == "" || !s.hasCredentials() {
 		return fmt.Errorf("error: configuración SMTP incompleta en las variables de entorno")
 	}
 
-	// Configurar autenticación SMTP
-	auth := smtp.PlainAuth("", s.username, s.password, s.server)
-
-	// Separar los destinatarios por comas
-	to := strings.Split(destinatarios, ",")
-
-	// validar que haya destinatarios
-	if len(to) == 0 || to[0] == "" {
-		return fmt.Errorf("error: no se especificaron destinatarios")
+	// Interpretar, normalizar y validar el remitente y los destinatarios
+	from, err := ParseSender(remitente)
+	if err != nil {
+		logs.LogError("Remitente inválido", err, messageID)
+		return err
+	}
+	to, err := ParseAddressList(destinatarios)
+	if err != nil {
+		logs.LogError("Destinatarios inválidos", err, messageID)
+		return err
 	}
 
-	// Configurar mensaje en formato HTML
-	msg := []byte(fmt.Sprintf(
-		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=\"UTF-8\"\r\n\r\n%s",
-		remitente,
-		strings.Join(to, ", "),
-		asunto,
-		cuerpo,
-	))
+	// Componer el mensaje MIME en formato HTML (y firmarlo si corresponde)
+	message := &Message{
+		From:      from,
+		To:        to,
+		Subject:   asunto,
+		HTMLBody:  cuerpo,
+		MessageID: messageID,
+		SMIME:     SMIMEFromContext(ctx),
+	}
+	msg, err := s.composer.Compose(message, messageID)
+	if err != nil {
+		return err
+	}
 
-	// Manejar error de conversión de destinatarios
-	if to == nil || len(to) == 0 || strings.Contains(to[0], "\x7f") {
-		return fmt.Errorf("error al convertir destinatarios a JSON")
+	// Configurar autenticación SMTP según el mecanismo configurado
+	auth, err := s.buildAuth(ctx, messageID)
+	if err != nil {
+		logs.LogError("Error configurando la autenticación SMTP", err, messageID)
+		return err
 	}
 
 	// Registrar el inicio del envío de correo
@@ -105,7 +184,7 @@ func (s *SMTPEmailService) SendEmail(
 	startTime := time.Now()
 
 	// Enviar el correo con el timeout configurado
-	err := s.sendMailWithTimeout(s.server+":"+s.port, auth, remitente, to, msg)
+	results, err := s.sendMailWithTimeout(ctx, s.server+":"+s.port, auth, from.Address, message.Recipients(), msg)
 
 	// Medir el tiempo de fin
 	duration := time.Since(startTime).Milliseconds()
@@ -126,31 +205,90 @@ func (s *SMTPEmailService) SendEmail(
 		messageID,
 	)
 
-	return nil
+	return deliveryOutcome(results, s.replyPolicy(), messageID)
 }
 
-// sendMailWithTimeout envía el correo con un timeout establecido.
-func (s *SMTPEmailService) sendMailWithTimeout(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
-	// Crear contexto con timeout
-	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
-	defer cancel()
-
-	done := make(chan error, 1)
+// replyPolicy devuelve las políticas configuradas, o las políticas por defecto.
+func (s *SMTPEmailService) replyPolicy() *ReplyPolicy {
+	if s.policy == nil {
+		return NewReplyPolicy(nil, maxDeferDelay)
+	}
+	return s.policy
+}
 
-	go func() {
-		err := s.sendMail(addr, auth, from, to, msg)
-		done <- err
-	}()
+// deliveryOutcome aplica la política de respuestas, registra el resultado de cada destinatario y devuelve un
+// DeliveryError si alguno no fue aceptado. Si no hay destinatarios diferidos, o la política de alguno es fail, el
+// error es permanente, ya que reintentar no cambia el resultado.
+func deliveryOutcome(results []RecipientResult, policy *ReplyPolicy, messageID string) error {
+	policy.classifyResults(results)
 
-	select {
-	case <-ctx.Done():
-		// Timeout alcanzado
-		return fmt.Errorf("error: timeout al enviar correo electrónico")
-	case err := <-done:
-		// Error al enviar el correo
-		if err != nil {
-			return fmt.Errorf("error enviando el correo electrónico: %v", err)
+	failed := false
+	permanent := false
+	var retryAfter time.Duration
+	for _, result := range results {
+		switch result.Status {
+		case RecipientAccepted:
+			logs.LogInfo(fmt.Sprintf("Destinatario %s aceptado", result.Address), messageID)
+			continue
+		case RecipientDeferred:
+			if delay := policy.retryAfter(result.Action); delay > retryAfter {
+				retryAfter = delay
+			}
 		}
+		failed = true
+		permanent = permanent || result.Action == ActionFail
+		logs.LogWarn(
+			fmt.Sprintf("Destinatario %s %s por el servidor (%d %s, política %s): %s",
+				result.Address, statusDescription(result.Status), result.Code, result.EnhancedCode, result.Action,
+				result.Message),
+			messageID,
+		)
+	}
+
+	if !failed {
 		return nil
 	}
+
+	deliveryErr := &DeliveryError{Results: results, RetryAfter: retryAfter}
+	if permanent || len(deliveryErr.DeferredRecipients()) == 0 {
+		return models.NewPermanentError(deliveryErr)
+	}
+	return deliveryErr
+}
+
+func statusDescription(status RecipientStatus) string {
+	if status == RecipientDeferred {
+		return "diferido"
+	}
+	return "rechazado"
+}
+
+// sendMailWithTimeout envía el correo con el timeout configurado. Al vencer el timeout (o cancelarse ctx) la
+// conexión se cierra, de modo que el envío se aborta y no se completa tarde.
+func (s *SMTPEmailService) sendMailWithTimeout(
+	ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) ([]RecipientResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	results, err := s.sendMail(ctx, addr, auth, from, to, msg)
+	if err != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return nil, newTransportError(errors.New("error: timeout al enviar correo electrónico"))
+		case ctx.Err() != nil:
+			return nil, fmt.Errorf("error: envío de correo electrónico cancelado: %w", ctx.Err())
+		}
+
+		// Las respuestas de error del servidor se clasifican según la política configurada
+		var smtpErr *SMTPError
+		if errors.As(err, &smtpErr) {
+			wrapped := fmt.Errorf("error enviando el correo electrónico: %w", err)
+			if s.replyPolicy().classifyError(smtpErr) {
+				return nil, models.NewPermanentError(wrapped)
+			}
+			return nil, newTransportError(wrapped)
+		}
+		return nil, newTransportError(fmt.Errorf("error enviando el correo electrónico: %v", err))
+	}
+	return results, nil
 }
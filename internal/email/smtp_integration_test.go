@@ -0,0 +1,235 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"gmf_message_processor/internal/email/smtptest"
+	"gmf_message_processor/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	integrationUser     = "gmf-user"
+	integrationPassword = "gmf-password"
+)
+
+// newIntegrationSMTPService crea un SMTPEmailService conectado al servidor de prueba, confiando en su certificado.
+func newIntegrationSMTPService(server *smtptest.Server, mechanism string, timeout time.Duration) *SMTPEmailService {
+	return &SMTPEmailService{
+		server:        server.Host(),
+		port:          server.Port(),
+		username:      integrationUser,
+		password:      integrationPassword,
+		authMechanism: mechanism,
+		composer:      NewComposer(),
+		sendMail:      newSendMailFunc(server.ClientTLSConfig()),
+		timeout:       timeout,
+	}
+}
+
+func TestSMTPIntegrationSTARTTLSAndAuth(t *testing.T) {
+	for _, mechanism := range []string{AuthPlain, AuthLogin, AuthCRAMMD5} {
+		t.Run(mechanism, func(t *testing.T) {
+			server := smtptest.NewServer(t, smtptest.WithSTARTTLS(), smtptest.WithAuth(integrationUser, integrationPassword))
+			service := newIntegrationSMTPService(server, mechanism, 5*time.Second)
+
+			err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com, b@test.com", testSubject,
+				testBody, testMessageID)
+
+			assert.NoError(t, err)
+			messages := server.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("Se esperaba un mensaje recibido, se obtuvieron %d", len(messages))
+			}
+			assert.True(t, messages[0].TLS)
+			assert.Equal(t, integrationUser, messages[0].Username)
+			assert.Equal(t, senderEmailTest, messages[0].From)
+			assert.Equal(t, []string{"a@test.com", "b@test.com"}, messages[0].To)
+			assert.Contains(t, string(messages[0].Data), "Subject: "+testSubject)
+			assert.Contains(t, string(messages[0].Data), "Message-ID: <"+testMessageID+".")
+		})
+	}
+}
+
+func TestSMTPIntegrationInvalidCredentials(t *testing.T) {
+	server := smtptest.NewServer(t, smtptest.WithSTARTTLS(), smtptest.WithAuth(integrationUser, "otra"))
+	service := newIntegrationSMTPService(server, AuthPlain, 5*time.Second)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "535")
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPIntegrationUntrustedCertificate(t *testing.T) {
+	server := smtptest.NewServer(t, smtptest.WithSTARTTLS(), smtptest.WithAuth(integrationUser, integrationPassword))
+	service := newIntegrationSMTPService(server, AuthPlain, 5*time.Second)
+	service.sendMail = sendMailPerRecipient
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPIntegrationRecipientRejected(t *testing.T) {
+	server := smtptest.NewServer(t,
+		smtptest.WithRecipientReply("noexiste@test.com", "550 5.1.1 <noexiste@test.com>: User unknown"))
+	service := newIntegrationSMTPService(server, AuthNone, 5*time.Second)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com, noexiste@test.com", testSubject,
+		testBody, testMessageID)
+
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		t.Fatalf("Se esperaba un DeliveryError, se obtuvo %v", err)
+	}
+	assert.Equal(t, []string{"noexiste@test.com"}, deliveryErr.RejectedRecipients())
+	assert.True(t, models.IsPermanentError(err))
+	assert.Equal(t, []string{"a@test.com"}, server.Messages()[0].To)
+}
+
+func TestSMTPIntegrationRateLimitedSenderIsDeferred(t *testing.T) {
+	server := smtptest.NewServer(t, smtptest.WithReply(smtptest.StageMail, "421 4.7.0 Too many messages, slow down"))
+	service := newIntegrationSMTPService(server, AuthNone, 5*time.Second)
+	service.policy = NewReplyPolicy(nil, 10*time.Minute)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) {
+		t.Fatalf("Se esperaba un SMTPError, se obtuvo %v", err)
+	}
+	assert.Equal(t, StageMail, smtpErr.Stage)
+	assert.Equal(t, 421, smtpErr.Code)
+	assert.Equal(t, "4.7.0", smtpErr.EnhancedCode)
+	assert.Equal(t, ActionDefer, smtpErr.Action)
+	assert.Equal(t, 10*time.Minute, smtpErr.RetryDelay())
+	assert.False(t, models.IsPermanentError(err))
+}
+
+func TestSMTPIntegrationUnknownMailboxAtDataIsNotRetried(t *testing.T) {
+	server := smtptest.NewServer(t, smtptest.WithReply(smtptest.StageData, "550 5.1.1 Mailbox does not exist"))
+	service := newIntegrationSMTPService(server, AuthNone, 5*time.Second)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		t.Fatalf("Se esperaba un DeliveryError, se obtuvo %v", err)
+	}
+	assert.Equal(t, ActionDrop, deliveryErr.Results[0].Action)
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestSMTPIntegrationFailPolicyStopsRetries(t *testing.T) {
+	server := smtptest.NewServer(t,
+		smtptest.WithRecipientReply("bloqueado@test.com", "550 5.7.1 Relaying denied"),
+		smtptest.WithRecipientReply("lleno@test.com", "452 4.2.2 Mailbox full"))
+	service := newIntegrationSMTPService(server, AuthNone, 5*time.Second)
+	service.policy = NewReplyPolicy(map[string]ReplyAction{"5.7.1": ActionFail}, maxDeferDelay)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "bloqueado@test.com, lleno@test.com", testSubject,
+		testBody, testMessageID)
+
+	// Con la política fail no se reintentan ni siquiera los destinatarios diferidos
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		t.Fatalf("Se esperaba un DeliveryError, se obtuvo %v", err)
+	}
+	assert.Equal(t, []string{"lleno@test.com"}, deliveryErr.DeferredRecipients())
+	assert.True(t, models.IsPermanentError(err))
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPIntegrationDataDeferred(t *testing.T) {
+	server := smtptest.NewServer(t, smtptest.WithReply(smtptest.StageData, "451 4.3.0 Try again later"))
+	service := newIntegrationSMTPService(server, AuthNone, 5*time.Second)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, "a@test.com, b@test.com", testSubject, testBody,
+		testMessageID)
+
+	var deliveryErr *DeliveryError
+	if !errors.As(err, &deliveryErr) {
+		t.Fatalf("Se esperaba un DeliveryError, se obtuvo %v", err)
+	}
+	assert.Equal(t, []string{"a@test.com", "b@test.com"}, deliveryErr.DeferredRecipients())
+	assert.False(t, models.IsPermanentError(err))
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPIntegrationSlowServerTimesOut(t *testing.T) {
+	server := smtptest.NewServer(t, smtptest.WithDelay(smtptest.StageData, 500*time.Millisecond))
+	service := newIntegrationSMTPService(server, AuthNone, 100*time.Millisecond)
+
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timeout")
+	assert.False(t, models.IsPermanentError(err))
+}
+
+func TestSMTPIntegrationTimeoutAbortsConversation(t *testing.T) {
+	delay := 300 * time.Millisecond
+	server := smtptest.NewServer(t, smtptest.WithDelay(smtptest.StageRcpt, delay))
+	service := newIntegrationSMTPService(server, AuthNone, 50*time.Millisecond)
+
+	start := time.Now()
+	err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+		testMessageID)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timeout")
+	assert.Less(t, time.Since(start), delay)
+
+	// La conversación se abortó: el correo no debe entregarse cuando el servidor responde tarde
+	time.Sleep(2 * delay)
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPIntegrationCancelledContext(t *testing.T) {
+	delay := 300 * time.Millisecond
+	server := smtptest.NewServer(t, smtptest.WithDelay(smtptest.StageMail, delay))
+	service := newIntegrationSMTPService(server, AuthNone, 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := service.SendEmail(ctx, senderEmailTest, recipientEmailTest, testSubject, testBody, testMessageID)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "cancelado")
+	assert.False(t, models.IsPermanentError(err))
+	assert.Less(t, time.Since(start), delay)
+
+	time.Sleep(2 * delay)
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPIntegrationDroppedConnection(t *testing.T) {
+	for _, stage := range []smtptest.Stage{smtptest.StageGreeting, smtptest.StageRcpt, smtptest.StageData} {
+		t.Run(strings.ToLower(string(stage)), func(t *testing.T) {
+			server := smtptest.NewServer(t, smtptest.WithDrop(stage))
+			service := newIntegrationSMTPService(server, AuthNone, 5*time.Second)
+
+			err := service.SendEmail(context.Background(), senderEmailTest, recipientEmailTest, testSubject, testBody,
+				testMessageID)
+
+			assert.Error(t, err)
+			assert.False(t, models.IsPermanentError(err))
+			assert.Empty(t, server.Messages())
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package email
 
 import (
+	"context"
 	"errors"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/mock"
 	"gmf_message_processor/connection"
+	"gmf_message_processor/internal/models"
 	"net/smtp"
 	"testing"
 	"time"
@@ -23,18 +25,34 @@ func (m *MockSecretService) GetSecret(secretName string, messageID string) (*con
 }
 
 // Mock de smtpSendMailFunc para simular el envío de correo sin realizar la operación real.
-func mockSendMailSuccess(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
-	return nil // Simula éxito
+func mockSendMailSuccess(_ context.Context, addr string, a smtp.Auth, from string, to []string,
+	msg []byte) ([]RecipientResult, error) {
+	return acceptAll(to), nil // Simula éxito
 }
 
-func mockSendMailError(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
-	return errors.New("error enviando el correo") // Simula un error
+func mockSendMailError(_ context.Context, addr string, a smtp.Auth, from string, to []string,
+	msg []byte) ([]RecipientResult, error) {
+	return nil, errors.New("error enviando el correo") // Simula un error
 }
 
-// Mock para forzar un retraso, simulando un timeout.
-func mockSendMailTimeout(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
-	time.Sleep(100 * time.Millisecond) // Fuerza un retraso mayor al timeout del servicio
-	return nil
+// Mock para forzar un retraso, simulando un timeout. Respeta el contexto como lo hace el envío real.
+func mockSendMailTimeout(
+	ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) ([]RecipientResult, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(100 * time.Millisecond): // Fuerza un retraso mayor al timeout del servicio
+		return acceptAll(to), nil
+	}
+}
+
+// acceptAll simula que el servidor acepta todos los destinatarios.
+func acceptAll(to []string) []RecipientResult {
+	results := make([]RecipientResult, 0, len(to))
+	for _, rcpt := range to {
+		results = append(results, RecipientResult{Address: rcpt, Status: RecipientAccepted, Code: 250})
+	}
+	return results
 }
 
 const secretName = "my-smtp-secrets"
@@ -84,6 +102,7 @@ func TestSMTPEmailServiceSendEmailSuccess(t *testing.T) {
 	}
 
 	err := service.SendEmail(
+		context.Background(),
 		senderEmailTest,
 		recipientEmailTest,
 		testSubject,
@@ -105,6 +124,7 @@ func TestSMTPEmailServiceSendEmailTimeout(t *testing.T) {
 	}
 
 	err := service.SendEmail(
+		context.Background(),
 		senderEmailTest,
 		recipientEmailTest,
 		testSubject,
@@ -127,6 +147,7 @@ func TestSMTPEmailServiceSendEmailError(t *testing.T) {
 	}
 
 	err := service.SendEmail(
+		context.Background(),
 		senderEmailTest,
 		recipientEmailTest,
 		testSubject,
@@ -149,6 +170,7 @@ func TestSMTPEmailServiceSendEmailIncompleteConfig(t *testing.T) {
 	}
 
 	err := service.SendEmail(
+		context.Background(),
 		senderEmailTest,
 		recipientEmailTest,
 		testSubject,
@@ -172,6 +194,7 @@ func TestSMTPEmailServiceSendEmailInvalidRecipient(t *testing.T) {
 
 	// Caso de destinatarios vacíos
 	err := service.SendEmail(
+		context.Background(),
 		senderEmailTest,
 		"",
 		testSubject,
@@ -218,6 +241,18 @@ func TestNewSMTPEmailServiceSuccess(t *testing.T) {
 	mockSecretService.AssertExpectations(t)
 }
 
+func TestNewSMTPEmailServiceRequiresServerAndPort(t *testing.T) {
+	mockSecretService := new(MockSecretService)
+	t.Setenv("SMTP_SERVER", smtpServerTest)
+	t.Setenv("SMTP_PORT", "")
+
+	service, err := NewSMTPEmailService(mockSecretService, testMessageID)
+
+	assert.Nil(t, service)
+	assert.ErrorContains(t, err, "SMTP_SERVER y SMTP_PORT")
+	mockSecretService.AssertNotCalled(t, "GetSecret", mock.Anything, mock.Anything)
+}
+
 func TestNewSMTPEmailServiceErrorGettingSecret(t *testing.T) {
 	mockSecretService := new(MockSecretService)
 
@@ -256,6 +291,7 @@ func TestSMTPEmailServiceSendEmailErrorConvertingRecipients(t *testing.T) {
 
 	// Probar un destinatario mal formado
 	err := service.SendEmail(
+		context.Background(),
 		senderEmailTest,
 		string([]byte{0x7f}),
 		testSubject,
@@ -263,7 +299,8 @@ func TestSMTPEmailServiceSendEmailErrorConvertingRecipients(t *testing.T) {
 		testMessageID,
 	)
 
-	// Verificar que se retorne el error esperado
+	// Verificar que se retorne un error permanente de validación
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "error al convertir destinatarios a JSON")
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "direcciones de correo inválidas")
 }
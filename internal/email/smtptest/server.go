@@ -0,0 +1,511 @@
+// Package smtptest proporciona un servidor SMTP en proceso para las pruebas de integración de la capa de correo.
+// Soporta STARTTLS con un certificado autofirmado, AUTH (PLAIN, LOGIN, CRAM-MD5 y XOAUTH2), fallas programadas
+// (respuestas 4xx/5xx, respuestas lentas y conexiones cortadas) y captura los mensajes recibidos.
+package smtptest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Stage identifica el punto de la conversación SMTP en el que se aplica una falla programada.
+type Stage string
+
+const (
+	StageGreeting Stage = "GREETING"
+	StageEHLO     Stage = "EHLO"
+	StageStartTLS Stage = "STARTTLS"
+	StageAuth     Stage = "AUTH"
+	StageMail     Stage = "MAIL"
+	StageRcpt     Stage = "RCPT"
+	// StageData es la respuesta final, una vez recibido el contenido del mensaje.
+	StageData Stage = "DATA"
+)
+
+// Message es un correo recibido por el servidor.
+type Message struct {
+	From string
+	// To contiene únicamente los destinatarios aceptados en RCPT TO.
+	To   []string
+	Data []byte
+	// TLS indica si el mensaje se recibió sobre una conexión cifrada con STARTTLS.
+	TLS bool
+	// Username es el usuario autenticado, o "" si no hubo AUTH.
+	Username string
+}
+
+// Option configura el servidor.
+type Option func(*Server)
+
+// WithSTARTTLS anuncia STARTTLS con un certificado autofirmado para 127.0.0.1 y localhost. Cuando está activo, AUTH
+// solo se anuncia después de STARTTLS, como en los servidores reales.
+func WithSTARTTLS() Option {
+	return func(s *Server) {
+		s.startTLS = true
+	}
+}
+
+// WithAuth exige autenticación con las credenciales indicadas. Para XOAUTH2 el token debe ser igual a password.
+func WithAuth(username, password string) Option {
+	return func(s *Server) {
+		s.username = username
+		s.password = password
+	}
+}
+
+// WithReply reemplaza la respuesta del servidor en la etapa indicada (por ejemplo "451 4.3.0 Try again later").
+func WithReply(stage Stage, reply string) Option {
+	return func(s *Server) {
+		s.replies[stage] = reply
+	}
+}
+
+// WithRecipientReply define la respuesta a RCPT TO para una dirección.
+func WithRecipientReply(address, reply string) Option {
+	return func(s *Server) {
+		s.recipientReplies[strings.ToLower(address)] = reply
+	}
+}
+
+// WithDelay retrasa la respuesta del servidor en la etapa indicada.
+func WithDelay(stage Stage, delay time.Duration) Option {
+	return func(s *Server) {
+		s.delays[stage] = delay
+	}
+}
+
+// WithDrop cierra la conexión en lugar de responder en la etapa indicada.
+func WithDrop(stage Stage) Option {
+	return func(s *Server) {
+		s.drops[stage] = true
+	}
+}
+
+// Server es un servidor SMTP en proceso que escucha en 127.0.0.1.
+type Server struct {
+	// Addr es la dirección host:puerto en la que escucha el servidor.
+	Addr string
+
+	listener         net.Listener
+	startTLS         bool
+	tlsConfig        *tls.Config
+	certPool         *x509.CertPool
+	username         string
+	password         string
+	replies          map[Stage]string
+	recipientReplies map[string]string
+	delays           map[Stage]time.Duration
+	drops            map[Stage]bool
+
+	mu       sync.Mutex
+	messages []Message
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer inicia el servidor y lo detiene al finalizar la prueba.
+func NewServer(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+
+	server := &Server{
+		replies:          map[Stage]string{},
+		recipientReplies: map[string]string{},
+		delays:           map[Stage]time.Duration{},
+		drops:            map[Stage]bool{},
+		conns:            map[net.Conn]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	if server.startTLS {
+		certificate, pool, err := selfSignedCertificate()
+		if err != nil {
+			t.Fatalf("Error generando el certificado del servidor SMTP de prueba: %v", err)
+		}
+		server.tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+		server.certPool = pool
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error iniciando el servidor SMTP de prueba: %v", err)
+	}
+	server.listener = listener
+	server.Addr = listener.Addr().String()
+
+	server.wg.Add(1)
+	go server.serve()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Host devuelve el host en el que escucha el servidor.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port devuelve el puerto en el que escucha el servidor.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	return port
+}
+
+// ClientTLSConfig devuelve una configuración TLS que confía en el certificado autofirmado del servidor.
+func (s *Server) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.certPool, ServerName: s.Host()}
+}
+
+// Messages devuelve una copia de los mensajes recibidos.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close detiene el servidor y cierra las conexiones abiertas.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			(&session{server: s, conn: conn, text: textproto.NewConn(conn)}).run()
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (s *Server) store(message Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+// session es el estado de una conexión SMTP.
+type session struct {
+	server   *Server
+	conn     net.Conn
+	text     *textproto.Conn
+	tls      bool
+	username string
+	from     string
+	to       []string
+}
+
+func (c *session) run() {
+	if !c.reply(StageGreeting, "220 127.0.0.1 ESMTP smtptest") {
+		return
+	}
+
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		var ok bool
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			ok = c.ehlo()
+		case "STARTTLS":
+			ok = c.startTLS()
+		case "AUTH":
+			ok = c.auth(arg)
+		case "MAIL":
+			ok = c.mail(arg)
+		case "RCPT":
+			ok = c.rcpt(arg)
+		case "DATA":
+			ok = c.data()
+		case "RSET":
+			c.from, c.to = "", nil
+			ok = c.write("250 2.0.0 OK")
+		case "NOOP":
+			ok = c.write("250 2.0.0 OK")
+		case "QUIT":
+			c.write("221 2.0.0 Bye")
+			return
+		default:
+			ok = c.write("502 5.5.2 Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// reply aplica las fallas programadas para la etapa y escribe la respuesta (o su reemplazo). Devuelve false si
+// la conexión debe cerrarse.
+func (c *session) reply(stage Stage, defaultReply string) bool {
+	if delay := c.server.delays[stage]; delay > 0 {
+		time.Sleep(delay)
+	}
+	if c.server.drops[stage] {
+		return false
+	}
+	if reply, ok := c.server.replies[stage]; ok {
+		return c.write(reply)
+	}
+	return c.write(defaultReply)
+}
+
+// failed indica si la etapa tiene programada una respuesta de error (4xx o 5xx).
+func (c *session) failed(stage Stage) bool {
+	reply, ok := c.server.replies[stage]
+	return ok && (strings.HasPrefix(reply, "4") || strings.HasPrefix(reply, "5"))
+}
+
+func (c *session) write(line string) bool {
+	return c.text.PrintfLine("%s", line) == nil
+}
+
+func (c *session) ehlo() bool {
+	lines := []string{"127.0.0.1", "8BITMIME", "PIPELINING"}
+	if c.server.startTLS && !c.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	if c.server.username != "" && (c.tls || !c.server.startTLS) {
+		lines = append(lines, "AUTH PLAIN LOGIN CRAM-MD5 XOAUTH2")
+	}
+
+	var reply strings.Builder
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		if i > 0 {
+			reply.WriteString("\r\n")
+		}
+		reply.WriteString("250" + separator + line)
+	}
+	return c.reply(StageEHLO, reply.String())
+}
+
+func (c *session) startTLS() bool {
+	if !c.server.startTLS || c.tls {
+		return c.write("502 5.5.1 STARTTLS not available")
+	}
+	if !c.reply(StageStartTLS, "220 2.0.0 Ready to start TLS") {
+		return false
+	}
+	if c.failed(StageStartTLS) {
+		return true
+	}
+
+	tlsConn := tls.Server(c.conn, c.server.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return false
+	}
+	c.conn = tlsConn
+	c.text = textproto.NewConn(tlsConn)
+	c.tls = true
+	c.username = ""
+	return true
+}
+
+func (c *session) auth(arg string) bool {
+	if c.server.username == "" {
+		return c.write("502 5.5.1 AUTH not available")
+	}
+
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	var username, secret string
+	var ok bool
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		response, readOK := c.challenge(initial, "")
+		if !readOK {
+			return false
+		}
+		parts := strings.Split(response, "\x00")
+		if len(parts) == 3 {
+			username, secret, ok = parts[1], parts[2], true
+		}
+	case "LOGIN":
+		user, readOK := c.challenge(initial, "Username:")
+		if !readOK {
+			return false
+		}
+		password, readOK := c.challenge("", "Password:")
+		if !readOK {
+			return false
+		}
+		username, secret, ok = user, password, true
+	case "CRAM-MD5":
+		nonce := fmt.Sprintf("<%d.smtptest@127.0.0.1>", time.Now().UnixNano())
+		response, readOK := c.challenge("", nonce)
+		if !readOK {
+			return false
+		}
+		user, digest, found := strings.Cut(response, " ")
+		mac := hmac.New(md5.New, []byte(c.server.password))
+		mac.Write([]byte(nonce))
+		if found && hmac.Equal([]byte(digest), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+			username, secret, ok = user, c.server.password, true
+		}
+	case "XOAUTH2":
+		response, readOK := c.challenge(initial, "")
+		if !readOK {
+			return false
+		}
+		for _, field := range strings.Split(response, "\x01") {
+			if value, found := strings.CutPrefix(field, "user="); found {
+				username = value
+			}
+			if value, found := strings.CutPrefix(field, "auth=Bearer "); found {
+				secret, ok = value, true
+			}
+		}
+	default:
+		return c.write("504 5.5.4 Unrecognized authentication type")
+	}
+
+	if !ok || username != c.server.username || secret != c.server.password {
+		return c.reply(StageAuth, "535 5.7.8 Authentication credentials invalid")
+	}
+	if c.failed(StageAuth) {
+		return c.reply(StageAuth, "")
+	}
+	c.username = username
+	return c.reply(StageAuth, "235 2.7.0 Authentication successful")
+}
+
+// challenge devuelve la respuesta inicial decodificada o, si no se envió, solicita una con el desafío indicado.
+func (c *session) challenge(initial, prompt string) (string, bool) {
+	if initial == "" || initial == "=" {
+		if !c.write("334 " + base64.StdEncoding.EncodeToString([]byte(prompt))) {
+			return "", false
+		}
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return "", false
+		}
+		initial = line
+	}
+	decoded, err := base64.StdEncoding.DecodeString(initial)
+	if err != nil {
+		return "", true
+	}
+	return string(decoded), true
+}
+
+func (c *session) mail(arg string) bool {
+	if c.server.username != "" && c.username == "" {
+		return c.write("530 5.7.0 Authentication required")
+	}
+	if c.failed(StageMail) {
+		return c.reply(StageMail, "")
+	}
+	c.from = extractPath(arg, "FROM:")
+	c.to = nil
+	return c.reply(StageMail, "250 2.1.0 OK")
+}
+
+func (c *session) rcpt(arg string) bool {
+	if c.from == "" {
+		return c.write("503 5.5.1 MAIL first")
+	}
+	address := extractPath(arg, "TO:")
+	if reply, ok := c.server.recipientReplies[strings.ToLower(address)]; ok {
+		if strings.HasPrefix(reply, "2") {
+			c.to = append(c.to, address)
+		}
+		return c.write(reply)
+	}
+	if c.failed(StageRcpt) {
+		return c.reply(StageRcpt, "")
+	}
+	c.to = append(c.to, address)
+	return c.reply(StageRcpt, "250 2.1.5 OK")
+}
+
+func (c *session) data() bool {
+	if len(c.to) == 0 {
+		return c.write("554 5.5.1 No valid recipients")
+	}
+	if !c.write("354 Start mail input; end with <CRLF>.<CRLF>") {
+		return false
+	}
+
+	// DotReader normaliza los finales de línea a \n; se restauran los CRLF con los que se transmitió el mensaje
+	data, err := io.ReadAll(c.text.DotReader())
+	if err != nil {
+		return false
+	}
+	data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	if !c.failed(StageData) && !c.server.drops[StageData] {
+		c.server.store(Message{
+			From:     c.from,
+			To:       append([]string(nil), c.to...),
+			Data:     data,
+			TLS:      c.tls,
+			Username: c.username,
+		})
+	}
+	c.from, c.to = "", nil
+	return c.reply(StageData, "250 2.0.0 Queued")
+}
+
+// extractPath obtiene la dirección de "FROM:<a@b.com> SIZE=10" o "TO:<a@b.com>".
+func extractPath(arg, prefix string) string {
+	arg = strings.TrimSpace(arg)
+	if len(arg) >= len(prefix) && strings.EqualFold(arg[:len(prefix)], prefix) {
+		arg = strings.TrimSpace(arg[len(prefix):])
+	}
+	if end := strings.Index(arg, ">"); strings.HasPrefix(arg, "<") && end > 0 {
+		return arg[1:end]
+	}
+	path, _, _ := strings.Cut(arg, " ")
+	return path
+}
@@ -0,0 +1,69 @@
+package smtptest
+
+import (
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerCapturesMessage(t *testing.T) {
+	server := NewServer(t, WithSTARTTLS(), WithAuth("user", "secret"))
+
+	client, err := smtp.Dial(server.Addr)
+	if err != nil {
+		t.Fatalf("Error conectando con el servidor: %v", err)
+	}
+	defer client.Close()
+
+	// AUTH solo se anuncia después de STARTTLS
+	ok, _ := client.Extension("AUTH")
+	assert.False(t, ok)
+
+	assert.NoError(t, client.StartTLS(server.ClientTLSConfig()))
+	assert.NoError(t, client.Auth(smtp.PlainAuth("", "user", "secret", server.Host())))
+	assert.NoError(t, client.Mail("from@test.com"))
+	assert.NoError(t, client.Rcpt("to@test.com"))
+	w, err := client.Data()
+	if err != nil {
+		t.Fatalf("Error iniciando DATA: %v", err)
+	}
+	_, _ = w.Write([]byte("Subject: hola\r\n\r\n.linea con punto\r\n"))
+	assert.NoError(t, w.Close())
+	assert.NoError(t, client.Quit())
+
+	messages := server.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, Message{
+		From:     "from@test.com",
+		To:       []string{"to@test.com"},
+		Data:     []byte("Subject: hola\r\n\r\n.linea con punto\r\n"),
+		TLS:      true,
+		Username: "user",
+	}, messages[0])
+}
+
+func TestServerRequiresAuthentication(t *testing.T) {
+	server := NewServer(t, WithAuth("user", "secret"))
+
+	client, err := smtp.Dial(server.Addr)
+	if err != nil {
+		t.Fatalf("Error conectando con el servidor: %v", err)
+	}
+	defer client.Close()
+
+	err = client.Mail("from@test.com")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "530")
+}
+
+func TestServerScriptedReplies(t *testing.T) {
+	server := NewServer(t,
+		WithReply(StageMail, "421 4.7.0 Too many connections"),
+		WithRecipientReply("a@test.com", "550 5.1.1 Unknown"),
+	)
+
+	err := smtp.SendMail(server.Addr, nil, "from@test.com", []string{"a@test.com"}, []byte("x"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "421")
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	"gmf_message_processor/internal/aws"
@@ -10,6 +11,7 @@ import (
 	"gmf_message_processor/internal/models"
 	"gmf_message_processor/internal/utils"
 	"strings"
+	"time"
 )
 
 // Interfaces para inyección de dependencias
@@ -31,6 +33,8 @@ type UtilsInterface interface {
 	ValidateSQSMessage(messageBody string) (*models.SQSMessage, error)
 	DeleteMessageFromQueue(ctx context.Context, client aws.SQSAPI, queueURL string, receiptHandle *string, messageID string) error
 	SendMessageToQueue(ctx context.Context, client aws.SQSAPI, queueURL, messageBody, messageID string) error
+	SendMessageToQueueWithDelay(
+		ctx context.Context, client aws.SQSAPI, queueURL, messageBody, messageID string, delay time.Duration) error
 }
 
 // Estructura principal del manejador
@@ -103,19 +107,59 @@ func (h *SQSHandler) processMessage(ctx context.Context, record events.SQSMessag
 // Maneja la recuperación en caso de panic
 func (h *SQSHandler) handleRecovery(validMsg *models.SQSMessage, messageID string) {
 	if r := recover(); r != nil {
-		h.Logger.LogError("Error al procesar el mensaje", fmt.Errorf("%v", r), messageID)
-		h.retryMessage(context.Background(), validMsg, messageID, nil)
+		err, ok := r.(error)
+		if !ok {
+			err = fmt.Errorf("%v", r)
+		}
+		h.retryMessage(context.Background(), validMsg, messageID, err)
 	}
 }
 
 var jsonMarshal = json.Marshal
 
+// deferredRecipientsError lo implementan los errores de entrega parcial que indican qué destinatarios
+// tuvieron un error temporal y deben reintentarse.
+type deferredRecipientsError interface {
+	error
+	DeferredRecipients() []string
+}
+
+// retryDelayError lo implementan los errores que indican un retraso específico para el reintento (por ejemplo,
+// una respuesta SMTP diferida por límite de envío).
+type retryDelayError interface {
+	error
+	RetryDelay() time.Duration
+}
+
 // Reintenta el envío de un mensaje a SQS
 func (h *SQSHandler) retryMessage(ctx context.Context, msg *models.SQSMessage, messageID string, err error) error {
 	if err != nil {
 		h.Logger.LogError("Error al procesar el mensaje", err, messageID)
 	}
 
+	// Los errores permanentes (datos inválidos) no se resuelven reintentando
+	if models.IsPermanentError(err) {
+		h.Logger.LogError("Error permanente, el mensaje no se reintentará", err, messageID)
+		return nil
+	}
+
+	// En una entrega parcial solo se reintentan los destinatarios diferidos
+	var partialErr deferredRecipientsError
+	if errors.As(err, &partialErr) {
+		msg.Destinatarios = partialErr.DeferredRecipients()
+		h.Logger.LogInfo(
+			fmt.Sprintf("Se reintentarán solo los destinatarios diferidos: %s", strings.Join(msg.Destinatarios, ", ")),
+			messageID,
+		)
+	}
+
+	// Las respuestas que piden esperar más (por ejemplo, límites de envío) retrasan el reintento
+	var delay time.Duration
+	var delayErr retryDelayError
+	if errors.As(err, &delayErr) {
+		delay = delayErr.RetryDelay()
+	}
+
 	msg.RetryCount++
 	if msg.RetryCount > utils.GetMaxRetries() {
 		h.Logger.LogError("Se alcanzó el máximo de reintentos", nil, messageID)
@@ -129,7 +173,14 @@ func (h *SQSHandler) retryMessage(ctx context.Context, msg *models.SQSMessage, m
 		return fmt.Errorf("Error convirtiendo mensaje a JSON: %w", err)
 	}
 
-	if err := h.Utils.SendMessageToQueue(ctx, h.SQSClient, h.QueueURL, string(messageBodyWithRetry), messageID); err != nil {
+	if delay > 0 {
+		h.Logger.LogInfo(fmt.Sprintf("El reintento se difiere %s", delay), messageID)
+		err = h.Utils.SendMessageToQueueWithDelay(
+			ctx, h.SQSClient, h.QueueURL, string(messageBodyWithRetry), messageID, delay)
+	} else {
+		err = h.Utils.SendMessageToQueue(ctx, h.SQSClient, h.QueueURL, string(messageBodyWithRetry), messageID)
+	}
+	if err != nil {
 		return fmt.Errorf("Error reenviando mensaje a SQS: %w", err)
 	}
 	return nil
@@ -10,6 +10,7 @@ import (
 	"gmf_message_processor/internal/utils"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/stretchr/testify/mock"
@@ -42,6 +43,17 @@ func (m *MockUtils) SendMessageToQueue(
 	return args.Error(0)
 }
 
+func (m *MockUtils) SendMessageToQueueWithDelay(
+	ctx context.Context,
+	client aws.SQSAPI,
+	queueURL string,
+	messageBody string,
+	messageID string,
+	delay time.Duration) error {
+	args := m.Called(ctx, client, queueURL, messageBody, messageID, delay)
+	return args.Error(0)
+}
+
 // Mock para el service
 type MockPlantillaService struct {
 	mock.Mock
@@ -569,3 +581,117 @@ func TestPrintSQSEventErrorMarshalling(t *testing.T) {
 		t.Errorf("Expected log message to contain: %q, but got: %q", expectedMessage, loggedMessage)
 	}
 }
+
+func TestRetryMessagePermanentErrorNotRetried(t *testing.T) {
+	mockUtils := new(MockUtils)
+	mockPlantillaService := new(MockPlantillaService)
+	mockSQSClient := new(MockSQSClient)
+	logger := &logs.LoggerAdapter{}
+
+	sqsHandler := NewSQSHandler(mockPlantillaService, mockSQSClient, mockUtils, logger, queueURL)
+
+	msg := &models.SQSMessage{IDPlantilla: "123", RetryCount: 0}
+	permanentErr := models.NewPermanentError(fmt.Errorf("direcciones de correo inválidas"))
+
+	err := sqsHandler.retryMessage(context.Background(), msg, "1", permanentErr)
+
+	if err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	if msg.RetryCount != 0 {
+		t.Errorf("Expected retry count 0, got: %d", msg.RetryCount)
+	}
+	mockUtils.AssertNotCalled(t, "SendMessageToQueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleRecoveryPermanentPanicNotRetried(t *testing.T) {
+	mockUtils := new(MockUtils)
+	mockPlantillaService := new(MockPlantillaService)
+	mockSQSClient := new(MockSQSClient)
+	logger := &logs.LoggerAdapter{}
+
+	sqsHandler := NewSQSHandler(mockPlantillaService, mockSQSClient, mockUtils, logger, queueURL)
+
+	func() {
+		defer sqsHandler.handleRecovery(&models.SQSMessage{IDPlantilla: "123"}, "1")
+		panic(models.NewPermanentError(fmt.Errorf("remitente inválido")))
+	}()
+
+	mockUtils.AssertNotCalled(t, "SendMessageToQueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// partialDeliveryError simula el error de entrega parcial del servicio de correo.
+type partialDeliveryError struct {
+	deferred []string
+}
+
+func (e *partialDeliveryError) Error() string {
+	return "entrega parcial"
+}
+
+func (e *partialDeliveryError) DeferredRecipients() []string {
+	return e.deferred
+}
+
+func TestRetryMessageOnlyDeferredRecipients(t *testing.T) {
+	mockUtils := new(MockUtils)
+	mockPlantillaService := new(MockPlantillaService)
+	mockSQSClient := new(MockSQSClient)
+	logger := &logs.LoggerAdapter{}
+
+	sqsHandler := NewSQSHandler(mockPlantillaService, mockSQSClient, mockUtils, logger, queueURL)
+
+	msg := &models.SQSMessage{IDPlantilla: "123"}
+	mockUtils.On(
+		"SendMessageToQueue",
+		mock.Anything, mockSQSClient, queueURL,
+		`{"id_plantilla":"123","parametros":null,"retry_count":1,"destinatarios":["b@test.com"]}`,
+		"1").Return(nil)
+
+	err := sqsHandler.retryMessage(
+		context.Background(), msg, "1", fmt.Errorf("envío: %w", &partialDeliveryError{deferred: []string{"b@test.com"}}))
+
+	if err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	mockUtils.AssertExpectations(t)
+}
+
+// deferredSendError simula una respuesta SMTP que pide diferir el reintento.
+type deferredSendError struct {
+	delay time.Duration
+}
+
+func (e *deferredSendError) Error() string {
+	return "421 4.7.0 demasiados mensajes"
+}
+
+func (e *deferredSendError) RetryDelay() time.Duration {
+	return e.delay
+}
+
+func TestRetryMessageWithRetryDelay(t *testing.T) {
+	mockUtils := new(MockUtils)
+	mockPlantillaService := new(MockPlantillaService)
+	mockSQSClient := new(MockSQSClient)
+	logger := &logs.LoggerAdapter{}
+
+	sqsHandler := NewSQSHandler(mockPlantillaService, mockSQSClient, mockUtils, logger, queueURL)
+
+	msg := &models.SQSMessage{IDPlantilla: "123"}
+	mockUtils.On(
+		"SendMessageToQueueWithDelay",
+		mock.Anything, mockSQSClient, queueURL,
+		`{"id_plantilla":"123","parametros":null,"retry_count":1}`,
+		"1", 10*time.Minute).Return(nil)
+
+	err := sqsHandler.retryMessage(
+		context.Background(), msg, "1", fmt.Errorf("envío: %w", &deferredSendError{delay: 10 * time.Minute}))
+
+	if err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	mockUtils.AssertExpectations(t)
+	mockUtils.AssertNotCalled(t, "SendMessageToQueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything)
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"gmf_message_processor/internal/models"
+	"gmf_message_processor/internal/notification"
+)
+
+// SuppressionStore agrega direcciones a la lista de supresión.
+type SuppressionStore interface {
+	AddSupresion(supresion *models.Supresion) error
+}
+
+// EventRecorder registra los eventos de entrega reportados por el proveedor.
+type EventRecorder interface {
+	RecordEvent(evento *models.EventoCorreo) error
+}
+
+// SendLookup busca el envío al que el proveedor asignó un identificador (el MessageId de SES).
+type SendLookup interface {
+	FindMessageID(proveedorMessageID string) (string, error)
+}
+
+// SESNotificationHandler procesa las notificaciones de rebote, queja y entrega de SES recibidas por SQS o SNS.
+// ProcessEvent también se utiliza para los rebotes DSN del envío por SMTP.
+type SESNotificationHandler struct {
+	Suppressions SuppressionStore
+	Events       EventRecorder
+	Sends        SendLookup
+	Logger       LogInterface
+}
+
+// Constructor del manejador de notificaciones de SES
+func NewSESNotificationHandler(
+	suppressions SuppressionStore,
+	events EventRecorder,
+	sends SendLookup,
+	logger LogInterface,
+) *SESNotificationHandler {
+	return &SESNotificationHandler{
+		Suppressions: suppressions,
+		Events:       events,
+		Sends:        sends,
+		Logger:       logger,
+	}
+}
+
+// HandleEvent recibe el evento de Lambda sin procesar y lo despacha según su origen (SQS o SNS).
+func (h *SESNotificationHandler) HandleEvent(ctx context.Context, payload json.RawMessage) error {
+	var probe struct {
+		Records []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return fmt.Errorf("error interpretando el evento de Lambda: %w", err)
+	}
+	if len(probe.Records) == 0 {
+		h.Logger.LogInfo("Evento de Lambda sin registros, no hay notificaciones que procesar", "")
+		return nil
+	}
+
+	switch probe.Records[0].EventSource {
+	case "aws:sns":
+		var snsEvent events.SNSEvent
+		if err := json.Unmarshal(payload, &snsEvent); err != nil {
+			return fmt.Errorf("error interpretando el evento SNS: %w", err)
+		}
+		return h.HandleSNSEvent(ctx, snsEvent)
+	default:
+		var sqsEvent events.SQSEvent
+		if err := json.Unmarshal(payload, &sqsEvent); err != nil {
+			return fmt.Errorf("error interpretando el evento SQS: %w", err)
+		}
+		return h.HandleLambdaEvent(ctx, sqsEvent)
+	}
+}
+
+// HandleLambdaEvent procesa las notificaciones recibidas por SQS (con o sin el sobre de SNS).
+func (h *SESNotificationHandler) HandleLambdaEvent(ctx context.Context, sqsEvent events.SQSEvent) error {
+	for _, record := range sqsEvent.Records {
+		if err := h.HandleNotification(ctx, []byte(record.Body), record.MessageId); err != nil {
+			h.Logger.LogError("Error procesando la notificación de SES", err, record.MessageId)
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleSNSEvent procesa las notificaciones recibidas directamente desde SNS.
+func (h *SESNotificationHandler) HandleSNSEvent(ctx context.Context, snsEvent events.SNSEvent) error {
+	for _, record := range snsEvent.Records {
+		if err := h.HandleNotification(ctx, []byte(record.SNS.Message), record.SNS.MessageID); err != nil {
+			h.Logger.LogError("Error procesando la notificación de SES", err, record.SNS.MessageID)
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleNotification registra los eventos de una notificación y suprime a los destinatarios con rebote
+// permanente o queja. Las notificaciones inválidas o no soportadas se descartan, ya que reintentarlas no cambia
+// el resultado; los errores de la base de datos se devuelven para que la notificación se reintente.
+func (h *SESNotificationHandler) HandleNotification(ctx context.Context, payload []byte, deliveryID string) error {
+	event, err := notification.ParseSESNotification(payload)
+	if err != nil {
+		if errors.Is(err, notification.ErrUnsupportedNotification) {
+			h.Logger.LogInfo(fmt.Sprintf("Notificación de SES ignorada: %v", err), deliveryID)
+			return nil
+		}
+		h.Logger.LogError("Notificación de SES inválida, se descarta", err, deliveryID)
+		return nil
+	}
+
+	return h.ProcessEvent(ctx, event, deliveryID)
+}
+
+// ProcessEvent registra los eventos de cada destinatario y suprime a los que tuvieron un rebote permanente o una
+// queja. Se utiliza tanto para las notificaciones de SES como para los DSN recibidos por SMTP.
+func (h *SESNotificationHandler) ProcessEvent(ctx context.Context, event *notification.Event, deliveryID string) error {
+	// El MessageId de SES registrado en el envío prevalece sobre el encabezado Message-ID, que SES puede reemplazar
+	if event.SESMessageID != "" && h.Sends != nil {
+		messageID, err := h.Sends.FindMessageID(event.SESMessageID)
+		if err != nil {
+			return fmt.Errorf("error buscando el envío del MessageId de SES %s: %w", event.SESMessageID, err)
+		}
+		if messageID != "" {
+			event.MessageID = messageID
+		}
+	}
+
+	messageID := event.MessageID
+	if messageID == "" {
+		messageID = deliveryID
+		h.Logger.LogInfo(fmt.Sprintf(
+			"No fue posible correlacionar la notificación %s con un mensaje de SQS", event.Source), deliveryID)
+	}
+
+	for _, recipient := range event.Recipients {
+		if err := h.Events.RecordEvent(&models.EventoCorreo{
+			MessageID:    event.MessageID,
+			SESMessageID: event.SESMessageID,
+			Tipo:         event.Type,
+			SubTipo:      event.SubType,
+			Destinatario: recipient.Address,
+			Estado:       recipient.Status,
+			Detalle:      recipient.Detail,
+			OcurridoEn:   event.OccurredAt,
+		}); err != nil {
+			return fmt.Errorf("error registrando el evento %s de %s: %w", event.Type, recipient.Address, err)
+		}
+
+		motivo := suppressionReason(event)
+		if motivo == "" {
+			continue
+		}
+		if err := h.Suppressions.AddSupresion(&models.Supresion{
+			Direccion: recipient.Address,
+			Motivo:    motivo,
+			Origen:    event.Source,
+		}); err != nil {
+			return fmt.Errorf("error suprimiendo la dirección %s: %w", recipient.Address, err)
+		}
+		h.Logger.LogInfo(fmt.Sprintf("Dirección %s agregada a la lista de supresión (%s)", recipient.Address, motivo),
+			messageID)
+	}
+
+	h.Logger.LogInfo(fmt.Sprintf("Notificación %s (%s) procesada para %d destinatario(s)",
+		event.Type, event.Source, len(event.Recipients)), messageID)
+	return nil
+}
+
+// suppressionReason devuelve el motivo de supresión del evento, o "" si el destinatario no debe suprimirse.
+func suppressionReason(event *notification.Event) string {
+	switch {
+	case event.Type == notification.EventBounce && event.Permanent:
+		return models.MotivoSupresionRebote
+	case event.Type == notification.EventComplaint:
+		return models.MotivoSupresionQueja
+	default:
+		return ""
+	}
+}
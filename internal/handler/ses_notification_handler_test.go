@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"gmf_message_processor/internal/notification"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mocks en memoria de la lista de supresión y del registro de eventos.
+type mockSuppressionStore struct {
+	supresiones []*models.Supresion
+	err         error
+}
+
+func (m *mockSuppressionStore) AddSupresion(supresion *models.Supresion) error {
+	m.supresiones = append(m.supresiones, supresion)
+	return m.err
+}
+
+type mockEventRecorder struct {
+	eventos []*models.EventoCorreo
+	err     error
+}
+
+func (m *mockEventRecorder) RecordEvent(evento *models.EventoCorreo) error {
+	m.eventos = append(m.eventos, evento)
+	return m.err
+}
+
+type mockSendLookup struct {
+	messageIDs map[string]string
+	err        error
+}
+
+func (m *mockSendLookup) FindMessageID(proveedorMessageID string) (string, error) {
+	return m.messageIDs[proveedorMessageID], m.err
+}
+
+func readSESFixture(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "ses", name))
+	if err != nil {
+		t.Fatalf("Error leyendo el fixture %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func sqsEventWithBodies(bodies ...string) events.SQSEvent {
+	var event events.SQSEvent
+	for i, body := range bodies {
+		event.Records = append(event.Records, events.SQSMessage{
+			MessageId:   "sqs-notificacion-" + string(rune('a'+i)),
+			Body:        body,
+			EventSource: "aws:sqs",
+		})
+	}
+	return event
+}
+
+func TestSESNotificationHandlerSuppressesHardBounceAndComplaint(t *testing.T) {
+	suppressions := &mockSuppressionStore{}
+	eventos := &mockEventRecorder{}
+	h := NewSESNotificationHandler(suppressions, eventos, nil, &logs.LoggerAdapter{})
+
+	err := h.HandleLambdaEvent(context.TODO(), sqsEventWithBodies(
+		readSESFixture(t, "sns_bounce_envelope.json"),
+		readSESFixture(t, "complaint.json"),
+		readSESFixture(t, "bounce_transient.json"),
+	))
+
+	assert.NoError(t, err)
+	assert.Len(t, suppressions.supresiones, 2)
+	assert.Equal(t, "noexiste@example.com", suppressions.supresiones[0].Direccion)
+	assert.Equal(t, models.MotivoSupresionRebote, suppressions.supresiones[0].Motivo)
+	assert.Equal(t, notification.SourceSES, suppressions.supresiones[0].Origen)
+	assert.Equal(t, "molesto@example.com", suppressions.supresiones[1].Direccion)
+	assert.Equal(t, models.MotivoSupresionQueja, suppressions.supresiones[1].Motivo)
+
+	// Todos los eventos, incluido el rebote temporal, se registran contra el envío original
+	assert.Len(t, eventos.eventos, 3)
+	assert.Equal(t, "e9cf1877-7fd5-4fba-8dc6-1ee9519355d4", eventos.eventos[0].MessageID)
+	assert.Equal(t, "Transient/MailboxFull", eventos.eventos[2].SubTipo)
+}
+
+func TestSESNotificationHandlerRecordsDelivery(t *testing.T) {
+	suppressions := &mockSuppressionStore{}
+	eventos := &mockEventRecorder{}
+	h := NewSESNotificationHandler(suppressions, eventos, nil, &logs.LoggerAdapter{})
+
+	snsEvent := events.SNSEvent{Records: []events.SNSEventRecord{{
+		EventSource: "aws:sns",
+		SNS:         events.SNSEntity{MessageID: "sns-1", Message: readSESFixture(t, "delivery.json")},
+	}}}
+	payload, err := json.Marshal(snsEvent)
+	if err != nil {
+		t.Fatalf("Error serializando el evento SNS: %v", err)
+	}
+
+	err = h.HandleEvent(context.TODO(), payload)
+
+	assert.NoError(t, err)
+	assert.Empty(t, suppressions.supresiones)
+	assert.Len(t, eventos.eventos, 2)
+	assert.Equal(t, "Delivery", eventos.eventos[0].Tipo)
+	assert.Equal(t, "a@example.com", eventos.eventos[0].Destinatario)
+}
+
+func TestSESNotificationHandlerDispatchesSQSEvent(t *testing.T) {
+	eventos := &mockEventRecorder{}
+	h := NewSESNotificationHandler(&mockSuppressionStore{}, eventos, nil, &logs.LoggerAdapter{})
+
+	payload, err := json.Marshal(sqsEventWithBodies(readSESFixture(t, "delivery.json")))
+	if err != nil {
+		t.Fatalf("Error serializando el evento SQS: %v", err)
+	}
+
+	assert.NoError(t, h.HandleEvent(context.TODO(), payload))
+	assert.Len(t, eventos.eventos, 2)
+	assert.NoError(t, h.HandleEvent(context.TODO(), []byte(`{"Records":[]}`)))
+	assert.Error(t, h.HandleEvent(context.TODO(), []byte(`no es json`)))
+}
+
+func TestSESNotificationHandlerDiscardsInvalidPayloads(t *testing.T) {
+	eventos := &mockEventRecorder{}
+	h := NewSESNotificationHandler(&mockSuppressionStore{}, eventos, nil, &logs.LoggerAdapter{})
+
+	err := h.HandleLambdaEvent(context.TODO(), sqsEventWithBodies(
+		"no es json",
+		readSESFixture(t, "send_event.json"),
+	))
+
+	assert.NoError(t, err)
+	assert.Empty(t, eventos.eventos)
+}
+
+func TestSESNotificationHandlerStoreErrorIsRetried(t *testing.T) {
+	suppressions := &mockSuppressionStore{err: errors.New("conexión cerrada")}
+	h := NewSESNotificationHandler(suppressions, &mockEventRecorder{}, nil, &logs.LoggerAdapter{})
+
+	err := h.HandleLambdaEvent(context.TODO(), sqsEventWithBodies(readSESFixture(t, "bounce_permanent.json")))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "noexiste@example.com")
+}
+
+func TestSESNotificationHandlerCorrelatesBySESMessageID(t *testing.T) {
+	eventos := &mockEventRecorder{}
+	sends := &mockSendLookup{messageIDs: map[string]string{
+		"0100018e7c2b0f12-6f7a8b9c-0d1e-4f2a-3b4c-5d6e7f8a9b0c-000000": "sqs-envio-original",
+	}}
+	h := NewSESNotificationHandler(&mockSuppressionStore{}, eventos, sends, &logs.LoggerAdapter{})
+
+	err := h.HandleLambdaEvent(context.TODO(), sqsEventWithBodies(
+		readSESFixture(t, "delivery.json"),
+		readSESFixture(t, "complaint.json"),
+	))
+
+	assert.NoError(t, err)
+	// El envío registrado prevalece sobre el encabezado; sin envío registrado se usa el encabezado
+	assert.Equal(t, "sqs-envio-original", eventos.eventos[0].MessageID)
+	assert.Equal(t, "0100018e7c2b0f12-6f7a8b9c-0d1e-4f2a-3b4c-5d6e7f8a9b0c-000000", eventos.eventos[0].SESMessageID)
+	assert.Equal(t, "7d8e9f0a-1b2c-4d3e-9f0a-1b2c3d4e5f6a", eventos.eventos[len(eventos.eventos)-1].MessageID)
+}
+
+func TestSESNotificationHandlerSendLookupErrorIsRetried(t *testing.T) {
+	eventos := &mockEventRecorder{}
+	sends := &mockSendLookup{err: errors.New("conexión cerrada")}
+	h := NewSESNotificationHandler(&mockSuppressionStore{}, eventos, sends, &logs.LoggerAdapter{})
+
+	err := h.HandleLambdaEvent(context.TODO(), sqsEventWithBodies(readSESFixture(t, "delivery.json")))
+
+	assert.Error(t, err)
+	assert.Empty(t, eventos.eventos)
+}
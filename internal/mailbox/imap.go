@@ -0,0 +1,218 @@
+// Package mailbox implementa un cliente IMAP4rev1 mínimo para leer los rebotes (DSN) del buzón de retorno.
+package mailbox
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IMAPClient es una conexión IMAP autenticada. Sólo implementa los comandos necesarios para leer los mensajes
+// no leídos de un buzón y marcarlos como leídos.
+type IMAPClient struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	tag     int
+}
+
+// response es una respuesta no etiquetada ("* ...") junto con los literales {n} que contiene.
+type response struct {
+	line     string
+	literals [][]byte
+}
+
+// DialIMAP abre la conexión con el servidor (con TLS implícito si useTLS es true) y lee el saludo inicial.
+func DialIMAP(addr string, useTLS bool, timeout time.Duration) (*IMAPClient, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error conectando con el servidor IMAP %s: %w", addr, err)
+	}
+
+	client := &IMAPClient{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+	client.extendDeadline()
+	greeting, err := client.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error leyendo el saludo del servidor IMAP: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("saludo inesperado del servidor IMAP: %q", greeting)
+	}
+	return client, nil
+}
+
+// Login autentica al usuario con el comando LOGIN.
+func (c *IMAPClient) Login(username, password string) error {
+	_, err := c.command("LOGIN " + quote(username) + " " + quote(password))
+	if err != nil {
+		return fmt.Errorf("error de autenticación IMAP: %w", err)
+	}
+	return nil
+}
+
+// Select abre el buzón indicado en modo lectura y escritura.
+func (c *IMAPClient) Select(mailbox string) error {
+	if _, err := c.command("SELECT " + quote(mailbox)); err != nil {
+		return fmt.Errorf("error seleccionando el buzón %s: %w", mailbox, err)
+	}
+	return nil
+}
+
+// SearchUnseen devuelve los UID de los mensajes no leídos del buzón seleccionado.
+func (c *IMAPClient) SearchUnseen() ([]uint32, error) {
+	responses, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, fmt.Errorf("error buscando los mensajes no leídos: %w", err)
+	}
+
+	var uids []uint32
+	for _, r := range responses {
+		fields := strings.Fields(r.line)
+		if len(fields) < 2 || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, field := range fields[2:] {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("UID inválido en la respuesta SEARCH: %q", field)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids, nil
+}
+
+// FetchMessage descarga el mensaje completo sin marcarlo como leído (BODY.PEEK[]).
+func (c *IMAPClient) FetchMessage(uid uint32) ([]byte, error) {
+	responses, err := c.command(fmt.Sprintf("UID FETCH %d (BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, fmt.Errorf("error descargando el mensaje %d: %w", uid, err)
+	}
+	for _, r := range responses {
+		if strings.Contains(strings.ToUpper(r.line), " FETCH ") && len(r.literals) > 0 {
+			return r.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("el servidor no devolvió el mensaje %d", uid)
+}
+
+// MarkSeen marca el mensaje como leído para no procesarlo de nuevo.
+func (c *IMAPClient) MarkSeen(uid uint32) error {
+	if _, err := c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid)); err != nil {
+		return fmt.Errorf("error marcando el mensaje %d como leído: %w", uid, err)
+	}
+	return nil
+}
+
+// Logout cierra la sesión y la conexión.
+func (c *IMAPClient) Logout() error {
+	_, err := c.command("LOGOUT")
+	closeErr := c.conn.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// command envía un comando etiquetado y devuelve las respuestas no etiquetadas recibidas antes de la respuesta
+// final. Las respuestas NO y BAD se devuelven como error.
+func (c *IMAPClient) command(cmd string) ([]response, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	c.extendDeadline()
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, err
+	}
+
+	var responses []response
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(r.line, tag+" ") {
+			responses = append(responses, r)
+			continue
+		}
+
+		status := strings.TrimPrefix(r.line, tag+" ")
+		if strings.HasPrefix(strings.ToUpper(status), "OK") {
+			return responses, nil
+		}
+		return nil, errors.New(status)
+	}
+}
+
+// readResponse lee una respuesta completa, incluidos los literales {n} que pueda contener.
+func (c *IMAPClient) readResponse() (response, error) {
+	var r response
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return r, err
+		}
+		r.line += line
+
+		size, ok := literalSize(line)
+		if !ok {
+			return r, nil
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, literal); err != nil {
+			return r, fmt.Errorf("error leyendo un literal IMAP de %d bytes: %w", size, err)
+		}
+		r.literals = append(r.literals, literal)
+	}
+}
+
+func (c *IMAPClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *IMAPClient) extendDeadline() {
+	if c.timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+// literalSize detecta un literal al final de la línea ("... {123}").
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	start := strings.LastIndex(line, "{")
+	if start < 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(line[start+1 : len(line)-1])
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// quote devuelve la cadena como "quoted string" de IMAP.
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
@@ -0,0 +1,182 @@
+package mailbox
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIMAPServer simula un servidor IMAP con un único buzón para las pruebas.
+type fakeIMAPServer struct {
+	listener net.Listener
+	password string
+	messages map[uint32]string
+
+	mu       sync.Mutex
+	seen     map[uint32]bool
+	commands []string
+}
+
+func newFakeIMAPServer(t *testing.T, messages map[uint32]string) *fakeIMAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error iniciando el servidor IMAP de prueba: %v", err)
+	}
+	server := &fakeIMAPServer{
+		listener: listener,
+		password: "secreto",
+		messages: messages,
+		seen:     map[uint32]bool{},
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *fakeIMAPServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeIMAPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *fakeIMAPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK IMAP4rev1 listo\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(strings.TrimSpace(line))
+		if len(fields) < 2 {
+			continue
+		}
+		tag, cmd := fields[0], strings.ToUpper(strings.Join(fields[1:], " "))
+
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.mu.Unlock()
+
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN"):
+			if !strings.Contains(line, `"`+s.password+`"`) {
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] credenciales inválidas\r\n", tag)
+				continue
+			}
+			fmt.Fprintf(conn, "%s OK LOGIN completado\r\n", tag)
+		case strings.HasPrefix(cmd, "SELECT"):
+			fmt.Fprintf(conn, "* %d EXISTS\r\n%s OK [READ-WRITE] SELECT completado\r\n", len(s.messages), tag)
+		case cmd == "UID SEARCH UNSEEN":
+			var uids []string
+			s.mu.Lock()
+			for uid := uint32(1); uid <= uint32(len(s.messages)); uid++ {
+				if _, ok := s.messages[uid]; ok && !s.seen[uid] {
+					uids = append(uids, strconv.Itoa(int(uid)))
+				}
+			}
+			s.mu.Unlock()
+			fmt.Fprintf(conn, "* SEARCH %s\r\n%s OK SEARCH completado\r\n", strings.Join(uids, " "), tag)
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			uid, _ := strconv.Atoi(fields[3])
+			body := s.messages[uint32(uid)]
+			fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n%s OK FETCH completado\r\n",
+				uid, uid, len(body), body, tag)
+		case strings.HasPrefix(cmd, "UID STORE"):
+			uid, _ := strconv.Atoi(fields[3])
+			s.mu.Lock()
+			s.seen[uint32(uid)] = true
+			s.mu.Unlock()
+			fmt.Fprintf(conn, "%s OK STORE completado\r\n", tag)
+		case cmd == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completado\r\n", tag)
+			return
+		default:
+			fmt.Fprintf(conn, "%s BAD comando desconocido\r\n", tag)
+		}
+	}
+}
+
+func TestIMAPClientFetchesUnseenMessages(t *testing.T) {
+	message := "From: MAILER-DAEMON@test.com\r\nSubject: Undelivered\r\n\r\nCuerpo {con llaves}\r\n"
+	server := newFakeIMAPServer(t, map[uint32]string{1: message, 2: "Subject: otro\r\n\r\nx\r\n"})
+
+	client, err := DialIMAP(server.addr(), false, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Error conectando con el servidor de prueba: %v", err)
+	}
+
+	assert.NoError(t, client.Login("rebotes@gmf.com.co", "secreto"))
+	assert.NoError(t, client.Select("INBOX"))
+
+	uids, err := client.SearchUnseen()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2}, uids)
+
+	raw, err := client.FetchMessage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, message, string(raw))
+
+	assert.NoError(t, client.MarkSeen(1))
+	uids, err = client.SearchUnseen()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{2}, uids)
+
+	assert.NoError(t, client.Logout())
+	assert.Contains(t, server.received(), `UID FETCH 1 (BODY.PEEK[])`)
+	assert.Contains(t, server.received(), `UID STORE 1 +FLAGS.SILENT (\SEEN)`)
+}
+
+func TestIMAPClientLoginRejected(t *testing.T) {
+	server := newFakeIMAPServer(t, map[uint32]string{})
+
+	client, err := DialIMAP(server.addr(), false, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Error conectando con el servidor de prueba: %v", err)
+	}
+
+	err = client.Login("rebotes@gmf.com.co", "incorrecta")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "AUTHENTICATIONFAILED")
+}
+
+func TestDialIMAPConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error reservando un puerto: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = DialIMAP(addr, false, time.Second)
+	assert.Error(t, err)
+}
+
+func TestLiteralSize(t *testing.T) {
+	size, ok := literalSize("* 1 FETCH (BODY[] {42}")
+	assert.True(t, ok)
+	assert.Equal(t, 42, size)
+
+	_, ok = literalSize("* OK listo")
+	assert.False(t, ok)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c"`, quote(`a"b\c`))
+}
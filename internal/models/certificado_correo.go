@@ -0,0 +1,25 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// CertificadoCorreo es el certificado S/MIME (PEM) de un destinatario, con el que se cifran los correos de las
+// plantillas que lo requieren.
+type CertificadoCorreo struct {
+	Direccion   string    `json:"Direccion" gorm:"type:varchar(320);not null;primaryKey"`
+	Certificado string    `json:"Certificado" gorm:"type:text;not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo CertificadoCorreo.
+func (CertificadoCorreo) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_certificados"
+	}
+	return fmt.Sprintf("%s.cgd_correos_certificados", schema)
+}
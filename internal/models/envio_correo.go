@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Estados posibles de un intento de envío. Un envío omitido no llegó a ningún proveedor (por ejemplo, porque todos
+// los destinatarios estaban suprimidos).
+const (
+	EstadoEnvioEntregado = "ENTREGADO"
+	EstadoEnvioFallido   = "FALLIDO"
+	EstadoEnvioOmitido   = "OMITIDO"
+)
+
+// ProveedorNinguno es el proveedor registrado en los envíos omitidos.
+const ProveedorNinguno = "ninguno"
+
+// EnvioCorreo registra cada intento de envío de un mensaje y el proveedor que lo realizó. ProveedorMessageID es el
+// identificador que el proveedor asignó al correo (el MessageId de SES), con el que se correlacionan sus
+// notificaciones.
+type EnvioCorreo struct {
+	IDEnvio            uint      `json:"IDEnvio" gorm:"primaryKey;autoIncrement"`
+	MessageID          string    `json:"MessageID" gorm:"type:varchar(100);not null;index"`
+	Proveedor          string    `json:"Proveedor" gorm:"type:varchar(50);not null"`
+	ProveedorMessageID string    `json:"ProveedorMessageID" gorm:"type:varchar(100);index"`
+	Remitente          string    `json:"Remitente" gorm:"type:varchar(100)"`
+	Destinatarios      string    `json:"Destinatarios" gorm:"type:varchar(1000)"`
+	Estado             string    `json:"Estado" gorm:"type:varchar(20);not null"`
+	Error              string    `json:"Error" gorm:"type:text"`
+	CreatedAt          time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo EnvioCorreo.
+func (EnvioCorreo) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_envios"
+	}
+	return fmt.Sprintf("%s.cgd_correos_envios", schema)
+}
@@ -0,0 +1,31 @@
+package models
+
+import "errors"
+
+// PermanentError indica un error que no se resuelve reintentando el mensaje
+// (por ejemplo, datos inválidos enviados por el productor).
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// NewPermanentError marca un error como permanente.
+func NewPermanentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanentError indica si el error (o alguno de los errores que envuelve) es permanente.
+func IsPermanentError(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
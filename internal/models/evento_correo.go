@@ -0,0 +1,31 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// EventoCorreo registra un evento reportado por el proveedor (entrega, rebote o queja) para un destinatario,
+// asociado al MessageId de SQS del envío original.
+type EventoCorreo struct {
+	IDEvento     uint      `json:"IDEvento" gorm:"primaryKey;autoIncrement"`
+	MessageID    string    `json:"MessageID" gorm:"type:varchar(100);index"`
+	SESMessageID string    `json:"SESMessageID" gorm:"type:varchar(100)"`
+	Tipo         string    `json:"Tipo" gorm:"type:varchar(20);not null"`
+	SubTipo      string    `json:"SubTipo" gorm:"type:varchar(50)"`
+	Destinatario string    `json:"Destinatario" gorm:"type:varchar(255);not null"`
+	Estado       string    `json:"Estado" gorm:"type:varchar(20)"`
+	Detalle      string    `json:"Detalle" gorm:"type:text"`
+	OcurridoEn   time.Time `json:"OcurridoEn"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo EventoCorreo.
+func (EventoCorreo) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_eventos"
+	}
+	return fmt.Sprintf("%s.cgd_correos_eventos", schema)
+}
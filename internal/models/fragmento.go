@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Tipos de fragmento compartido entre plantillas.
+const (
+	// TipoFragmentoLayout envuelve el cuerpo de la plantilla; su contenido incluye el cuerpo con {{> cuerpo}}.
+	TipoFragmentoLayout = "layout"
+	// TipoFragmentoParcial es un bloque que las plantillas, los layouts y otros parciales incluyen con {{> nombre}}.
+	TipoFragmentoParcial = "partial"
+)
+
+// Fragmento es un layout o un parcial compartido por las plantillas (encabezado, pie, aviso legal, estilos). Se
+// identifica por Nombre e Idioma, igual que las plantillas. Version es el número de la versión activa en
+// cgd_correos_fragmentos_versiones (0 si el fragmento no tiene versiones registradas).
+type Fragmento struct {
+	Nombre    string    `json:"Nombre" gorm:"type:varchar(100);not null;primaryKey"`
+	Idioma    string    `json:"Idioma" gorm:"type:varchar(20);not null;primaryKey;default:es-CO"`
+	Tipo      string    `json:"Tipo" gorm:"type:varchar(10);not null;default:partial"`
+	Contenido string    `json:"Contenido" gorm:"type:text;not null"`
+	Version   int       `json:"Version" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo Fragmento.
+func (Fragmento) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_fragmentos"
+	}
+	return fmt.Sprintf("%s.cgd_correos_fragmentos", schema)
+}
+
+// FragmentoVersion es una versión histórica de un fragmento. Cada fragmento e idioma tiene a lo sumo una versión
+// activa (índice único parcial), que es la que se incluye en las plantillas.
+type FragmentoVersion struct {
+	Nombre    string    `json:"Nombre" gorm:"type:varchar(100);not null;primaryKey;uniqueIndex:cgd_correos_fragmentos_versiones_activa,where:activa"`
+	Idioma    string    `json:"Idioma" gorm:"type:varchar(20);not null;primaryKey;default:es-CO;uniqueIndex:cgd_correos_fragmentos_versiones_activa,where:activa"`
+	Version   int       `json:"Version" gorm:"not null;primaryKey;autoIncrement:false"`
+	Tipo      string    `json:"Tipo" gorm:"type:varchar(10);not null;default:partial"`
+	Contenido string    `json:"Contenido" gorm:"type:text;not null"`
+	Activa    bool      `json:"Activa" gorm:"type:boolean;not null;default:false"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo FragmentoVersion.
+func (FragmentoVersion) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_fragmentos_versiones"
+	}
+	return fmt.Sprintf("%s.cgd_correos_fragmentos_versiones", schema)
+}
+
+// NewFragmentoVersion copia el contenido del fragmento en una versión con el número indicado.
+func NewFragmentoVersion(fragmento *Fragmento, version int) *FragmentoVersion {
+	return &FragmentoVersion{
+		Nombre:    fragmento.Nombre,
+		Idioma:    fragmento.Idioma,
+		Version:   version,
+		Tipo:      fragmento.Tipo,
+		Contenido: fragmento.Contenido,
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultIdioma es el idioma de las plantillas cuando DEFAULT_LOCALE no está definido.
+const defaultIdioma = "es-CO"
+
+// DefaultIdioma devuelve el idioma predeterminado de las plantillas (DEFAULT_LOCALE, por defecto es-CO).
+func DefaultIdioma() string {
+	if idioma := NormalizeIdioma(os.Getenv("DEFAULT_LOCALE")); idioma != "" {
+		return idioma
+	}
+	return defaultIdioma
+}
+
+// NormalizeIdioma escribe la etiqueta de idioma en su forma canónica: el idioma en minúsculas, la escritura con
+// mayúscula inicial y la región en mayúsculas ("EN_us" → "en-US", "zh-hant-tw" → "zh-Hant-TW").
+func NormalizeIdioma(idioma string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(idioma), "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// IdiomaChain devuelve los idiomas en los que se busca una plantilla, en orden de preferencia: el solicitado, sus
+// versiones más generales y por último el predeterminado (por ejemplo, en-US → en → es-CO).
+func IdiomaChain(idioma string) []string {
+	var chain []string
+	seen := map[string]bool{}
+	add := func(candidate string) {
+		if candidate != "" && !seen[candidate] {
+			seen[candidate] = true
+			chain = append(chain, candidate)
+		}
+	}
+
+	for candidate := NormalizeIdioma(idioma); candidate != ""; {
+		add(candidate)
+		index := strings.LastIndex(candidate, "-")
+		if index < 0 {
+			break
+		}
+		candidate = candidate[:index]
+	}
+	add(DefaultIdioma())
+	return chain
+}
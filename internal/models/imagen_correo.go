@@ -0,0 +1,26 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ImagenCorreo es una imagen que las plantillas referencian como cid:<Nombre> y que se incluye en el correo como
+// parte inline, de modo que se muestre aunque el cliente bloquee las imágenes remotas.
+type ImagenCorreo struct {
+	Nombre      string    `json:"Nombre" gorm:"type:varchar(100);not null;primaryKey"`
+	ContentType string    `json:"ContentType" gorm:"type:varchar(100);not null"`
+	Contenido   []byte    `json:"Contenido" gorm:"type:bytea;not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo ImagenCorreo.
+func (ImagenCorreo) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_imagenes"
+	}
+	return fmt.Sprintf("%s.cgd_correos_imagenes", schema)
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -62,6 +65,38 @@ func TestSQSMessageModelInitialization(t *testing.T) {
 	assert.Equal(t, "Sistema X", param.Valor)
 
 }
+
+func TestParametrosSQSStructuredValues(t *testing.T) {
+	body := `{"id_plantilla": "PC001", "parametros": [
+		{"nombre": "archivo", "valor": "pagos.csv"},
+		{"nombre": "total", "valor": 2},
+		{"nombre": "rechazos", "valor": [{"codigo": "E01", "descripcion": "Cuenta inválida", "valor": 1500.5}]},
+		{"nombre": "resumen", "valor": {"aceptados": 10}},
+		{"nombre": "observacion", "valor": null}
+	]}`
+
+	var msg SQSMessage
+	assert.NoError(t, json.Unmarshal([]byte(body), &msg))
+
+	assert.Equal(t, ParametrosSQS{Nombre: "archivo", Valor: "pagos.csv"}, msg.Parametro[0])
+	assert.Equal(t, ParametrosSQS{Nombre: "total", Valor: "2"}, msg.Parametro[1])
+	assert.Equal(t, `[{"codigo":"E01","descripcion":"Cuenta inválida","valor":1500.5}]`, msg.Parametro[2].Valor)
+	assert.Equal(t, []any{map[string]any{
+		"codigo": "E01", "descripcion": "Cuenta inválida", "valor": json.Number("1500.5"),
+	}}, msg.Parametro[2].Datos)
+	assert.Equal(t, map[string]any{"aceptados": json.Number("10")}, msg.Parametro[3].Datos)
+	assert.Equal(t, ParametrosSQS{Nombre: "observacion"}, msg.Parametro[4])
+
+	// Un reintento reenvía los valores estructurados tal como llegaron
+	encoded, err := json.Marshal(msg)
+	assert.NoError(t, err)
+	var retried SQSMessage
+	assert.NoError(t, json.Unmarshal(encoded, &retried))
+	assert.Equal(t, msg.Parametro, retried.Parametro)
+	assert.Contains(t, string(encoded), `{"nombre":"archivo","valor":"pagos.csv"}`)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"nombre": "x", "valor": [1,}`), &ParametrosSQS{}))
+}
 func TestPlantillaTableNameDefaultSchema(t *testing.T) {
 	// Limpiar la variable de entorno para simular el comportamiento por defecto
 	os.Unsetenv("DB_SCHEMA")
@@ -96,3 +131,27 @@ func TestPlantillaTableNameCustomSchema(t *testing.T) {
 	// Limpiar la variable de entorno después del test
 	os.Unsetenv("DB_SCHEMA")
 }
+
+func TestPermanentError(t *testing.T) {
+	base := errors.New("dato inválido")
+	err := fmt.Errorf("validando mensaje: %w", NewPermanentError(base))
+
+	assert.True(t, IsPermanentError(err))
+	assert.ErrorIs(t, err, base)
+	assert.False(t, IsPermanentError(base))
+	assert.Nil(t, NewPermanentError(nil))
+}
+
+func TestIdiomaChain(t *testing.T) {
+	t.Setenv("DEFAULT_LOCALE", "")
+
+	assert.Equal(t, []string{"en-US", "en", "es-CO"}, IdiomaChain("en_us"))
+	assert.Equal(t, []string{"es", "es-CO"}, IdiomaChain(" ES "))
+	assert.Equal(t, []string{"es-CO"}, IdiomaChain(""))
+	assert.Equal(t, []string{"es-CO", "es"}, IdiomaChain("es-co"))
+	assert.Equal(t, []string{"zh-Hant-TW", "zh-Hant", "zh", "es-CO"}, IdiomaChain("zh-hant-tw"))
+
+	t.Setenv("DEFAULT_LOCALE", "en_us")
+	assert.Equal(t, "en-US", DefaultIdioma())
+	assert.Equal(t, []string{"pt-BR", "pt", "en-US"}, IdiomaChain("pt-BR"))
+}
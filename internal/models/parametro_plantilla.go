@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Tipos de los parámetros declarados para una plantilla.
+const (
+	TipoParametroTexto  = "string"
+	TipoParametroFecha  = "date"
+	TipoParametroNumero = "number"
+	TipoParametroLista  = "list"
+	// TipoParametroJSON admite listas y objetos JSON, por ejemplo los registros de una tabla.
+	TipoParametroJSON = "json"
+)
+
+// ParametroPlantilla declara un parámetro que espera una plantilla: su tipo, si es requerido, su valor por defecto
+// (nil si no tiene) y una expresión regular opcional que debe cumplir cada valor.
+type ParametroPlantilla struct {
+	IDPlantilla  string    `json:"IDPlantilla" gorm:"type:char(5);not null;primaryKey"`
+	Nombre       string    `json:"Nombre" gorm:"type:varchar(100);not null;primaryKey"`
+	Tipo         string    `json:"Tipo" gorm:"type:varchar(10);not null;default:string"`
+	Requerido    bool      `json:"Requerido" gorm:"type:boolean;not null;default:false"`
+	ValorDefecto *string   `json:"ValorDefecto" gorm:"type:varchar(1000)"`
+	Patron       string    `json:"Patron" gorm:"type:varchar(255);not null;default:''"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo ParametroPlantilla.
+func (ParametroPlantilla) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_plantilla_parametros"
+	}
+	return fmt.Sprintf("%s.cgd_correos_plantilla_parametros", schema)
+}
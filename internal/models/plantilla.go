@@ -6,16 +6,34 @@ import (
 	"time"
 )
 
-// Plantilla representa la estructura del modelo de Plantilla.
+// Plantilla representa la estructura del modelo de Plantilla. Cada plantilla se identifica por IDPlantilla e Idioma
+// (por ejemplo, "es-CO" o "en"). Motor selecciona el motor de renderizado (legacy o html) y RenderizarDirecciones si
+// los parámetros se aplican también a Remitente y Destinatario. ModoParametros (fail, warn o default) decide qué
+// hacer ante marcadores sin resolver o parámetros no utilizados; en modo default los marcadores se completan con
+// ValorDefecto. FirmarSMIME y CifrarSMIME protegen con S/MIME los correos de la plantilla. Layout es el nombre del
+// fragmento que envuelve el cuerpo (vacío si no usa ninguno). Version es el número de la versión activa en
+// cgd_correos_plantillas_versiones (0 si la plantilla no tiene versiones registradas). CambiosSinVersionar no se
+// persiste: indica que la plantilla se sirvió desde su versión activa y cgd_correos_plantillas tiene ediciones sin
+// registrar como versión.
 type Plantilla struct {
-	IDPlantilla  string    `json:"IDPlantilla" gorm:"type:char(5);not null;primaryKey"`
-	Asunto       string    `json:"Asunto" gorm:"type:varchar(255);not null"`
-	Cuerpo       string    `json:"Cuerpo" gorm:"type:text;not null"`
-	Remitente    string    `json:"Remitente" gorm:"type:varchar(100);not null"`
-	Destinatario string    `json:"Destinatario" gorm:"type:varchar(1000)"`
-	Adjunto      bool      `json:"Adjunto" gorm:"type:boolean;not null"`
-	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	IDPlantilla           string    `json:"IDPlantilla" gorm:"type:char(5);not null;primaryKey"`
+	Idioma                string    `json:"Idioma" gorm:"type:varchar(20);not null;primaryKey;default:es-CO"`
+	Asunto                string    `json:"Asunto" gorm:"type:varchar(255);not null"`
+	Cuerpo                string    `json:"Cuerpo" gorm:"type:text;not null"`
+	Remitente             string    `json:"Remitente" gorm:"type:varchar(100);not null"`
+	Destinatario          string    `json:"Destinatario" gorm:"type:varchar(1000)"`
+	Adjunto               bool      `json:"Adjunto" gorm:"type:boolean;not null"`
+	Motor                 string    `json:"Motor" gorm:"column:motor;type:varchar(10);not null;default:legacy"`
+	RenderizarDirecciones bool      `json:"RenderizarDirecciones" gorm:"type:boolean;not null;default:false"`
+	ModoParametros        string    `json:"ModoParametros" gorm:"type:varchar(10);not null;default:warn"`
+	ValorDefecto          string    `json:"ValorDefecto" gorm:"type:varchar(255);not null;default:''"`
+	FirmarSMIME           bool      `json:"FirmarSMIME" gorm:"column:firmar_smime;type:boolean;not null;default:false"`
+	CifrarSMIME           bool      `json:"CifrarSMIME" gorm:"column:cifrar_smime;type:boolean;not null;default:false"`
+	Layout                string    `json:"Layout" gorm:"type:varchar(100);not null;default:''"`
+	Version               int       `json:"Version" gorm:"not null;default:0"`
+	CreatedAt             time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt             time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	CambiosSinVersionar   bool      `json:"-" gorm:"-"`
 }
 
 // TableName devuelve el nombre de la tabla para el modelo Plantilla.
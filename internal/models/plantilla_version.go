@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// PlantillaVersion es una versión histórica del contenido de una plantilla en un idioma. Cada plantilla e idioma
+// tiene a lo sumo una versión activa (índice único parcial), que es la que se envía.
+type PlantillaVersion struct {
+	IDPlantilla           string    `json:"IDPlantilla" gorm:"type:char(5);not null;primaryKey;uniqueIndex:cgd_correos_plantillas_versiones_activa,where:activa"`
+	Idioma                string    `json:"Idioma" gorm:"type:varchar(20);not null;primaryKey;default:es-CO;uniqueIndex:cgd_correos_plantillas_versiones_activa,where:activa"`
+	Version               int       `json:"Version" gorm:"not null;primaryKey;autoIncrement:false"`
+	Asunto                string    `json:"Asunto" gorm:"type:varchar(255);not null"`
+	Cuerpo                string    `json:"Cuerpo" gorm:"type:text;not null"`
+	Remitente             string    `json:"Remitente" gorm:"type:varchar(100);not null"`
+	Destinatario          string    `json:"Destinatario" gorm:"type:varchar(1000)"`
+	Adjunto               bool      `json:"Adjunto" gorm:"type:boolean;not null"`
+	Motor                 string    `json:"Motor" gorm:"column:motor;type:varchar(10);not null;default:legacy"`
+	RenderizarDirecciones bool      `json:"RenderizarDirecciones" gorm:"type:boolean;not null;default:false"`
+	ModoParametros        string    `json:"ModoParametros" gorm:"type:varchar(10);not null;default:warn"`
+	ValorDefecto          string    `json:"ValorDefecto" gorm:"type:varchar(255);not null;default:''"`
+	FirmarSMIME           bool      `json:"FirmarSMIME" gorm:"column:firmar_smime;type:boolean;not null;default:false"`
+	CifrarSMIME           bool      `json:"CifrarSMIME" gorm:"column:cifrar_smime;type:boolean;not null;default:false"`
+	Layout                string    `json:"Layout" gorm:"type:varchar(100);not null;default:''"`
+	Activa                bool      `json:"Activa" gorm:"type:boolean;not null;default:false"`
+	CreatedAt             time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo PlantillaVersion.
+func (PlantillaVersion) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_plantillas_versiones"
+	}
+	return fmt.Sprintf("%s.cgd_correos_plantillas_versiones", schema)
+}
+
+// NewPlantillaVersion copia el contenido de la plantilla en una versión con el número indicado.
+func NewPlantillaVersion(plantilla *Plantilla, version int) *PlantillaVersion {
+	return &PlantillaVersion{
+		IDPlantilla:           plantilla.IDPlantilla,
+		Idioma:                plantilla.Idioma,
+		Version:               version,
+		Asunto:                plantilla.Asunto,
+		Cuerpo:                plantilla.Cuerpo,
+		Remitente:             plantilla.Remitente,
+		Destinatario:          plantilla.Destinatario,
+		Adjunto:               plantilla.Adjunto,
+		Motor:                 plantilla.Motor,
+		RenderizarDirecciones: plantilla.RenderizarDirecciones,
+		ModoParametros:        plantilla.ModoParametros,
+		ValorDefecto:          plantilla.ValorDefecto,
+		FirmarSMIME:           plantilla.FirmarSMIME,
+		CifrarSMIME:           plantilla.CifrarSMIME,
+		Layout:                plantilla.Layout,
+	}
+}
+
+// Plantilla devuelve la plantilla con el contenido de la versión.
+func (v *PlantillaVersion) Plantilla() *Plantilla {
+	return &Plantilla{
+		IDPlantilla:           v.IDPlantilla,
+		Idioma:                v.Idioma,
+		Asunto:                v.Asunto,
+		Cuerpo:                v.Cuerpo,
+		Remitente:             v.Remitente,
+		Destinatario:          v.Destinatario,
+		Adjunto:               v.Adjunto,
+		Motor:                 v.Motor,
+		RenderizarDirecciones: v.RenderizarDirecciones,
+		ModoParametros:        v.ModoParametros,
+		ValorDefecto:          v.ValorDefecto,
+		FirmarSMIME:           v.FirmarSMIME,
+		CifrarSMIME:           v.CifrarSMIME,
+		Layout:                v.Layout,
+		Version:               v.Version,
+	}
+}
+
+// Matches indica si la plantilla tiene el mismo contenido que la versión.
+func (v *PlantillaVersion) Matches(plantilla *Plantilla) bool {
+	current := NewPlantillaVersion(plantilla, v.Version)
+	current.Activa, current.CreatedAt = v.Activa, v.CreatedAt
+	return *current == *v
+}
@@ -1,13 +1,84 @@
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ControlInvalidarCache es el mensaje de control que descarta las plantillas guardadas en la caché de la Lambda que
+// lo recibe: la indicada en id_plantilla o, si no se indica ninguna, todas.
+const ControlInvalidarCache = "invalidar_cache"
+
 // SQSMessage representa la estructura esperada de un mensaje recibido desde SQS.
 type SQSMessage struct {
 	IDPlantilla string          `json:"id_plantilla"`
 	Parametro   []ParametrosSQS `json:"parametros"`
 	RetryCount  int             `json:"retry_count"`
+	// Destinatarios limita el envío a un subconjunto de los destinatarios de la plantilla; las direcciones que
+	// no pertenecen a ella se descartan. Se usa al reintentar solo los destinatarios diferidos de una entrega parcial.
+	Destinatarios []string `json:"destinatarios,omitempty"`
+	// VersionPlantilla fija la versión de la plantilla que se renderiza; 0 usa la versión activa.
+	VersionPlantilla int `json:"version_plantilla,omitempty"`
+	// Idioma selecciona la traducción de la plantilla (por ejemplo, en-US). Si no existe se usan sus versiones más
+	// generales y por último el idioma predeterminado; vacío usa el predeterminado.
+	Idioma string `json:"idioma,omitempty"`
+	// Control identifica un mensaje de control (por ejemplo, ControlInvalidarCache), que no envía ningún correo.
+	Control string `json:"control,omitempty"`
 }
 
+// ParametrosSQS es un parámetro del mensaje. El valor suele ser un texto, pero también puede ser una lista o un
+// objeto JSON (por ejemplo, los registros rechazados de un archivo): en ese caso Datos contiene el valor decodificado
+// y Valor su JSON. Los números y booleanos sueltos se reciben como texto.
 type ParametrosSQS struct {
 	Nombre string `json:"nombre"`
 	Valor  string `json:"valor"`
+	// Datos es el valor estructurado ([]any o map[string]any, con los números como json.Number); nil si el valor es
+	// un texto.
+	Datos any `json:"-"`
+}
+
+// UnmarshalJSON acepta como valor un texto, un número, un booleano, una lista o un objeto.
+func (p *ParametrosSQS) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Nombre string          `json:"nombre"`
+		Valor  json.RawMessage `json:"valor"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = ParametrosSQS{Nombre: raw.Nombre}
+	valor := bytes.TrimSpace(raw.Valor)
+	switch {
+	case len(valor) == 0 || bytes.Equal(valor, []byte("null")):
+	case valor[0] == '"':
+		return json.Unmarshal(valor, &p.Valor)
+	case valor[0] == '[' || valor[0] == '{':
+		decoder := json.NewDecoder(bytes.NewReader(valor))
+		decoder.UseNumber()
+		if err := decoder.Decode(&p.Datos); err != nil {
+			return fmt.Errorf("valor inválido para el parámetro %s: %w", raw.Nombre, err)
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, valor); err != nil {
+			return err
+		}
+		p.Valor = compact.String()
+	default:
+		p.Valor = string(valor)
+	}
+	return nil
+}
+
+// MarshalJSON conserva los valores estructurados, de modo que un reintento reenvía el mismo mensaje.
+func (p ParametrosSQS) MarshalJSON() ([]byte, error) {
+	var valor any = p.Valor
+	if p.Datos != nil {
+		valor = p.Datos
+	}
+	return json.Marshal(struct {
+		Nombre string `json:"nombre"`
+		Valor  any    `json:"valor"`
+	}{p.Nombre, valor})
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Motivos por los que una dirección se suprime.
+const (
+	MotivoSupresionRebote = "hard_bounce"
+	MotivoSupresionQueja  = "complaint"
+	MotivoSupresionManual = "manual"
+)
+
+// Supresion representa una dirección a la que no se deben enviar correos. Si ExpiraEn es nil la supresión es
+// indefinida.
+type Supresion struct {
+	Direccion string     `json:"Direccion" gorm:"type:varchar(255);not null;primaryKey"`
+	Motivo    string     `json:"Motivo" gorm:"type:varchar(50);not null"`
+	Origen    string     `json:"Origen" gorm:"type:varchar(50);not null"`
+	ExpiraEn  *time.Time `json:"ExpiraEn" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName devuelve el nombre de la tabla para el modelo Supresion.
+func (Supresion) TableName() string {
+	schema := os.Getenv("DB_SCHEMA")
+	if schema == "" || schema == "public" {
+		return "cgd_correos_supresiones"
+	}
+	return fmt.Sprintf("%s.cgd_correos_supresiones", schema)
+}
+
+// Vigente indica si la supresión aplica en el instante indicado.
+func (s *Supresion) Vigente(now time.Time) bool {
+	return s.ExpiraEn == nil || s.ExpiraEn.After(now)
+}
@@ -0,0 +1,233 @@
+package notification
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/email"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SourceDSN identifica los eventos obtenidos de notificaciones DSN recibidas por SMTP.
+const SourceDSN = "dsn"
+
+// ErrNotDSN indica que el correo no es un reporte multipart/report; report-type=delivery-status.
+var ErrNotDSN = errors.New("el correo no es una notificación de estado de entrega (DSN)")
+
+// DSNRecipient es el estado reportado para un destinatario (RFC 3464, campos por destinatario).
+type DSNRecipient struct {
+	Address        string
+	Action         string
+	Status         string
+	DiagnosticCode string
+}
+
+// DSN es una notificación de estado de entrega interpretada.
+type DSN struct {
+	ReportingMTA string
+	// OriginalMessageID es el encabezado Message-ID del correo original.
+	OriginalMessageID string
+	// MessageID es el MessageId de SQS del envío original. Vacío si el correo no fue generado por esta aplicación.
+	MessageID  string
+	Recipients []DSNRecipient
+	Date       time.Time
+}
+
+// FailedRecipients devuelve los destinatarios con Action: failed.
+func (d *DSN) FailedRecipients() []DSNRecipient {
+	var out []DSNRecipient
+	for _, recipient := range d.Recipients {
+		if recipient.Action == "failed" {
+			out = append(out, recipient)
+		}
+	}
+	return out
+}
+
+// Events convierte la notificación en eventos equivalentes a los de SES: los fallos con estado 5.x.x son rebotes
+// permanentes, los fallos o retrasos 4.x.x son temporales y las entregas exitosas se reportan como Delivery.
+func (d *DSN) Events() []*Event {
+	permanent := &Event{Type: EventBounce, Permanent: true, SubType: "Permanent/DSN"}
+	transient := &Event{Type: EventBounce, SubType: "Transient/DSN"}
+	delivered := &Event{Type: EventDelivery}
+
+	for _, recipient := range d.Recipients {
+		r := Recipient{Address: recipient.Address, Status: recipient.Status, Detail: recipient.DiagnosticCode}
+		switch {
+		case recipient.Action == "failed" && strings.HasPrefix(recipient.Status, "5"):
+			permanent.Recipients = append(permanent.Recipients, r)
+		case recipient.Action == "failed" || recipient.Action == "delayed":
+			transient.Recipients = append(transient.Recipients, r)
+		case recipient.Action == "delivered" || recipient.Action == "relayed" || recipient.Action == "expanded":
+			delivered.Recipients = append(delivered.Recipients, r)
+		}
+	}
+
+	var events []*Event
+	for _, event := range []*Event{permanent, transient, delivered} {
+		if len(event.Recipients) == 0 {
+			continue
+		}
+		event.Source = SourceDSN
+		event.MessageID = d.MessageID
+		event.OccurredAt = d.Date
+		events = append(events, event)
+	}
+	return events
+}
+
+// ParseDSN interpreta un correo multipart/report; report-type=delivery-status (RFC 3464).
+func ParseDSN(r io.Reader) (*DSN, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo el correo: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" ||
+		!strings.EqualFold(params["report-type"], "delivery-status") || params["boundary"] == "" {
+		return nil, ErrNotDSN
+	}
+
+	dsn := &DSN{}
+	if date, err := msg.Header.Date(); err == nil {
+		dsn.Date = date
+	}
+	statusFound := false
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo las partes del reporte: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(decodeTransferEncoding(part))
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo la parte %s: %w", partType, err)
+		}
+
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if err := parseDeliveryStatus(body, dsn); err != nil {
+				return nil, err
+			}
+			statusFound = true
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			dsn.OriginalMessageID = originalMessageIDHeader(body)
+		}
+	}
+
+	if !statusFound {
+		return nil, errors.New("error: el reporte no contiene la parte message/delivery-status")
+	}
+
+	// Algunos servidores no devuelven el correo original, pero sí lo referencian
+	if dsn.OriginalMessageID == "" {
+		dsn.OriginalMessageID = firstMessageID(msg.Header.Get("References"), msg.Header.Get("In-Reply-To"))
+	}
+	if messageID, ok := email.MessageIDFromHeader(dsn.OriginalMessageID); ok {
+		dsn.MessageID = messageID
+	}
+	return dsn, nil
+}
+
+// decodeTransferEncoding decodifica la parte si viene en base64 o quoted-printable. multipart.Reader solo lo hace
+// para quoted-printable.
+func decodeTransferEncoding(part *multipart.Part) io.Reader {
+	switch strings.ToLower(part.Header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, part)
+	case "quoted-printable":
+		return quotedprintable.NewReader(part)
+	default:
+		return part
+	}
+}
+
+// parseDeliveryStatus interpreta los bloques de campos del reporte: el primero corresponde al mensaje y los
+// siguientes a cada destinatario.
+func parseDeliveryStatus(body []byte, dsn *DSN) error {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(body)))
+
+	first := true
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			if first {
+				dsn.ReportingMTA = fieldValue(fields.Get("Reporting-MTA"))
+			} else if recipient := fieldValue(fields.Get("Final-Recipient")); recipient != "" {
+				dsn.Recipients = append(dsn.Recipients, DSNRecipient{
+					Address:        strings.ToLower(strings.Trim(recipient, "<>")),
+					Action:         strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+					Status:         statusCode(fields.Get("Status")),
+					DiagnosticCode: fieldValue(fields.Get("Diagnostic-Code")),
+				})
+			}
+			first = false
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error interpretando message/delivery-status: %w", err)
+		}
+	}
+
+	if len(dsn.Recipients) == 0 {
+		return errors.New("error: el reporte no contiene destinatarios (Final-Recipient)")
+	}
+	return nil
+}
+
+// fieldValue elimina el tipo ("rfc822;", "smtp;", "dns;") de los campos tipados del reporte.
+func fieldValue(value string) string {
+	if i := strings.Index(value, ";"); i >= 0 {
+		return strings.TrimSpace(value[i+1:])
+	}
+	return strings.TrimSpace(value)
+}
+
+// statusCode devuelve el código de estado sin comentarios (por ejemplo, "5.1.1 (user unknown)").
+func statusCode(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// originalMessageIDHeader obtiene el Message-ID de los encabezados del correo original incluido en el reporte.
+func originalMessageIDHeader(body []byte) string {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(body)))
+	headers, err := reader.ReadMIMEHeader()
+	if err != nil && len(headers) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(headers.Get("Message-Id"))
+}
+
+// firstMessageID devuelve el primer identificador <...> de los encabezados indicados.
+func firstMessageID(values ...string) string {
+	for _, value := range values {
+		start := strings.Index(value, "<")
+		end := strings.Index(value, ">")
+		if start >= 0 && end > start {
+			return value[start : end+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package notification
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readDSNFixture lee un DSN grabado en test_data/dsn.
+func readDSNFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "dsn", name))
+	if err != nil {
+		t.Fatalf("Error leyendo el fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestParseDSNPostfixBounce(t *testing.T) {
+	dsn, err := ParseDSN(bytes.NewReader(readDSNFixture(t, "postfix_hard_bounce.eml")))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "mx1.gmf.com.co", dsn.ReportingMTA)
+	assert.Equal(t, "<e9cf1877-7fd5-4fba-8dc6-1ee9519355d4.1728310860000000000@gmf.com.co>", dsn.OriginalMessageID)
+	assert.Equal(t, fixtureMessageID, dsn.MessageID)
+	assert.Len(t, dsn.Recipients, 2)
+	assert.Equal(t, DSNRecipient{
+		Address:        "noexiste@example.com",
+		Action:         "failed",
+		Status:         "5.1.1",
+		DiagnosticCode: "550 5.1.1 <noexiste@example.com>: Recipient address rejected: User unknown",
+	}, dsn.Recipients[0])
+	assert.Equal(t, "lleno@example.com", dsn.Recipients[1].Address)
+	assert.Equal(t, "4.2.2", dsn.Recipients[1].Status)
+	assert.Len(t, dsn.FailedRecipients(), 1)
+
+	events := dsn.Events()
+	assert.Len(t, events, 2)
+	assert.True(t, events[0].Permanent)
+	assert.Equal(t, SourceDSN, events[0].Source)
+	assert.Equal(t, fixtureMessageID, events[0].MessageID)
+	assert.Equal(t, "noexiste@example.com", events[0].Recipients[0].Address)
+	assert.False(t, events[1].Permanent)
+	assert.Equal(t, "lleno@example.com", events[1].Recipients[0].Address)
+	assert.Equal(t, 2024, events[0].OccurredAt.Year())
+}
+
+func TestParseDSNBase64Parts(t *testing.T) {
+	dsn, err := ParseDSN(bytes.NewReader(readDSNFixture(t, "exchange_quota_bounce.eml")))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "CO1PR10MB4611.namprd10.prod.outlook.com", dsn.ReportingMTA)
+	assert.Equal(t, "7d8e9f0a-1b2c-4d3e-9f0a-1b2c3d4e5f6a", dsn.MessageID)
+	assert.Equal(t, []DSNRecipient{{
+		Address:        "buzon@cliente.com.co",
+		Action:         "failed",
+		Status:         "5.2.2",
+		DiagnosticCode: "554 5.2.2 mailbox full; STOREDRV.Deliver.Exception:QuotaExceededException",
+	}}, dsn.Recipients)
+}
+
+func TestParseDSNNotAReport(t *testing.T) {
+	_, err := ParseDSN(bytes.NewReader(readDSNFixture(t, "not_a_dsn.eml")))
+	assert.True(t, errors.Is(err, ErrNotDSN))
+}
+
+func TestParseDSNWithoutOriginalUsesReferences(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: MAILER-DAEMON@mx.test.com",
+		"References: <abc-123.1728310860000000000@gmf.com.co>",
+		`Content-Type: multipart/report; report-type=delivery-status; boundary="b"`,
+		"",
+		"--b",
+		"Content-Type: message/delivery-status",
+		"",
+		"Reporting-MTA: dns; mx.test.com",
+		"",
+		"Final-Recipient: rfc822; a@test.com",
+		"Action: failed",
+		"Status: 5.0.0",
+		"--b--",
+		"",
+	}, "\r\n")
+
+	dsn, err := ParseDSN(strings.NewReader(raw))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "abc-123", dsn.MessageID)
+	assert.Equal(t, "5.0.0", dsn.Recipients[0].Status)
+}
+
+func TestParseDSNWithoutRecipients(t *testing.T) {
+	raw := strings.Join([]string{
+		`Content-Type: multipart/report; report-type=delivery-status; boundary="b"`,
+		"",
+		"--b",
+		"Content-Type: text/plain",
+		"",
+		"Sin reporte",
+		"--b--",
+		"",
+	}, "\r\n")
+
+	_, err := ParseDSN(strings.NewReader(raw))
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/email"
+	"strings"
+	"time"
+)
+
+// SourceSES identifica los eventos obtenidos de notificaciones de SES.
+const SourceSES = "ses"
+
+// Tipos de evento de SES que procesa la aplicación.
+const (
+	EventBounce    = "Bounce"
+	EventComplaint = "Complaint"
+	EventDelivery  = "Delivery"
+)
+
+// ErrUnsupportedNotification indica un tipo de notificación que la aplicación no procesa (por ejemplo, Send u Open).
+var ErrUnsupportedNotification = errors.New("tipo de notificación de SES no soportado")
+
+// snsEnvelope es el sobre de SNS cuando la notificación llega por SQS sin "raw message delivery".
+type snsEnvelope struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// sesNotification es el contenido publicado por SES, ya sea como notificación de identidad (notificationType)
+// o como evento de un configuration set (eventType).
+type sesNotification struct {
+	NotificationType string        `json:"notificationType"`
+	EventType        string        `json:"eventType"`
+	Mail             sesMail       `json:"mail"`
+	Bounce           *sesBounce    `json:"bounce"`
+	Complaint        *sesComplaint `json:"complaint"`
+	Delivery         *sesDelivery  `json:"delivery"`
+}
+
+type sesMail struct {
+	Timestamp     time.Time   `json:"timestamp"`
+	MessageID     string      `json:"messageId"`
+	Source        string      `json:"source"`
+	Destination   []string    `json:"destination"`
+	Headers       []sesHeader `json:"headers"`
+	CommonHeaders struct {
+		MessageID string `json:"messageId"`
+	} `json:"commonHeaders"`
+}
+
+type sesHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type sesBounce struct {
+	BounceType        string `json:"bounceType"`
+	BounceSubType     string `json:"bounceSubType"`
+	BouncedRecipients []struct {
+		EmailAddress   string `json:"emailAddress"`
+		Action         string `json:"action"`
+		Status         string `json:"status"`
+		DiagnosticCode string `json:"diagnosticCode"`
+	} `json:"bouncedRecipients"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type sesComplaint struct {
+	ComplainedRecipients []struct {
+		EmailAddress string `json:"emailAddress"`
+	} `json:"complainedRecipients"`
+	ComplaintFeedbackType string    `json:"complaintFeedbackType"`
+	Timestamp             time.Time `json:"timestamp"`
+}
+
+type sesDelivery struct {
+	Recipients   []string  `json:"recipients"`
+	SMTPResponse string    `json:"smtpResponse"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Recipient es el resultado reportado para un destinatario.
+type Recipient struct {
+	Address string
+	Status  string
+	Detail  string
+}
+
+// Event es una notificación de entrega ya interpretada (de SES o de un DSN).
+type Event struct {
+	Type string
+	// Source es el origen de la notificación: SourceSES o SourceDSN.
+	Source string
+	// SESMessageID es el identificador que SES asignó al correo.
+	SESMessageID string
+	// MessageID es el MessageId de SQS del envío original, obtenido del encabezado Message-ID. Vacío si el
+	// correo no fue generado por esta aplicación.
+	MessageID string
+	// Permanent indica un rebote permanente (hard bounce); los rebotes temporales no suprimen al destinatario.
+	Permanent  bool
+	SubType    string
+	Recipients []Recipient
+	OccurredAt time.Time
+}
+
+// ParseSESNotification interpreta una notificación de SES. Acepta el JSON de SES directamente o envuelto en
+// el sobre de SNS.
+func ParseSESNotification(payload []byte) (*Event, error) {
+	var envelope snsEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("error interpretando la notificación de SES: %w", err)
+	}
+	if envelope.Type == "Notification" && envelope.Message != "" {
+		payload = []byte(envelope.Message)
+	}
+
+	var notification sesNotification
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return nil, fmt.Errorf("error interpretando la notificación de SES: %w", err)
+	}
+
+	eventType := notification.NotificationType
+	if eventType == "" {
+		eventType = notification.EventType
+	}
+
+	event := &Event{
+		Type:         eventType,
+		Source:       SourceSES,
+		SESMessageID: notification.Mail.MessageID,
+		MessageID:    originalMessageID(notification.Mail),
+	}
+
+	switch eventType {
+	case EventBounce:
+		if notification.Bounce == nil {
+			return nil, errors.New("error: notificación Bounce sin el objeto bounce")
+		}
+		event.Permanent = notification.Bounce.BounceType == "Permanent"
+		event.SubType = notification.Bounce.BounceType + "/" + notification.Bounce.BounceSubType
+		event.OccurredAt = notification.Bounce.Timestamp
+		for _, r := range notification.Bounce.BouncedRecipients {
+			event.Recipients = append(event.Recipients, Recipient{
+				Address: r.EmailAddress,
+				Status:  r.Status,
+				Detail:  r.DiagnosticCode,
+			})
+		}
+	case EventComplaint:
+		if notification.Complaint == nil {
+			return nil, errors.New("error: notificación Complaint sin el objeto complaint")
+		}
+		event.SubType = notification.Complaint.ComplaintFeedbackType
+		event.OccurredAt = notification.Complaint.Timestamp
+		for _, r := range notification.Complaint.ComplainedRecipients {
+			event.Recipients = append(event.Recipients, Recipient{Address: r.EmailAddress})
+		}
+	case EventDelivery:
+		if notification.Delivery == nil {
+			return nil, errors.New("error: notificación Delivery sin el objeto delivery")
+		}
+		event.OccurredAt = notification.Delivery.Timestamp
+		for _, address := range notification.Delivery.Recipients {
+			event.Recipients = append(event.Recipients, Recipient{
+				Address: address,
+				Detail:  notification.Delivery.SMTPResponse,
+			})
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedNotification, eventType)
+	}
+
+	return event, nil
+}
+
+// originalMessageID obtiene el MessageId de SQS a partir del encabezado Message-ID del correo original.
+func originalMessageID(mail sesMail) string {
+	header := mail.CommonHeaders.MessageID
+	if header == "" {
+		for _, h := range mail.Headers {
+			if strings.EqualFold(h.Name, "Message-ID") {
+				header = h.Value
+				break
+			}
+		}
+	}
+
+	messageID, ok := email.MessageIDFromHeader(header)
+	if !ok {
+		return ""
+	}
+	return messageID
+}
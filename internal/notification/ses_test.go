@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const fixtureMessageID = "e9cf1877-7fd5-4fba-8dc6-1ee9519355d4"
+
+// readFixture lee una notificación de SES grabada en test_data/ses.
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("..", "..", "test_data", "ses", name))
+	if err != nil {
+		t.Fatalf("Error leyendo el fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestParseSESNotificationPermanentBounce(t *testing.T) {
+	event, err := ParseSESNotification(readFixture(t, "bounce_permanent.json"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, EventBounce, event.Type)
+	assert.True(t, event.Permanent)
+	assert.Equal(t, "Permanent/General", event.SubType)
+	assert.Equal(t, fixtureMessageID, event.MessageID)
+	assert.Equal(t, "0100018e7c2b0f11-2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d-000000", event.SESMessageID)
+	assert.Equal(t, []Recipient{{
+		Address: "noexiste@example.com",
+		Status:  "5.1.1",
+		Detail:  "smtp; 550 5.1.1 user unknown",
+	}}, event.Recipients)
+	assert.Equal(t, time.Date(2024, 10, 7, 14, 20, 31, 0, time.UTC), event.OccurredAt)
+}
+
+func TestParseSESNotificationTransientBounceUsesHeaders(t *testing.T) {
+	event, err := ParseSESNotification(readFixture(t, "bounce_transient.json"))
+
+	assert.NoError(t, err)
+	assert.False(t, event.Permanent)
+	assert.Equal(t, "Transient/MailboxFull", event.SubType)
+	// Sin commonHeaders.messageId se usa el encabezado original
+	assert.Equal(t, "5b1c9f3e-1a2b-4c3d-8e9f-0a1b2c3d4e5f", event.MessageID)
+}
+
+func TestParseSESNotificationComplaint(t *testing.T) {
+	event, err := ParseSESNotification(readFixture(t, "complaint.json"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, EventComplaint, event.Type)
+	assert.Equal(t, "abuse", event.SubType)
+	assert.Equal(t, "7d8e9f0a-1b2c-4d3e-9f0a-1b2c3d4e5f6a", event.MessageID)
+	assert.Equal(t, []Recipient{{Address: "molesto@example.com"}}, event.Recipients)
+}
+
+func TestParseSESNotificationDelivery(t *testing.T) {
+	event, err := ParseSESNotification(readFixture(t, "delivery.json"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, EventDelivery, event.Type)
+	assert.Len(t, event.Recipients, 2)
+	assert.Equal(t, "250 2.6.0 Message received", event.Recipients[1].Detail)
+	assert.Equal(t, fixtureMessageID, event.MessageID)
+}
+
+func TestParseSESNotificationSNSEnvelope(t *testing.T) {
+	event, err := ParseSESNotification(readFixture(t, "sns_bounce_envelope.json"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, EventBounce, event.Type)
+	assert.True(t, event.Permanent)
+	assert.Equal(t, fixtureMessageID, event.MessageID)
+}
+
+func TestParseSESNotificationUnsupported(t *testing.T) {
+	_, err := ParseSESNotification(readFixture(t, "send_event.json"))
+	assert.True(t, errors.Is(err, ErrUnsupportedNotification))
+
+	_, err = ParseSESNotification([]byte("no es json"))
+	assert.Error(t, err)
+
+	_, err = ParseSESNotification([]byte(`{"notificationType":"Bounce","mail":{}}`))
+	assert.Error(t, err)
+}
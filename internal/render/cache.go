@@ -0,0 +1,64 @@
+package render
+
+import (
+	"html/template"
+	"sync"
+	texttemplate "text/template"
+)
+
+// maxParsedTemplates limita las plantillas analizadas que se conservan; al alcanzarlo se descartan todas.
+const maxParsedTemplates = 256
+
+// parsedCache conserva las plantillas ya analizadas por html/template y text/template para no volver a analizarlas
+// en cada mensaje de una Lambda caliente. La clave incluye el texto, de modo que una plantilla editada se analiza de
+// nuevo sin necesidad de invalidar la caché.
+var parsedCache = struct {
+	sync.Mutex
+	html map[string]*template.Template
+	text map[string]*texttemplate.Template
+}{
+	html: map[string]*template.Template{},
+	text: map[string]*texttemplate.Template{},
+}
+
+func parsedKey(name, text string) string {
+	return name + "\x00" + text
+}
+
+func cachedHTML(name, text string) *template.Template {
+	parsedCache.Lock()
+	defer parsedCache.Unlock()
+	return parsedCache.html[parsedKey(name, text)]
+}
+
+func storeHTML(name, text string, tmpl *template.Template) {
+	parsedCache.Lock()
+	defer parsedCache.Unlock()
+	if len(parsedCache.html) >= maxParsedTemplates {
+		parsedCache.html = map[string]*template.Template{}
+	}
+	parsedCache.html[parsedKey(name, text)] = tmpl
+}
+
+func cachedText(name, text string) *texttemplate.Template {
+	parsedCache.Lock()
+	defer parsedCache.Unlock()
+	return parsedCache.text[parsedKey(name, text)]
+}
+
+func storeText(name, text string, tmpl *texttemplate.Template) {
+	parsedCache.Lock()
+	defer parsedCache.Unlock()
+	if len(parsedCache.text) >= maxParsedTemplates {
+		parsedCache.text = map[string]*texttemplate.Template{}
+	}
+	parsedCache.text[parsedKey(name, text)] = tmpl
+}
+
+// ResetCache descarta las plantillas analizadas.
+func ResetCache() {
+	parsedCache.Lock()
+	defer parsedCache.Unlock()
+	parsedCache.html = map[string]*template.Template{}
+	parsedCache.text = map[string]*texttemplate.Template{}
+}
@@ -0,0 +1,246 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	// Las Lambdas no siempre incluyen la base de datos de zonas horarias del sistema
+	_ "time/tzdata"
+)
+
+// defaultTimezone es la zona horaria de las fechas sin zona cuando TEMPLATE_TIMEZONE no está definido.
+const defaultTimezone = "America/Bogota"
+
+// dateFormats son los formatos con nombre que aceptan date y dateIn; cualquier otro formato se interpreta como un
+// layout de Go (por ejemplo, "2006-01-02").
+var dateFormats = map[string]string{
+	"fecha":      "02/01/2006",
+	"fecha_hora": "02/01/2006 03:04 PM",
+	"hora":       "03:04 PM",
+	"iso":        time.RFC3339,
+}
+
+// formatLongDate es el formato "largo" (7 de octubre de 2024), que no puede expresarse como layout de Go.
+const formatLongDate = "largo"
+
+var meses = [...]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre",
+	"octubre", "noviembre", "diciembre"}
+
+// timeLayouts son los formatos de entrada de date y dateIn: los de los parámetros de tipo date más fecha y hora, y
+// solo hora.
+var timeLayouts = append(append([]string{}, dateLayouts...),
+	"02/01/2006 15:04", "02/01/2006 03:04 PM", "02/01/2006 3:04 PM", "15:04", "15:04:05", "03:04 PM", "3:04 PM")
+
+// textValue convierte un parámetro en texto; las listas de textos se unen con ", " y los valores estructurados se
+// escriben como JSON.
+func textValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ", ")
+	case []any, map[string]any:
+		return jsonText(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// templateLocation devuelve la zona horaria de las plantillas (TEMPLATE_TIMEZONE, por defecto America/Bogota).
+func templateLocation() (*time.Location, error) {
+	name := strings.TrimSpace(os.Getenv("TEMPLATE_TIMEZONE"))
+	if name == "" {
+		name = defaultTimezone
+	}
+	return loadLocation(name)
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("zona horaria %q no válida: %w", name, err)
+	}
+	return location, nil
+}
+
+// formatDate interpreta el valor como fecha y/o hora y lo escribe en el formato indicado, en la zona horaria de las
+// plantillas: {{.fecha_recepcion | date "fecha"}}.
+func formatDate(format string, value any) (string, error) {
+	location, err := templateLocation()
+	if err != nil {
+		return "", err
+	}
+	return formatTime(format, location, value)
+}
+
+// formatDateIn es como formatDate pero convierte la fecha a la zona horaria indicada:
+// {{.fecha_envio | dateIn "fecha_hora" "America/New_York"}}.
+func formatDateIn(format, zone string, value any) (string, error) {
+	location, err := loadLocation(zone)
+	if err != nil {
+		return "", err
+	}
+	return formatTime(format, location, value)
+}
+
+func formatTime(format string, location *time.Location, value any) (string, error) {
+	layout, err := dateLayout(format)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(textValue(value))
+	if text == "" {
+		return "", nil
+	}
+	source, err := templateLocation()
+	if err != nil {
+		return "", err
+	}
+
+	// Las fechas sin zona horaria se interpretan en la zona de las plantillas
+	var parsed time.Time
+	for _, input := range timeLayouts {
+		if parsed, err = time.ParseInLocation(input, text, source); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%q no es una fecha válida", text)
+	}
+	// Una hora sin fecha no puede convertirse de zona horaria
+	if parsed.Year() != 0 {
+		parsed = parsed.In(location)
+	}
+
+	if layout == formatLongDate {
+		return fmt.Sprintf("%d de %s de %d", parsed.Day(), meses[parsed.Month()-1], parsed.Year()), nil
+	}
+	return parsed.Format(layout), nil
+}
+
+// layoutSample es una fecha cualquiera con la que se comprueba que un formato contiene elementos de un layout de Go.
+var layoutSample = time.Date(1999, time.December, 31, 23, 59, 58, 0, time.UTC)
+
+// dateLayout devuelve el layout de Go del formato (o formatLongDate). Un formato que no tiene nombre ni contiene
+// ningún elemento de un layout de Go ("corta", "largo.") es un error, en lugar de escribirse como texto literal.
+func dateLayout(format string) (string, error) {
+	if format == formatLongDate {
+		return format, nil
+	}
+	if layout, ok := dateFormats[format]; ok {
+		return layout, nil
+	}
+	if layoutSample.Format(format) == format {
+		return "", fmt.Errorf("formato de fecha %q desconocido: use fecha, fecha_hora, hora, iso, largo o un layout "+
+			"de Go (2006-01-02)", format)
+	}
+	return format, nil
+}
+
+// formatNumber escribe el número con separadores de es-CO (punto para los miles y coma para los decimales):
+// {{.total | number 2}} produce 1.234,50.
+func formatNumber(decimals int, value any) (string, error) {
+	number, ok, err := numberValue(value)
+	if err != nil || !ok {
+		return "", err
+	}
+	if decimals < 0 {
+		return "", fmt.Errorf("number requiere 0 o más decimales: %d", decimals)
+	}
+	return groupNumber(number, decimals), nil
+}
+
+// formatCurrency escribe el valor como pesos colombianos con dos decimales: {{.valor | currency}} produce
+// $ 1.234.567,00.
+func formatCurrency(value any) (string, error) {
+	number, ok, err := numberValue(value)
+	if err != nil || !ok {
+		return "", err
+	}
+	formatted := groupNumber(number, 2)
+	if strings.HasPrefix(formatted, "-") {
+		return "-$ " + formatted[1:], nil
+	}
+	return "$ " + formatted, nil
+}
+
+// numberValue interpreta el valor como número; ok es false si el valor está vacío.
+func numberValue(value any) (float64, bool, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, true, nil
+	case int:
+		return float64(v), true, nil
+	}
+	text := strings.TrimSpace(textValue(value))
+	if text == "" {
+		return 0, false, nil
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%q no es un número válido", text)
+	}
+	return number, true, nil
+}
+
+func groupNumber(number float64, decimals int) string {
+	digits := strconv.FormatFloat(math.Abs(number), 'f', decimals, 64)
+	integer, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+	}
+
+	var out strings.Builder
+	// Un valor que se redondea a cero no lleva signo
+	if number < 0 && strings.Trim(digits, "0.") != "" {
+		out.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			out.WriteByte('.')
+		}
+		out.WriteRune(digit)
+	}
+	if fraction != "" {
+		out.WriteString("," + fraction)
+	}
+	return out.String()
+}
+
+// titleCase escribe en mayúscula la primera letra de cada palabra y el resto en minúscula: "JOSÉ pérez-gómez"
+// produce "José Pérez-Gómez".
+func titleCase(value any) string {
+	runes := []rune(strings.ToLower(textValue(value)))
+	for i, r := range runes {
+		if i == 0 || !unicode.IsLetter(runes[i-1]) {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// truncate recorta el valor a length caracteres como máximo; si lo recorta, el último es "…".
+func truncate(length int, value any) string {
+	runes := []rune(textValue(value))
+	if len(runes) <= length {
+		return string(runes)
+	}
+	if length <= 0 {
+		return ""
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+// defaultValue devuelve fallback si el valor está vacío o el parámetro no llegó: {{.observacion | default "N/A"}}.
+func defaultValue(fallback string, value any) any {
+	if strings.TrimSpace(textValue(value)) == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,145 @@
+package render
+
+import (
+	"gmf_message_processor/internal/models"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormattersInHTMLTemplates(t *testing.T) {
+	tests := []struct {
+		text   string
+		params Params
+		want   string
+	}{
+		{`{{.fecha | date "largo"}}`, Params{"fecha": "07/10/2024"}, "7 de octubre de 2024"},
+		{`{{.fecha | date "2006-01-02"}}`, Params{"fecha": "07/10/2024"}, "2024-10-07"},
+		{`{{.hora | date "15:04"}}`, Params{"hora": "09:19 PM"}, "21:19"},
+		{`{{.envio | date "fecha_hora"}}`, Params{"envio": "2024-10-07T14:30:00Z"}, "07/10/2024 09:30 AM"},
+		{`{{.envio | dateIn "fecha_hora" "Europe/Madrid"}}`, Params{"envio": "07/10/2024 09:30 AM"},
+			"07/10/2024 04:30 PM"},
+		{`{{.total | number 2}}`, Params{"total": "1234567.5"}, "1.234.567,50"},
+		{`{{.total | number 0}}`, Params{"total": "-999.6"}, "-1.000"},
+		{`{{.valor | currency}}`, Params{"valor": "-1500"}, "-$ 1.500,00"},
+		{`{{.valor | currency}}`, Params{"valor": "0.001"}, "$ 0,00"},
+		{`{{.nombre | upper}} {{.nombre | lower}} {{.nombre | title}}`, Params{"nombre": "JOSÉ pérez-gómez"},
+			"JOSÉ PÉREZ-GÓMEZ josé pérez-gómez José Pérez-Gómez"},
+		{`{{.descripcion | truncate 10}}|{{.codigo | truncate 10}}`,
+			Params{"descripcion": "Archivo ya existe con un estado no válido", "codigo": "EPCM002"},
+			"Archivo y…|EPCM002"},
+		{`{{.observacion | default "N/A"}}|{{.codigo | default "N/A"}}`, Params{"codigo": "E01"}, "N/A|E01"},
+		{`{{.fecha | date "fecha"}}{{.total | number 2}}`, Params{}, ""},
+	}
+	for _, tt := range tests {
+		out, err := HTMLEngine{}.Render("PC001", tt.text, tt.params)
+		assert.NoError(t, err, tt.text)
+		assert.Equal(t, tt.want, out, tt.text)
+	}
+
+	subject, err := HTMLEngine{}.RenderText("PC001/asunto",
+		`Rechazo {{.codigo | default "sin código" | upper}}`, Params{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Rechazo SIN CÓDIGO", subject)
+}
+
+func TestFormattersUseTemplateTimezone(t *testing.T) {
+	os.Setenv("TEMPLATE_TIMEZONE", "UTC")
+	defer os.Unsetenv("TEMPLATE_TIMEZONE")
+
+	out, err := HTMLEngine{}.Render("PC001", `{{.envio | dateIn "15:04" "America/Bogota"}}`,
+		Params{"envio": "2024-10-07 14:30:00"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "09:30", out)
+}
+
+func TestFormatterErrorsArePermanent(t *testing.T) {
+	for _, text := range []string{
+		`{{.fecha | date "fecha"}}`,
+		`{{.total | number 2}}`,
+		`{{.fecha | dateIn "fecha" "Marte/Olimpo"}}`,
+		`{{.hoy | date "corta"}}`,
+		`{{.hoy | date "largo."}}`,
+		`{{.vacia | date "corta"}}`,
+	} {
+		_, err := HTMLEngine{}.Render("PC001", text,
+			Params{"fecha": "ayer", "total": "1.234,5", "hoy": "07/10/2024", "vacia": ""})
+		assert.True(t, models.IsPermanentError(err), text)
+	}
+}
+
+func TestDefaultMakesReferenceOptional(t *testing.T) {
+	report, err := Check(HTMLEngine{}, "PC001", Params{}, `{{.observacion | default "N/A"}} {{.codigo | upper}}`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"codigo"}, report.Unresolved)
+}
+
+func TestFormattersInLegacyTemplates(t *testing.T) {
+	tests := []struct {
+		text   string
+		params Params
+		want   string
+	}{
+		{"&fecha_recepcion|date:largo", Params{"fecha_recepcion": "07/10/2024"}, "7 de octubre de 2024"},
+		{"&envio|dateIn:fecha_hora:Europe/Madrid", Params{"envio": "07/10/2024 09:30 AM"}, "07/10/2024 04:30 PM"},
+		{"&total|number:2 / &valor|currency", Params{"total": "1234567.5", "valor": "-1500"},
+			"1.234.567,50 / -$ 1.500,00"},
+		{"&nombre|title &nombre|upper", Params{"nombre": "josé pérez"}, "José Pérez JOSÉ PÉREZ"},
+		{"&descripcion|truncate:10", Params{"descripcion": "Archivo ya existe"}, "Archivo y…"},
+		{"&observacion|default:N/A|upper, &codigo|default:N/A", Params{"codigo": "E01"}, "N/A, E01"},
+		{"&valor|default:0|currency", Params{"valor": ""}, "$ 0,00"},
+		// Un nombre que no es un formateador se conserva como texto
+		{"&codigo|&descripcion|otro", Params{"codigo": "E01", "descripcion": "duplicado"}, "E01|duplicado|otro"},
+		{"&observacion|upper", Params{}, "&observacion|upper"},
+	}
+	for _, tt := range tests {
+		out, err := LegacyEngine{}.Render("PC001", tt.text, tt.params)
+		assert.NoError(t, err, tt.text)
+		assert.Equal(t, tt.want, out, tt.text)
+	}
+}
+
+func TestLegacyFormattersFollowedByPunctuation(t *testing.T) {
+	params := Params{"fecha": "07/10/2024", "total": "1234.5", "nombre": "José", "valor": "2.5"}
+	tests := map[string]string{
+		"Recibido el &fecha|date:largo.":          "Recibido el 7 de octubre de 2024.",
+		"<p>Recibido el &fecha|date:fecha.</p>":   "<p>Recibido el 07/10/2024.</p>",
+		"Total &total|number:2, gracias":          "Total 1.234,50, gracias",
+		"Hola &nombre|truncate:2.":                "Hola J….",
+		"&valor|default:0.5, &total|currency.":    "2.5, $ 1.234,50.",
+		"Total &total|number:2,&nombre|upper...":  "Total 1.234,50,JOSÉ...",
+		"Fecha: &fecha|dateIn:fecha:UTC, &nombre": "Fecha: 07/10/2024, José",
+	}
+	for text, want := range tests {
+		out, err := LegacyEngine{}.Render("PC001", text, params)
+		assert.NoError(t, err, text)
+		assert.Equal(t, want, out, text)
+	}
+}
+
+func TestLegacyFormatterErrorsArePermanent(t *testing.T) {
+	for _, text := range []string{
+		"&fecha|date:fecha",
+		"&total|number:2",
+		"&total|number",
+		"&fecha|dateIn:fecha:Marte/Olimpo",
+		"&hoy|date:corta",
+		"&hoy|date:largo.x",
+	} {
+		_, err := LegacyEngine{}.Render("PC001", text, Params{"fecha": "ayer", "total": "1.234,5", "hoy": "07/10/2024"})
+		assert.True(t, models.IsPermanentError(err), text)
+	}
+
+	_, err := LegacyEngine{}.References("PC001", "&total|truncate")
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestLegacyDefaultMakesReferenceOptional(t *testing.T) {
+	report, err := Check(LegacyEngine{}, "PC001", Params{}, "&observacion|default:N/A &codigo|upper")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"codigo"}, report.Unresolved)
+}
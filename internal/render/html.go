@@ -0,0 +1,124 @@
+package render
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// HTMLEngine renderiza la plantilla con html/template: los valores se escapan según el contexto en que aparecen
+// (texto, atributos, URLs), y se admiten {{if}}/{{else}}, {{range}} y las funciones de helperFuncs. Los campos de
+// texto plano usan text/template con la misma sintaxis y funciones.
+type HTMLEngine struct{}
+
+// helperFuncs son las funciones disponibles en las plantillas además de las predefinidas de html/template. Ninguna
+// produce HTML sin escapar.
+var helperFuncs = template.FuncMap{
+	// list convierte un parámetro en lista, de modo que {{range list .archivos}} funcione tanto si el productor
+	// envió un solo valor como si envió varios o una lista JSON.
+	"list": func(value any) any {
+		switch v := value.(type) {
+		case nil:
+			return nil
+		case []string, []any:
+			return v
+		case map[string]any:
+			return []any{v}
+		default:
+			return []string{fmt.Sprint(v)}
+		}
+	},
+	// join une los valores de una lista: {{join ", " .archivos}}.
+	"join": func(sep string, value any) string {
+		switch v := value.(type) {
+		case nil:
+			return ""
+		case []string:
+			return strings.Join(v, sep)
+		case []any:
+			values := make([]string, len(v))
+			for i, item := range v {
+				values[i] = textValue(item)
+			}
+			return strings.Join(values, sep)
+		default:
+			return textValue(v)
+		}
+	},
+	// split separa un valor en una lista: {{range split ";" .correos}}.
+	"split": func(sep string, value any) []string {
+		if value == nil || value == "" {
+			return nil
+		}
+		return strings.Split(fmt.Sprint(value), sep)
+	},
+	"trim": strings.TrimSpace,
+
+	// Formateadores de valores, pensados para usarse como pipes: {{.fecha_recepcion | date "largo"}},
+	// {{.total | currency}}, {{.observacion | default "N/A"}}. Un valor vacío produce un texto vacío; uno que no
+	// puede interpretarse como fecha o número es un error de la plantilla.
+	"date":     formatDate,
+	"dateIn":   formatDateIn,
+	"number":   formatNumber,
+	"currency": formatCurrency,
+	"upper":    func(value any) string { return strings.ToUpper(textValue(value)) },
+	"lower":    func(value any) string { return strings.ToLower(textValue(value)) },
+	"title":    titleCase,
+	"truncate": truncate,
+	"default":  defaultValue,
+}
+
+func (HTMLEngine) Render(name, text string, params Params) (string, error) {
+	tmpl := cachedHTML(name, text)
+	if tmpl == nil {
+		var err error
+		if tmpl, err = template.New(name).Funcs(helperFuncs).Parse(text); err != nil {
+			return "", models.NewPermanentError(fmt.Errorf("error: la plantilla %s no es válida: %w", name, err))
+		}
+		storeHTML(name, text, tmpl)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, map[string]any(params)); err != nil {
+		return "", models.NewPermanentError(fmt.Errorf("error al renderizar la plantilla %s: %w", name, err))
+	}
+	return out.String(), nil
+}
+
+// RenderText renderiza con text/template. A diferencia de html/template, text/template imprime "<no value>" para
+// los parámetros ausentes, por lo que éstos se completan con un texto vacío.
+func (HTMLEngine) RenderText(name, text string, params Params) (string, error) {
+	tmpl, err := parseText(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	data := make(map[string]any, len(params))
+	for _, ref := range treeReferences(tmpl) {
+		data[ref.Name] = ""
+	}
+	for key, value := range params {
+		data[key] = value
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", models.NewPermanentError(fmt.Errorf("error al renderizar la plantilla %s: %w", name, err))
+	}
+	return out.String(), nil
+}
+
+// parseText analiza el texto con text/template, o devuelve el análisis guardado en la caché.
+func parseText(name, text string) (*texttemplate.Template, error) {
+	if tmpl := cachedText(name, text); tmpl != nil {
+		return tmpl, nil
+	}
+	tmpl, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(helperFuncs)).Parse(text)
+	if err != nil {
+		return nil, models.NewPermanentError(fmt.Errorf("error: la plantilla %s no es válida: %w", name, err))
+	}
+	storeText(name, text, tmpl)
+	return tmpl, nil
+}
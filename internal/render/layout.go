@@ -0,0 +1,123 @@
+package render
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"regexp"
+	"strings"
+)
+
+// BodySlot es el nombre con el que un layout incluye el cuerpo de la plantilla: {{> cuerpo}}.
+const BodySlot = "cuerpo"
+
+// maxIncludeDepth limita el anidamiento de parciales para detectar inclusiones circulares.
+const maxIncludeDepth = 10
+
+// includePattern reconoce las inclusiones {{> nombre}}. Se resuelven antes de renderizar, por lo que funcionan igual
+// con ambos motores y los parciales pueden usar los parámetros del mensaje.
+var includePattern = regexp.MustCompile(`\{\{>\s*([\p{L}\p{N}_.-]+)\s*\}\}`)
+
+// FragmentLookup devuelve el fragmento con el nombre indicado, o nil si no existe.
+type FragmentLookup func(nombre string) (*models.Fragmento, error)
+
+// Includes devuelve los nombres de los parciales que el texto incluye, en orden de aparición.
+func Includes(text string) []string {
+	var names []string
+	for _, match := range includePattern.FindAllStringSubmatch(text, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// Assemble arma el cuerpo de la plantilla: si tiene layout, el cuerpo se coloca en su {{> cuerpo}}, y luego se
+// reemplaza cada {{> nombre}} por el contenido del parcial, incluidos los parciales anidados. Un fragmento
+// inexistente, de otro tipo o incluido de forma circular es un error permanente; los errores de lookup se devuelven
+// sin cambios.
+func Assemble(name, layout, body string, lookup FragmentLookup) (string, error) {
+	text := body
+	if layout != "" {
+		fragmento, err := findFragment(lookup, layout, models.TipoFragmentoLayout)
+		if err != nil {
+			return "", err
+		}
+		if !includes(fragmento.Contenido, BodySlot) {
+			return "", models.NewPermanentError(
+				fmt.Errorf("error: el layout %s no incluye el cuerpo con {{> %s}}", layout, BodySlot))
+		}
+		text = includePattern.ReplaceAllStringFunc(fragmento.Contenido, func(include string) string {
+			if includePattern.FindStringSubmatch(include)[1] == BodySlot {
+				return body
+			}
+			return include
+		})
+	}
+
+	return expandIncludes(name, text, lookup, nil)
+}
+
+// expandIncludes reemplaza las inclusiones del texto; stack son los parciales que se están expandiendo.
+func expandIncludes(name, text string, lookup FragmentLookup, stack []string) (string, error) {
+	var expandErr error
+	expanded := includePattern.ReplaceAllStringFunc(text, func(include string) string {
+		if expandErr != nil {
+			return include
+		}
+		nombre := includePattern.FindStringSubmatch(include)[1]
+		switch {
+		case nombre == BodySlot:
+			expandErr = fmt.Errorf("{{> %s}} solo puede usarse en un layout", BodySlot)
+		case inStack(stack, nombre):
+			expandErr = fmt.Errorf("inclusión circular %s → %s", strings.Join(stack, " → "), nombre)
+		case len(stack) >= maxIncludeDepth:
+			expandErr = fmt.Errorf("se superó el máximo de %d parciales anidados", maxIncludeDepth)
+		}
+		if expandErr != nil {
+			expandErr = models.NewPermanentError(fmt.Errorf("error al armar la plantilla %s: %w", name, expandErr))
+			return include
+		}
+
+		fragmento, err := findFragment(lookup, nombre, models.TipoFragmentoParcial)
+		if err == nil {
+			var content string
+			if content, err = expandIncludes(name, fragmento.Contenido, lookup, append(stack, nombre)); err == nil {
+				return content
+			}
+		}
+		expandErr = err
+		return include
+	})
+	return expanded, expandErr
+}
+func findFragment(lookup FragmentLookup, nombre, tipo string) (*models.Fragmento, error) {
+	fragmento, err := lookup(nombre)
+	if err != nil {
+		return nil, err
+	}
+	if fragmento == nil {
+		return nil, models.NewPermanentError(fmt.Errorf("error: el fragmento %s no existe", nombre))
+	}
+	if fragmento.Tipo != tipo {
+		return nil, models.NewPermanentError(
+			fmt.Errorf("error: el fragmento %s es de tipo %s y se esperaba %s", nombre, fragmento.Tipo, tipo))
+	}
+	return fragmento, nil
+}
+
+// includes indica si el texto incluye el fragmento indicado.
+func includes(text, nombre string) bool {
+	for _, included := range Includes(text) {
+		if included == nombre {
+			return true
+		}
+	}
+	return false
+}
+
+func inStack(stack []string, nombre string) bool {
+	for _, current := range stack {
+		if current == nombre {
+			return true
+		}
+	}
+	return false
+}
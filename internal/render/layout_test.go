@@ -0,0 +1,85 @@
+package render
+
+import (
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fragmentos devuelve un FragmentLookup sobre los fragmentos indicados.
+func fragmentos(list ...models.Fragmento) FragmentLookup {
+	return func(nombre string) (*models.Fragmento, error) {
+		for i := range list {
+			if list[i].Nombre == nombre {
+				return &list[i], nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+func TestAssembleLayoutAndPartials(t *testing.T) {
+	lookup := fragmentos(
+		models.Fragmento{Nombre: "base", Tipo: models.TipoFragmentoLayout,
+			Contenido: "<html>{{> encabezado}}{{> cuerpo}}{{>pie}}</html>"},
+		models.Fragmento{Nombre: "encabezado", Tipo: models.TipoFragmentoParcial, Contenido: "<h1>Banco</h1>"},
+		models.Fragmento{Nombre: "pie", Tipo: models.TipoFragmentoParcial, Contenido: "<footer>{{> aviso_legal}}</footer>"},
+		models.Fragmento{Nombre: "aviso_legal", Tipo: models.TipoFragmentoParcial, Contenido: "Confidencial &$1"},
+	)
+
+	cuerpo, err := Assemble("PC001", "base", "<p>Hola &nombre</p>", lookup)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "<html><h1>Banco</h1><p>Hola &nombre</p><footer>Confidencial &$1</footer></html>", cuerpo)
+	assert.Equal(t, []string{"encabezado", "cuerpo", "pie"}, Includes("{{> encabezado}}{{> cuerpo}}{{>pie}}"))
+}
+
+func TestAssembleWithoutLayout(t *testing.T) {
+	lookup := fragmentos(models.Fragmento{Nombre: "pie", Tipo: models.TipoFragmentoParcial, Contenido: "Pie"})
+
+	cuerpo, err := Assemble("PC001", "", "Cuerpo {{> pie }}", lookup)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Cuerpo Pie", cuerpo)
+}
+
+func TestAssembleErrorsArePermanent(t *testing.T) {
+	lookup := fragmentos(
+		models.Fragmento{Nombre: "sin_cuerpo", Tipo: models.TipoFragmentoLayout, Contenido: "<html></html>"},
+		models.Fragmento{Nombre: "pie", Tipo: models.TipoFragmentoParcial, Contenido: "{{> aviso}}"},
+		models.Fragmento{Nombre: "aviso", Tipo: models.TipoFragmentoParcial, Contenido: "{{> pie}}"},
+	)
+
+	tests := []struct {
+		name    string
+		layout  string
+		body    string
+		message string
+	}{
+		{name: "layout inexistente", layout: "base", body: "x", message: "el fragmento base no existe"},
+		{name: "layout sin cuerpo", layout: "sin_cuerpo", body: "x", message: "no incluye el cuerpo"},
+		{name: "parcial como layout", layout: "pie", body: "x", message: "es de tipo partial y se esperaba layout"},
+		{name: "parcial inexistente", body: "{{> firma}}", message: "el fragmento firma no existe"},
+		{name: "circular", body: "{{> pie}}", message: "inclusión circular pie → aviso → pie"},
+		{name: "cuerpo fuera del layout", body: "{{> cuerpo}}", message: "solo puede usarse en un layout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble("PC001", tt.layout, tt.body, lookup)
+
+			assert.ErrorContains(t, err, tt.message)
+			assert.True(t, models.IsPermanentError(err))
+		})
+	}
+}
+
+func TestAssembleLookupErrorIsNotPermanent(t *testing.T) {
+	lookup := func(string) (*models.Fragmento, error) { return nil, errors.New("db caída") }
+
+	_, err := Assemble("PC001", "", "{{> pie}}", lookup)
+
+	assert.EqualError(t, err, "db caída")
+	assert.False(t, models.IsPermanentError(err))
+}
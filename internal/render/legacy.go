@@ -0,0 +1,151 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"sort"
+	"strings"
+)
+
+// LegacyEngine reemplaza los marcadores &nombre por el valor del parámetro, tal como lo hacía el servicio antes de
+// que existiera el motor html. Los valores no se escapan y, si un parámetro se repite, se usa su último valor. Las
+// listas y objetos JSON se reemplazan por su JSON. Los parámetros de la consulta de una URL (?a=1&b=2) se conservan.
+// Los formateadores se encadenan tras el marcador con "|" (&fecha_recepcion|date:largo, &valor|default:0|currency).
+type LegacyEngine struct{}
+
+func (LegacyEngine) Render(name, text string, params Params) (string, error) {
+	out, err := replaceLegacy(text, params)
+	if err != nil {
+		return "", models.NewPermanentError(fmt.Errorf("error al renderizar la plantilla %s: %w", name, err))
+	}
+	return out, nil
+}
+
+// RenderText es igual a Render: el motor legacy nunca escapa los valores.
+func (LegacyEngine) RenderText(name, text string, params Params) (string, error) {
+	return LegacyEngine{}.Render(name, text, params)
+}
+
+func replaceLegacy(text string, params Params) (string, error) {
+	values := make(map[string]string, len(params))
+	for name, value := range params {
+		switch v := value.(type) {
+		case string:
+			values[name] = v
+		case []string:
+			values[name] = v[len(v)-1]
+		default:
+			values[name] = jsonText(v)
+		}
+	}
+
+	placeholders, err := scanLegacy(text, legacyNames(params))
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, placeholder := range placeholders {
+		var value any
+		if placeholder.param {
+			value = values[placeholder.name]
+		} else if !hasLegacyDefault(placeholder.formatters) {
+			// Un marcador sin parámetro solo se reemplaza si tiene un valor por defecto
+			continue
+		}
+		formatted, err := applyLegacyFormatters(placeholder.formatters, value)
+		if err != nil {
+			return "", fmt.Errorf("&%s: %w", placeholder.name, err)
+		}
+		out.WriteString(text[last:placeholder.start])
+		out.WriteString(formatted)
+		last = placeholder.end
+	}
+	out.WriteString(text[last:])
+	return out.String(), nil
+}
+
+// legacyPlaceholder es un marcador &nombre del texto, entre start y end, con sus formateadores. param indica que el
+// nombre es el de un parámetro recibido.
+type legacyPlaceholder struct {
+	start, end int
+	name       string
+	param      bool
+	formatters []legacyFormatterCall
+}
+
+// scanLegacy devuelve los marcadores del texto; Render y References lo comparten para que reconozcan los mismos. Un
+// marcador que empieza con el nombre de un parámetro corresponde al más largo de ellos: con archivo y
+// archivo_rechazado, &archivo_rechazado es archivo_rechazado, y solo con archivo es archivo seguido de "_rechazado".
+// Si ningún parámetro coincide el marcador es la palabra completa. Las entidades HTML (&nbsp;) y los & que separan
+// los parámetros de la consulta de una URL no son marcadores.
+func scanLegacy(text string, names []string) ([]legacyPlaceholder, error) {
+	var placeholders []legacyPlaceholder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '&' || isQuerySeparator(text, i) {
+			continue
+		}
+
+		name := legacyParam(text[i+1:], names)
+		param := name != ""
+		if !param {
+			if name = legacyToken(text[i:]); name == "" {
+				continue
+			}
+		}
+		formatters, length, err := parseLegacyFormatters(text[i+1+len(name):])
+		if err != nil {
+			return nil, fmt.Errorf("&%s: %w", name, err)
+		}
+
+		end := i + 1 + len(name) + length
+		placeholders = append(placeholders, legacyPlaceholder{
+			start: i, end: end, name: name, param: param, formatters: formatters,
+		})
+		i = end - 1
+	}
+	return placeholders, nil
+}
+
+// legacyNames devuelve los nombres de los parámetros del más largo al más corto, el orden en que scanLegacy los
+// compara.
+func legacyNames(params Params) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+	return names
+}
+
+// legacyToken devuelve el nombre del marcador &nombre con el que empieza el texto, o "" si no es un marcador.
+func legacyToken(text string) string {
+	match := legacyPlaceholderPattern.FindStringSubmatchIndex(text)
+	if match == nil || match[0] != 0 || match[5] > match[4] {
+		return ""
+	}
+	return text[match[2]:match[3]]
+}
+
+// legacyParam devuelve el nombre de parámetro con el que empieza el texto, o "" si ninguno coincide.
+func legacyParam(text string, names []string) string {
+	for _, name := range names {
+		if strings.HasPrefix(text, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// jsonText escribe un valor estructurado como JSON.
+func jsonText(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
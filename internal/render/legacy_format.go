@@ -0,0 +1,120 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// legacyFormatterPattern reconoce un formateador a continuación de un marcador legacy: |nombre o |nombre:arg:arg.
+// Los argumentos no pueden contener espacios ni ":", por lo que date y dateIn se usan con los formatos con nombre, ni
+// terminar en "." o ",", que se consideran la puntuación del texto.
+var legacyFormatterPattern = regexp.MustCompile(`^\|([a-zA-Z]+)((?::[\p{L}\p{N}_./+,-]+)*)`)
+
+// legacyFormatter es un formateador del motor legacy con el número de argumentos que requiere.
+type legacyFormatter struct {
+	args  int
+	apply func(args []string, value any) (any, error)
+}
+
+// legacyFormatters son los formateadores de helperFuncs disponibles con la sintaxis &nombre|formateador:arg.
+var legacyFormatters = map[string]legacyFormatter{
+	"date": {1, func(args []string, value any) (any, error) {
+		return formatDate(args[0], value)
+	}},
+	"dateIn": {2, func(args []string, value any) (any, error) {
+		return formatDateIn(args[0], args[1], value)
+	}},
+	"number": {1, func(args []string, value any) (any, error) {
+		decimals, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("number requiere un número de decimales: %q", args[0])
+		}
+		return formatNumber(decimals, value)
+	}},
+	"currency": {0, func(_ []string, value any) (any, error) {
+		return formatCurrency(value)
+	}},
+	"upper": {0, func(_ []string, value any) (any, error) {
+		return strings.ToUpper(textValue(value)), nil
+	}},
+	"lower": {0, func(_ []string, value any) (any, error) {
+		return strings.ToLower(textValue(value)), nil
+	}},
+	"title": {0, func(_ []string, value any) (any, error) {
+		return titleCase(value), nil
+	}},
+	"truncate": {1, func(args []string, value any) (any, error) {
+		length, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("truncate requiere una longitud: %q", args[0])
+		}
+		return truncate(length, value), nil
+	}},
+	"default": {1, func(args []string, value any) (any, error) {
+		return defaultValue(args[0], value), nil
+	}},
+}
+
+// legacyFormatterCall es un formateador del marcador con sus argumentos.
+type legacyFormatterCall struct {
+	name string
+	args []string
+}
+
+// parseLegacyFormatters lee los formateadores con los que empieza el texto y devuelve cuántos bytes ocupan. Un
+// nombre que no es un formateador termina la cadena y se conserva como texto (&codigo|&descripcion); un formateador
+// conocido con un número de argumentos incorrecto es un error.
+func parseLegacyFormatters(text string) ([]legacyFormatterCall, int, error) {
+	var calls []legacyFormatterCall
+	length := 0
+	for {
+		match := legacyFormatterPattern.FindStringSubmatch(text[length:])
+		if match == nil {
+			return calls, length, nil
+		}
+		formatter, ok := legacyFormatters[match[1]]
+		if !ok {
+			return calls, length, nil
+		}
+
+		// Los "." y "," finales son la puntuación del texto, no del argumento ("Recibido el &fecha|date:largo.")
+		matched, argText := match[0], match[2]
+		if trimmed := strings.TrimRight(argText, ".,"); trimmed != argText && !strings.HasSuffix(trimmed, ":") {
+			matched = matched[:len(matched)-(len(argText)-len(trimmed))]
+			argText = trimmed
+		}
+
+		var args []string
+		if argText != "" {
+			args = strings.Split(argText[1:], ":")
+		}
+		if len(args) != formatter.args {
+			return nil, 0, fmt.Errorf("%s requiere %d argumentos y recibió %d", match[1], formatter.args, len(args))
+		}
+		calls = append(calls, legacyFormatterCall{name: match[1], args: args})
+		length += len(matched)
+	}
+}
+
+// applyLegacyFormatters aplica los formateadores en orden y devuelve el valor como texto.
+func applyLegacyFormatters(calls []legacyFormatterCall, value any) (string, error) {
+	for _, call := range calls {
+		var err error
+		if value, err = legacyFormatters[call.name].apply(call.args, value); err != nil {
+			return "", err
+		}
+	}
+	return textValue(value), nil
+}
+
+// hasLegacyDefault indica si alguno de los formateadores da un valor por defecto al marcador.
+func hasLegacyDefault(calls []legacyFormatterCall) bool {
+	for _, call := range calls {
+		if call.name == "default" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package render
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"regexp"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// Modos de la plantilla ante marcadores sin resolver y parámetros no utilizados (columna modo_parametros).
+const (
+	// ModeFail rechaza el mensaje de forma permanente.
+	ModeFail = "fail"
+	// ModeWarn registra una advertencia y envía el correo tal como se renderiza.
+	ModeWarn = "warn"
+	// ModeDefault completa los marcadores sin resolver con el valor por defecto de la plantilla y registra una
+	// advertencia.
+	ModeDefault = "default"
+)
+
+// Reference es un parámetro referenciado por la plantilla. Optional indica que la plantilla funciona sin él (por
+// ejemplo, {{if .codigo}} o {{range .registros}}).
+type Reference struct {
+	Name     string
+	Optional bool
+}
+
+// Report resume los marcadores sin resolver y los parámetros que ninguna parte de la plantilla utiliza.
+type Report struct {
+	Unresolved []string
+	Unused     []string
+}
+
+// Empty indica que no hay nada que reportar.
+func (r Report) Empty() bool {
+	return len(r.Unresolved) == 0 && len(r.Unused) == 0
+}
+
+func (r Report) String() string {
+	var parts []string
+	if len(r.Unresolved) > 0 {
+		parts = append(parts, "marcadores sin resolver: "+strings.Join(r.Unresolved, ", "))
+	}
+	if len(r.Unused) > 0 {
+		parts = append(parts, "parámetros no utilizados: "+strings.Join(r.Unused, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// paramReferencer lo implementan los motores cuyos marcadores dependen de los parámetros recibidos (legacy).
+type paramReferencer interface {
+	ReferencesFor(name, text string, params Params) ([]Reference, error)
+}
+
+// Check analiza los textos de la plantilla con el motor indicado y compara sus referencias con los parámetros.
+func Check(engine Engine, name string, params Params, texts ...string) (Report, error) {
+	required := map[string]bool{}
+	referenced := map[string]bool{}
+	for _, text := range texts {
+		var references []Reference
+		var err error
+		if referencer, ok := engine.(paramReferencer); ok {
+			references, err = referencer.ReferencesFor(name, text, params)
+		} else {
+			references, err = engine.References(name, text)
+		}
+		if err != nil {
+			return Report{}, err
+		}
+		for _, ref := range references {
+			referenced[ref.Name] = true
+			if !ref.Optional {
+				required[ref.Name] = true
+			}
+		}
+	}
+
+	var report Report
+	for ref := range required {
+		if _, ok := params[ref]; !ok {
+			report.Unresolved = append(report.Unresolved, ref)
+		}
+	}
+	for param := range params {
+		if !referenced[param] {
+			report.Unused = append(report.Unused, param)
+		}
+	}
+	sort.Strings(report.Unresolved)
+	sort.Strings(report.Unused)
+	return report, nil
+}
+
+// ParseMode valida el modo configurado en la plantilla. Un modo vacío equivale a ModeWarn.
+func ParseMode(mode string) (string, error) {
+	switch normalized := strings.ToLower(strings.TrimSpace(mode)); normalized {
+	case "":
+		return ModeWarn, nil
+	case ModeFail, ModeWarn, ModeDefault:
+		return normalized, nil
+	default:
+		return "", models.NewPermanentError(fmt.Errorf("error: modo de parámetros %q no soportado (%s, %s o %s)",
+			mode, ModeFail, ModeWarn, ModeDefault))
+	}
+}
+
+// legacyPlaceholderPattern reconoce el marcador &nombre al inicio del texto. Un ";" a continuación indica una
+// entidad HTML (&nbsp;, &amp;), que no es un marcador.
+var legacyPlaceholderPattern = regexp.MustCompile(`^&([\p{L}\p{N}_]+)(;?)`)
+
+// References devuelve los marcadores &nombre del texto; son obligatorios salvo los que tienen un valor por defecto
+// (&observacion|default:N/A). Sin parámetros cada marcador es la palabra completa; Check usa ReferencesFor para
+// reconocerlos igual que Render.
+func (LegacyEngine) References(name, text string) ([]Reference, error) {
+	return LegacyEngine{}.ReferencesFor(name, text, nil)
+}
+
+// ReferencesFor devuelve los marcadores del texto reconociendo los nombres de los parámetros como prefijo, igual que
+// Render: con el parámetro archivo, &archivo_rechazado referencia archivo.
+func (LegacyEngine) ReferencesFor(name, text string, params Params) ([]Reference, error) {
+	placeholders, err := scanLegacy(text, legacyNames(params))
+	if err != nil {
+		return nil, models.NewPermanentError(fmt.Errorf("error: la plantilla %s no es válida: %w", name, err))
+	}
+
+	references := make([]Reference, 0, len(placeholders))
+	for _, placeholder := range placeholders {
+		references = append(references, Reference{
+			Name:     placeholder.name,
+			Optional: hasLegacyDefault(placeholder.formatters),
+		})
+	}
+	return references, nil
+}
+
+// urlDelimiters son los caracteres que delimitan una URL dentro del texto o de un atributo HTML.
+const urlDelimiters = " \t\r\n\"'<>()"
+
+// isQuerySeparator indica si el & de la posición i separa parámetros de la consulta de una URL
+// (https://x.co/?a=1&b=2). Un & a continuación de "=" es el valor del parámetro y sí es un marcador
+// (https://x.co/?ref=&referencia).
+func isQuerySeparator(text string, i int) bool {
+	if i > 0 && text[i-1] == '=' {
+		return false
+	}
+	start := strings.LastIndexAny(text[:i], urlDelimiters) + 1
+	return strings.Contains(text[start:i], "?")
+}
+
+// References recorre el árbol de la plantilla y devuelve los campos del mensaje que utiliza ({{.nombre}} y
+// {{$.nombre}}). Son opcionales los que solo se usan como condición de {{if}}/{{with}}, los recorridos con
+// {{range}}, los que tienen un valor por defecto ({{.x | default "N/A"}}) y los que aparecen dentro de un {{if}} que
+// comprueba ese mismo campo.
+func (HTMLEngine) References(name, text string) ([]Reference, error) {
+	tmpl, err := parseText(name, text)
+	if err != nil {
+		return nil, err
+	}
+	return treeReferences(tmpl), nil
+}
+
+func treeReferences(tmpl *texttemplate.Template) []Reference {
+	if tmpl.Tree == nil {
+		return nil
+	}
+	walker := &referenceWalker{}
+	walker.walk(tmpl.Tree.Root, true, nil)
+	return walker.references
+}
+
+type referenceWalker struct {
+	references []Reference
+}
+
+// walk recorre los nodos. atRoot indica si "." es el mapa de parámetros (fuera de {{range}} y {{with}}) y guarded
+// los campos comprobados por un {{if}} que encierra al nodo.
+func (w *referenceWalker) walk(node parse.Node, atRoot bool, guarded map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, atRoot, guarded)
+		}
+	case *parse.ActionNode:
+		if hasDefault(n.Pipe) {
+			w.pipe(n.Pipe, atRoot, always)
+		} else {
+			w.pipe(n.Pipe, atRoot, func(field string) bool { return guarded[field] })
+		}
+	case *parse.IfNode:
+		w.pipe(n.Pipe, atRoot, always)
+		inner := guarded
+		if field := conditionField(n.Pipe, atRoot); field != "" {
+			inner = map[string]bool{field: true}
+			for name := range guarded {
+				inner[name] = true
+			}
+		}
+		w.walk(n.List, atRoot, inner)
+		w.walk(n.ElseList, atRoot, guarded)
+	case *parse.RangeNode:
+		w.pipe(n.Pipe, atRoot, always)
+		w.walk(n.List, false, guarded)
+		w.walk(n.ElseList, atRoot, guarded)
+	case *parse.WithNode:
+		w.pipe(n.Pipe, atRoot, always)
+		w.walk(n.List, false, guarded)
+		w.walk(n.ElseList, atRoot, guarded)
+	case *parse.TemplateNode:
+		w.pipe(n.Pipe, atRoot, always)
+	}
+}
+
+func always(string) bool { return true }
+
+// pipe registra los campos del pipeline; optional decide si cada uno es opcional.
+func (w *referenceWalker) pipe(pipe *parse.PipeNode, atRoot bool, optional func(string) bool) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				if atRoot {
+					w.references = append(w.references, Reference{Name: a.Ident[0], Optional: optional(a.Ident[0])})
+				}
+			case *parse.VariableNode:
+				if a.Ident[0] == "$" && len(a.Ident) > 1 {
+					w.references = append(w.references, Reference{Name: a.Ident[1], Optional: optional(a.Ident[1])})
+				}
+			case *parse.PipeNode:
+				w.pipe(a, atRoot, optional)
+			}
+		}
+	}
+}
+
+// hasDefault indica si el pipeline aplica la función default.
+func hasDefault(pipe *parse.PipeNode) bool {
+	if pipe == nil {
+		return false
+	}
+	for _, cmd := range pipe.Cmds {
+		if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "default" {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionField devuelve el campo de un {{if .campo}} simple, o "" si la condición es otra expresión.
+func conditionField(pipe *parse.PipeNode, atRoot bool) string {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return ""
+	}
+	switch a := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		if atRoot {
+			return a.Ident[0]
+		}
+	case *parse.VariableNode:
+		if a.Ident[0] == "$" && len(a.Ident) > 1 {
+			return a.Ident[1]
+		}
+	}
+	return ""
+}
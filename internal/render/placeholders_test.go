@@ -0,0 +1,98 @@
+package render
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyReferencesIgnoreHTMLEntities(t *testing.T) {
+	references, err := LegacyEngine{}.References("PC001", "Hola&nbsp;&nombre, código &código_rechazo &amp; más")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Reference{{Name: "nombre"}, {Name: "código_rechazo"}}, references)
+}
+
+func TestLegacyReferencesIgnoreQueryStrings(t *testing.T) {
+	text := `<a href="https://x.co/?a=1&b=2">enlace</a> <img src='/logo.png?v=2&tema=oscuro'> ` +
+		`https://x.co/pago?id=&referencia&canal=web &nombre`
+
+	references, err := LegacyEngine{}.References("PC001", text)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Reference{{Name: "referencia"}, {Name: "nombre"}}, references)
+}
+
+func TestLegacyCheckMatchesParameterPrefixLikeRender(t *testing.T) {
+	params := Params{"archivo": "A"}
+
+	report, err := Check(LegacyEngine{}, "PC001", params, "&archivo_rechazado")
+	assert.NoError(t, err)
+	assert.True(t, report.Empty(), report.String())
+
+	out, err := LegacyEngine{}.Render("PC001", "&archivo_rechazado", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "A_rechazado", out)
+
+	// Con ambos parámetros se prefiere el más largo
+	params["archivo_rechazado"] = "x.csv"
+	report, err = Check(LegacyEngine{}, "PC001", params, "&archivo_rechazado")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"archivo"}, report.Unused)
+}
+
+func TestHTMLReferencesOptionalFields(t *testing.T) {
+	text := `{{.archivo}}{{if .codigo}}({{.codigo}}){{end}}{{if .x}}{{.detalle}}{{else}}{{.motivo}}{{end}}` +
+		`{{range .registros}}{{.codigo_registro}}{{$.lote}}{{end}}{{with .responsable}}{{.}}{{end}}`
+
+	references, err := HTMLEngine{}.References("PC001", text)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Reference{
+		{Name: "archivo"},
+		{Name: "codigo", Optional: true},
+		{Name: "codigo", Optional: true},
+		{Name: "x", Optional: true},
+		{Name: "detalle"},
+		{Name: "motivo"},
+		{Name: "registros", Optional: true},
+		{Name: "lote"},
+		{Name: "responsable", Optional: true},
+	}, references)
+}
+
+func TestCheckReportsUnresolvedAndUnused(t *testing.T) {
+	params := Params{"archivo": "pagos.csv", "sistema": "X", "usuario": "ana"}
+
+	report, err := Check(HTMLEngine{}, "PC001", params,
+		"{{.archivo}} {{.codigo_rechazo}}{{if .observacion}}{{.observacion}}{{end}}", "Rechazo {{.fecha}}")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"codigo_rechazo", "fecha"}, report.Unresolved)
+	assert.Equal(t, []string{"sistema", "usuario"}, report.Unused)
+	assert.Equal(t,
+		"marcadores sin resolver: codigo_rechazo, fecha; parámetros no utilizados: sistema, usuario", report.String())
+
+	report, err = Check(LegacyEngine{}, "PC001", Params{"archivo": "pagos.csv"}, "&archivo")
+	assert.NoError(t, err)
+	assert.True(t, report.Empty())
+}
+
+func TestParseMode(t *testing.T) {
+	for raw, want := range map[string]string{"": ModeWarn, "FAIL": ModeFail, " default ": ModeDefault} {
+		mode, err := ParseMode(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, want, mode)
+	}
+
+	_, err := ParseMode("ignore")
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestHTMLRenderTextMissingParameterIsEmpty(t *testing.T) {
+	out, err := HTMLEngine{}.RenderText("PC001/asunto", "Rechazo {{.archivo}}", Params{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Rechazo ", out)
+}
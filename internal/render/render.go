@@ -0,0 +1,91 @@
+package render
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"strings"
+)
+
+// Motores de plantillas admitidos en la columna motor de cgd_correos_plantillas.
+const (
+	// EngineLegacy reemplaza literalmente los marcadores &nombre, sin escapar los valores.
+	EngineLegacy = "legacy"
+	// EngineHTML usa la sintaxis de html/template ({{.nombre}}) y escapa los valores según su contexto.
+	EngineHTML = "html"
+)
+
+// Engine renderiza el texto de una plantilla con los parámetros del mensaje. Render produce el cuerpo HTML y
+// RenderText los campos de texto plano (asunto y direcciones), en los que los valores no se escapan como HTML.
+// References devuelve los parámetros que el texto utiliza, para detectar marcadores sin resolver.
+type Engine interface {
+	Render(name, text string, params Params) (string, error)
+	RenderText(name, text string, params Params) (string, error)
+	References(name, text string) ([]Reference, error)
+}
+
+// Params son los parámetros del mensaje por nombre. Un parámetro que llega una sola vez es un string, o un []any o
+// map[string]any si su valor es una lista u objeto JSON. Si el productor repite el nombre, el valor es la lista de
+// todos sus valores en orden: []string si todos son textos y []any si alguno es estructurado.
+type Params map[string]any
+
+// NewParams agrupa los parámetros del mensaje SQS por nombre.
+func NewParams(parametros []models.ParametrosSQS) Params {
+	params := Params{}
+	// repeated son los parámetros repetidos con algún valor estructurado, cuya lista ya es un []any
+	repeated := map[string]bool{}
+	for _, param := range parametros {
+		var value any = param.Valor
+		if param.Datos != nil {
+			value = param.Datos
+		}
+
+		current, exists := params[param.Nombre]
+		if !exists {
+			params[param.Nombre] = value
+			continue
+		}
+		text, isText := value.(string)
+		switch c := current.(type) {
+		case string:
+			if isText {
+				params[param.Nombre] = []string{c, text}
+				continue
+			}
+		case []string:
+			if isText {
+				params[param.Nombre] = append(c, text)
+				continue
+			}
+		}
+
+		if repeated[param.Nombre] {
+			params[param.Nombre] = append(current.([]any), value)
+			continue
+		}
+		var values []any
+		if list, ok := current.([]string); ok {
+			for _, v := range list {
+				values = append(values, v)
+			}
+		} else {
+			values = append(values, current)
+		}
+		params[param.Nombre] = append(values, value)
+		repeated[param.Nombre] = true
+	}
+	return params
+}
+
+// ForMotor devuelve el motor configurado en la plantilla. Un motor vacío equivale a EngineLegacy; uno desconocido
+// es un error permanente.
+func ForMotor(motor string) (Engine, error) {
+	switch strings.ToLower(strings.TrimSpace(motor)) {
+	case "", EngineLegacy:
+		return LegacyEngine{}, nil
+	case EngineHTML:
+		return HTMLEngine{}, nil
+	default:
+		return nil, models.NewPermanentError(fmt.Errorf("error: motor de plantillas %q no soportado (%s o %s)",
+			motor, EngineLegacy, EngineHTML))
+	}
+}
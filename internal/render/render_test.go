@@ -0,0 +1,164 @@
+package render
+
+import (
+	"encoding/json"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewParamsGroupsRepeatedNames(t *testing.T) {
+	params := NewParams([]models.ParametrosSQS{
+		{Nombre: "nombre_archivo", Valor: "pagos.csv"},
+		{Nombre: "registro", Valor: "R1"},
+		{Nombre: "registro", Valor: "R2"},
+		{Nombre: "registro", Valor: "R3"},
+	})
+
+	assert.Equal(t, Params{"nombre_archivo": "pagos.csv", "registro": []string{"R1", "R2", "R3"}}, params)
+}
+
+func TestNewParamsStructuredValues(t *testing.T) {
+	rechazos := []any{map[string]any{"codigo": "E01"}}
+	params := NewParams([]models.ParametrosSQS{
+		{Nombre: "rechazos", Valor: `[{"codigo":"E01"}]`, Datos: rechazos},
+		{Nombre: "registro", Valor: "R1"},
+		{Nombre: "registro", Valor: "R2"},
+		{Nombre: "registro", Valor: `{"id":"R3"}`, Datos: map[string]any{"id": "R3"}},
+		{Nombre: "registro", Valor: "R4"},
+	})
+
+	assert.Equal(t, Params{
+		"rechazos": rechazos,
+		"registro": []any{"R1", "R2", map[string]any{"id": "R3"}, "R4"},
+	}, params)
+}
+
+func TestHTMLEngineRendersTablesFromStructuredValues(t *testing.T) {
+	var msg models.SQSMessage
+	err := json.Unmarshal([]byte(`{"id_plantilla": "PC001", "parametros": [{"nombre": "rechazos", "valor": [
+		{"codigo": "E01", "descripcion": "Cuenta <inválida>", "valor": 1500.5},
+		{"codigo": "E02", "descripcion": "Fecha vencida", "valor": 20}
+	]}]}`), &msg)
+	assert.NoError(t, err)
+
+	text := `<table>{{range .rechazos}}<tr><td>{{.codigo}}</td><td>{{.descripcion}}</td>` +
+		`<td>{{.valor | currency}}</td></tr>{{end}}</table>{{len .rechazos}} rechazos`
+	out, err := HTMLEngine{}.Render("PC001", text, NewParams(msg.Parametro))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "<table><tr><td>E01</td><td>Cuenta &lt;inválida&gt;</td><td>$ 1.500,50</td></tr>"+
+		"<tr><td>E02</td><td>Fecha vencida</td><td>$ 20,00</td></tr></table>2 rechazos", out)
+
+	// El motor legacy reemplaza el marcador por el JSON del valor
+	out, err = LegacyEngine{}.Render("PC001", "Rechazos: &rechazos", Params{"rechazos": []any{"E01", "E02"}})
+	assert.NoError(t, err)
+	assert.Equal(t, `Rechazos: ["E01","E02"]`, out)
+}
+
+func TestForMotor(t *testing.T) {
+	for motor, want := range map[string]Engine{"": LegacyEngine{}, "legacy": LegacyEngine{}, " HTML ": HTMLEngine{}} {
+		engine, err := ForMotor(motor)
+		assert.NoError(t, err)
+		assert.Equal(t, want, engine)
+	}
+
+	_, err := ForMotor("mustache")
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "mustache")
+}
+
+func TestLegacyEngineKeepsRawReplacement(t *testing.T) {
+	params := Params{"archivo": "<b>a&b</b>", "archivo_rechazado": "x.csv", "codigo": []string{"01", "02"}}
+
+	out, err := LegacyEngine{}.Render("PC001", "&archivo_rechazado / &archivo / &codigo", params)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "x.csv / <b>a&b</b> / 02", out)
+}
+
+func TestLegacyEngineKeepsQueryStringLinks(t *testing.T) {
+	params := Params{"b": "X", "referencia": "R-1"}
+
+	out, err := LegacyEngine{}.Render("PC001", `<a href="https://x.co/?a=1&b=2&ref=&referencia">&b</a>`, params)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `<a href="https://x.co/?a=1&b=2&ref=R-1">X</a>`, out)
+}
+
+func TestHTMLEngineEscapesValues(t *testing.T) {
+	params := Params{"archivo": `<script>alert("x")</script> & co.csv`, "enlace": "javascript:alert(1)"}
+
+	out, err := HTMLEngine{}.Render("PC001", `<p>{{.archivo}}</p><a href="{{.enlace}}">ver</a>`, params)
+
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; co.csv</p><a href="#ZgotmplZ">ver</a>`, out)
+}
+
+func TestHTMLEngineConditionalsAndLoops(t *testing.T) {
+	text := `{{if .codigo_rechazo}}Rechazado ({{.codigo_rechazo}}){{else}}Aceptado{{end}}:` +
+		`{{range list .registro}}<li>{{.}}</li>{{end}}|{{join ", " .registro}}|` +
+		`{{range split ";" .correos}}[{{trim .}}]{{end}}`
+
+	out, err := HTMLEngine{}.Render("PC001", text, Params{
+		"registro": []string{"R1", "R<2>"},
+		"correos":  "a@test.com; b@test.com",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Aceptado:<li>R1</li><li>R&lt;2&gt;</li>|R1, R&lt;2&gt;|[a@test.com][b@test.com]", out)
+
+	// Un solo valor también puede recorrerse con list
+	out, err = HTMLEngine{}.Render("PC001", text, Params{"codigo_rechazo": "E01", "registro": "R1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Rechazado (E01):<li>R1</li>|R1|", out)
+}
+
+func TestHTMLEngineInvalidTemplateIsPermanent(t *testing.T) {
+	_, err := HTMLEngine{}.Render("PC001", "{{if .x}}sin cerrar", Params{})
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "PC001")
+
+	_, err = HTMLEngine{}.Render("PC001", "{{index .x 3}}", Params{"x": []string{"a"}})
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestRenderTextDoesNotEscapeHTML(t *testing.T) {
+	params := Params{"archivo": "pagos & cobros <v2>.csv"}
+
+	out, err := HTMLEngine{}.RenderText("PC001/asunto", "Rechazo de {{.archivo}}", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "Rechazo de pagos & cobros <v2>.csv", out)
+
+	out, err = LegacyEngine{}.RenderText("PC001/asunto", "Rechazo de &archivo", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "Rechazo de pagos & cobros <v2>.csv", out)
+
+	_, err = HTMLEngine{}.RenderText("PC001/asunto", "{{.archivo", params)
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestHTMLEngineReusesParsedTemplates(t *testing.T) {
+	ResetCache()
+	engine := HTMLEngine{}
+
+	first, err := engine.Render("PC001", "<p>{{.nombre}}</p>", Params{"nombre": "Ana"})
+	assert.NoError(t, err)
+	parsed := cachedHTML("PC001", "<p>{{.nombre}}</p>")
+	assert.NotNil(t, parsed)
+
+	second, err := engine.Render("PC001", "<p>{{.nombre}}</p>", Params{"nombre": "Luis"})
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>Ana</p>", first)
+	assert.Equal(t, "<p>Luis</p>", second)
+	assert.Same(t, parsed, cachedHTML("PC001", "<p>{{.nombre}}</p>"))
+
+	// Una plantilla editada se analiza de nuevo
+	edited, err := engine.Render("PC001", "<b>{{.nombre}}</b>", Params{"nombre": "Ana"})
+	assert.NoError(t, err)
+	assert.Equal(t, "<b>Ana</b>", edited)
+
+	ResetCache()
+	assert.Nil(t, cachedHTML("PC001", "<p>{{.nombre}}</p>"))
+}
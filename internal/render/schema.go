@@ -0,0 +1,128 @@
+package render
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts son los formatos de fecha admitidos en los parámetros de tipo date.
+var dateLayouts = []string{"2006-01-02", "02/01/2006", time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// ParameterValidationError lista los parámetros del mensaje que no cumplen el esquema declarado de la plantilla.
+type ParameterValidationError struct {
+	IDPlantilla string
+	Problems    []string
+}
+
+func (e *ParameterValidationError) Error() string {
+	return fmt.Sprintf("error: parámetros inválidos para la plantilla %s: %s",
+		e.IDPlantilla, strings.Join(e.Problems, "; "))
+}
+
+// Validate comprueba los parámetros contra el esquema declarado de la plantilla y devuelve una copia con los
+// valores por defecto de los parámetros ausentes. Los problemas se reportan todos juntos como un error permanente.
+// Los parámetros no declarados no se validan aquí (ver Check).
+func Validate(idPlantilla string, schema []models.ParametroPlantilla, params Params) (Params, error) {
+	validated := make(Params, len(params)+len(schema))
+	for name, value := range params {
+		validated[name] = value
+	}
+
+	var problems []string
+	for _, declared := range schema {
+		value, ok := params[declared.Nombre]
+		if !ok {
+			switch {
+			case declared.ValorDefecto != nil:
+				validated[declared.Nombre] = *declared.ValorDefecto
+			case declared.Requerido:
+				problems = append(problems, fmt.Sprintf("falta el parámetro requerido %s", declared.Nombre))
+			}
+			continue
+		}
+		if problem := validateValue(declared, value); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, models.NewPermanentError(&ParameterValidationError{IDPlantilla: idPlantilla, Problems: problems})
+	}
+	return validated, nil
+}
+
+func validateValue(declared models.ParametroPlantilla, value any) string {
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = []string{v}
+	case []string:
+		if declared.Tipo != models.TipoParametroLista {
+			return fmt.Sprintf("el parámetro %s admite un solo valor y se recibieron %d", declared.Nombre, len(v))
+		}
+		values = v
+	case []any:
+		// Una lista JSON, o un parámetro repetido con algún valor estructurado
+		switch declared.Tipo {
+		case models.TipoParametroJSON:
+			return ""
+		case models.TipoParametroLista:
+			for _, item := range v {
+				if _, structured := item.(map[string]any); structured {
+					return fmt.Sprintf("el parámetro %s debe ser una lista de valores simples", declared.Nombre)
+				}
+				values = append(values, textValue(item))
+			}
+		default:
+			return fmt.Sprintf("el parámetro %s no admite una lista JSON", declared.Nombre)
+		}
+	case map[string]any:
+		if declared.Tipo != models.TipoParametroJSON {
+			return fmt.Sprintf("el parámetro %s no admite un objeto JSON", declared.Nombre)
+		}
+		return ""
+	}
+
+	var pattern *regexp.Regexp
+	if declared.Patron != "" {
+		var err error
+		if pattern, err = regexp.Compile(`^(?:` + declared.Patron + `)$`); err != nil {
+			return fmt.Sprintf("el patrón declarado para %s no es válido: %v", declared.Nombre, err)
+		}
+	}
+
+	for _, v := range values {
+		switch declared.Tipo {
+		case "", models.TipoParametroTexto, models.TipoParametroLista, models.TipoParametroJSON:
+		case models.TipoParametroNumero:
+			if _, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+				return fmt.Sprintf("el parámetro %s debe ser un número: %q", declared.Nombre, v)
+			}
+		case models.TipoParametroFecha:
+			if _, ok := parseDate(v); !ok {
+				return fmt.Sprintf("el parámetro %s debe ser una fecha (AAAA-MM-DD o DD/MM/AAAA): %q", declared.Nombre, v)
+			}
+		default:
+			return fmt.Sprintf("el tipo %q declarado para %s no es válido", declared.Tipo, declared.Nombre)
+		}
+		if pattern != nil && !pattern.MatchString(v) {
+			return fmt.Sprintf("el parámetro %s no cumple el patrón %s: %q", declared.Nombre, declared.Patron, v)
+		}
+	}
+	return ""
+}
+
+// parseDate interpreta una fecha en alguno de los formatos de dateLayouts.
+func parseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
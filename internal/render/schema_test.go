@@ -0,0 +1,108 @@
+package render
+
+import (
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func TestValidateFillsDefaults(t *testing.T) {
+	schema := []models.ParametroPlantilla{
+		{Nombre: "nombre_archivo", Tipo: models.TipoParametroTexto, Requerido: true, Patron: `[\w.-]+\.csv`},
+		{Nombre: "fecha_recepcion", Tipo: models.TipoParametroFecha, Requerido: true},
+		{Nombre: "total", Tipo: models.TipoParametroNumero},
+		{Nombre: "registro", Tipo: models.TipoParametroLista, Patron: `R\d+`},
+		{Nombre: "canal", ValorDefecto: stringPtr("SFTP")},
+	}
+	params := Params{
+		"nombre_archivo":  "pagos_2024.csv",
+		"fecha_recepcion": "07/10/2024",
+		"registro":        []string{"R1", "R22"},
+		"extra":           "x",
+	}
+
+	validated, err := Validate("PC001", schema, params)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SFTP", validated["canal"])
+	assert.Equal(t, "x", validated["extra"])
+	assert.NotContains(t, validated, "total")
+	assert.NotContains(t, params, "canal", "Los parámetros originales no se modifican")
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	schema := []models.ParametroPlantilla{
+		{Nombre: "nombre_archivo", Requerido: true},
+		{Nombre: "fecha_recepcion", Tipo: models.TipoParametroFecha},
+		{Nombre: "total", Tipo: models.TipoParametroNumero},
+		{Nombre: "codigo_rechazo", Patron: `[A-Z]\d{2}`},
+		{Nombre: "sistema"},
+		{Nombre: "registro", Tipo: models.TipoParametroLista, Patron: `R\d+`},
+	}
+	params := Params{
+		"fecha_recepcion": "2024-13-45",
+		"total":           "1.234,50",
+		"codigo_rechazo":  "E1",
+		"sistema":         []string{"A", "B"},
+		"registro":        []string{"R1", "X"},
+	}
+
+	_, err := Validate("PC001", schema, params)
+
+	assert.True(t, models.IsPermanentError(err))
+	var validationErr *ParameterValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Se esperaba un ParameterValidationError, se obtuvo %v", err)
+	}
+	assert.Equal(t, []string{
+		"falta el parámetro requerido nombre_archivo",
+		`el parámetro fecha_recepcion debe ser una fecha (AAAA-MM-DD o DD/MM/AAAA): "2024-13-45"`,
+		`el parámetro total debe ser un número: "1.234,50"`,
+		`el parámetro codigo_rechazo no cumple el patrón [A-Z]\d{2}: "E1"`,
+		"el parámetro sistema admite un solo valor y se recibieron 2",
+		`el parámetro registro no cumple el patrón R\d+: "X"`,
+	}, validationErr.Problems)
+}
+
+func TestValidateInvalidSchema(t *testing.T) {
+	_, err := Validate("PC001", []models.ParametroPlantilla{
+		{Nombre: "a", Tipo: "boolean"},
+		{Nombre: "b", Patron: "("},
+	}, Params{"a": "x", "b": "y"})
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), `el tipo "boolean" declarado para a no es válido`)
+	assert.Contains(t, err.Error(), "el patrón declarado para b no es válido")
+}
+
+func TestValidateStructuredValues(t *testing.T) {
+	schema := []models.ParametroPlantilla{
+		{Nombre: "rechazos", Tipo: models.TipoParametroJSON, Requerido: true},
+		{Nombre: "registro", Tipo: models.TipoParametroLista, Patron: `R\d+`},
+	}
+
+	_, err := Validate("PC001", schema, Params{
+		"rechazos": []any{map[string]any{"codigo": "E01"}},
+		"registro": []any{"R1", "R2"},
+	})
+	assert.NoError(t, err)
+
+	schema = append(schema, models.ParametroPlantilla{Nombre: "total", Tipo: models.TipoParametroNumero})
+	_, err = Validate("PC001", schema, Params{
+		"rechazos": map[string]any{"codigo": "E01"},
+		"registro": []any{"R1", map[string]any{"id": "R2"}},
+		"total":    []any{"1", "2"},
+	})
+	var validationErr *ParameterValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{
+		"el parámetro registro debe ser una lista de valores simples",
+		"el parámetro total no admite una lista JSON",
+	}, validationErr.Problems)
+}
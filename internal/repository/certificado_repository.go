@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"errors"
+	"gmf_message_processor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GormCertificadoRepository obtiene los certificados S/MIME de los destinatarios utilizando GORM.
+type GormCertificadoRepository struct {
+	DB DBInterface
+}
+
+func NewCertificadoRepository(db DBInterface) *GormCertificadoRepository {
+	return &GormCertificadoRepository{DB: db}
+}
+
+// FindCertificate devuelve el certificado de la dirección indicada, o nil si no existe.
+func (repo *GormCertificadoRepository) FindCertificate(direccion string) (*models.CertificadoCorreo, error) {
+	var certificado models.CertificadoCorreo
+
+	if err := repo.DB.Where("direccion = ?", normalizeDireccion(direccion)).First(&certificado).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &certificado, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCertificadoRepositoryFindCertificate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(&models.CertificadoCorreo{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	certificado := &models.CertificadoCorreo{Direccion: "tesoreria@test.com", Certificado: "-----BEGIN CERTIFICATE-----"}
+	if err := db.Create(certificado).Error; err != nil {
+		t.Fatalf("Error al insertar el certificado de prueba: %v", err)
+	}
+
+	repo := NewCertificadoRepository(db)
+
+	// La dirección se busca sin distinguir mayúsculas
+	encontrado, err := repo.FindCertificate(" Tesoreria@Test.com")
+	if err != nil {
+		t.Fatalf("Error al consultar el certificado: %v", err)
+	}
+	if encontrado == nil || encontrado.Certificado != certificado.Certificado {
+		t.Errorf("Se esperaba el certificado de tesoreria@test.com, se obtuvo %+v", encontrado)
+	}
+
+	encontrado, err = repo.FindCertificate("otro@test.com")
+	if err != nil {
+		t.Fatalf("Error al consultar el certificado: %v", err)
+	}
+	if encontrado != nil {
+		t.Errorf("Se esperaba nil para una dirección sin certificado, se obtuvo %+v", encontrado)
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"gorm.io/gorm"
+)
+
+// EnvioDBInterface define las operaciones de la base de datos que necesita el registro de envíos.
+type EnvioDBInterface interface {
+	Create(value interface{}) *gorm.DB
+	Where(query interface{}, args ...interface{}) *gorm.DB
+}
+
+// GormEnvioRepository registra los intentos de envío de correo utilizando GORM.
+type GormEnvioRepository struct {
+	DB EnvioDBInterface
+}
+
+func NewEnvioRepository(db EnvioDBInterface) *GormEnvioRepository {
+	return &GormEnvioRepository{DB: db}
+}
+
+// RecordSend guarda un intento de envío en la tabla de envíos.
+func (repo *GormEnvioRepository) RecordSend(envio *models.EnvioCorreo) error {
+	return repo.DB.Create(envio).Error
+}
+
+// FindMessageID devuelve el MessageId de SQS del envío al que el proveedor asignó el identificador indicado, o "" si
+// ningún envío registrado lo tiene.
+func (repo *GormEnvioRepository) FindMessageID(proveedorMessageID string) (string, error) {
+	var envios []models.EnvioCorreo
+	err := repo.DB.Where("proveedor_message_id = ?", proveedorMessageID).Limit(1).Find(&envios).Error
+	if err != nil || len(envios) == 0 {
+		return "", err
+	}
+	return envios[0].MessageID, nil
+}
+
+// GormEventoRepository registra los eventos de entrega, rebote y queja reportados por el proveedor.
+type GormEventoRepository struct {
+	DB EnvioDBInterface
+}
+
+func NewEventoRepository(db EnvioDBInterface) *GormEventoRepository {
+	return &GormEventoRepository{DB: db}
+}
+
+// RecordEvent guarda un evento en la tabla de eventos.
+func (repo *GormEventoRepository) RecordEvent(evento *models.EventoCorreo) error {
+	return repo.DB.Create(evento).Error
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRecordSend(t *testing.T) {
+	// Crear una base de datos en memoria usando SQLite
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+
+	// Limpiar después de la prueba
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+
+	if err := db.AutoMigrate(&models.EnvioCorreo{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	repo := NewEnvioRepository(db)
+	err = repo.RecordSend(&models.EnvioCorreo{
+		MessageID:     "msg-1",
+		Proveedor:     "smtp_secondary",
+		Remitente:     "sender@test.com",
+		Destinatarios: "a@test.com",
+		Estado:        models.EstadoEnvioEntregado,
+	})
+	if err != nil {
+		t.Fatalf("Error al registrar el envío: %v", err)
+	}
+
+	var envio models.EnvioCorreo
+	if err := db.Where("message_id = ?", "msg-1").First(&envio).Error; err != nil {
+		t.Fatalf("El envío debería existir en la base de datos: %v", err)
+	}
+	if envio.Proveedor != "smtp_secondary" || envio.Estado != models.EstadoEnvioEntregado {
+		t.Fatalf("Envío registrado incorrecto: %+v", envio)
+	}
+}
+
+func TestFindMessageID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+
+	if err := db.AutoMigrate(&models.EnvioCorreo{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	repo := NewEnvioRepository(db)
+	if err := repo.RecordSend(&models.EnvioCorreo{
+		MessageID:          "msg-1",
+		Proveedor:          "ses",
+		ProveedorMessageID: "ses-1",
+		Estado:             models.EstadoEnvioEntregado,
+	}); err != nil {
+		t.Fatalf("Error al registrar el envío: %v", err)
+	}
+
+	messageID, err := repo.FindMessageID("ses-1")
+	if err != nil || messageID != "msg-1" {
+		t.Fatalf("Se esperaba el envío msg-1, se obtuvo %q (%v)", messageID, err)
+	}
+	messageID, err = repo.FindMessageID("ses-2")
+	if err != nil || messageID != "" {
+		t.Fatalf("No se esperaba ningún envío, se obtuvo %q (%v)", messageID, err)
+	}
+}
+
+func TestRecordEvent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+
+	if err := db.AutoMigrate(&models.EventoCorreo{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	repo := NewEventoRepository(db)
+	if err := repo.RecordEvent(&models.EventoCorreo{
+		MessageID:    "msg-1",
+		Tipo:         "Bounce",
+		Destinatario: "a@test.com",
+	}); err != nil {
+		t.Fatalf("Error al registrar el evento: %v", err)
+	}
+
+	var count int64
+	db.Model(&models.EventoCorreo{}).Where("message_id = ?", "msg-1").Count(&count)
+	if count != 1 {
+		t.Fatalf("Se esperaba 1 evento registrado, se obtuvieron %d", count)
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GormFragmentoRepository obtiene los layouts y parciales de las plantillas y administra su historial de versiones
+// utilizando GORM. Un idioma vacío equivale al predeterminado.
+type GormFragmentoRepository struct {
+	DB VersionDBInterface
+}
+
+func NewFragmentoRepository(db VersionDBInterface) *GormFragmentoRepository {
+	return &GormFragmentoRepository{DB: db}
+}
+
+// FindFragmento devuelve el fragmento en el idioma más cercano al solicitado según models.IdiomaChain, o nil si no
+// existe en ninguno. Igual que las plantillas, se sirve desde su versión activa si tiene versiones registradas.
+func (repo *GormFragmentoRepository) FindFragmento(nombre, idioma string) (*models.Fragmento, error) {
+	chain := models.IdiomaChain(idioma)
+
+	var fragmentos []models.Fragmento
+	if err := repo.DB.Where("nombre = ? AND idioma IN ?", nombre, chain).Find(&fragmentos).Error; err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range chain {
+		for i := range fragmentos {
+			if fragmentos[i].Idioma == candidate {
+				return repo.activeFragmento(&fragmentos[i])
+			}
+		}
+	}
+	return nil, nil
+}
+
+// activeFragmento reemplaza el contenido del fragmento por el de su versión activa, si la tiene.
+func (repo *GormFragmentoRepository) activeFragmento(fragmento *models.Fragmento) (*models.Fragmento, error) {
+	var versiones []models.FragmentoVersion
+	err := repo.DB.Where("nombre = ? AND idioma = ? AND activa = ?", fragmento.Nombre, fragmento.Idioma, true).
+		Limit(1).
+		Find(&versiones).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(versiones) > 0 {
+		fragmento.Tipo = versiones[0].Tipo
+		fragmento.Contenido = versiones[0].Contenido
+		fragmento.Version = versiones[0].Version
+	}
+	return fragmento, nil
+}
+
+// ListFragmentoVersions devuelve las versiones del fragmento, de la más antigua a la más reciente.
+func (repo *GormFragmentoRepository) ListFragmentoVersions(nombre, idioma string) ([]models.FragmentoVersion, error) {
+	var versiones []models.FragmentoVersion
+	err := repo.DB.Where("nombre = ? AND idioma = ?", nombre, versionIdioma(idioma)).
+		Order("version").
+		Find(&versiones).Error
+	return versiones, err
+}
+
+// SnapshotFragmento registra el contenido actual del fragmento como una nueva versión y la marca como activa.
+func (repo *GormFragmentoRepository) SnapshotFragmento(nombre, idioma string) (*models.FragmentoVersion, error) {
+	idioma = versionIdioma(idioma)
+	var snapshot *models.FragmentoVersion
+
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var fragmento models.Fragmento
+		if err := tx.Where("nombre = ? AND idioma = ?", nombre, idioma).First(&fragmento).Error; err != nil {
+			return fragmentoNotFound(nombre, idioma, err)
+		}
+
+		var latest int
+		err := tx.Model(&models.FragmentoVersion{}).
+			Where("nombre = ? AND idioma = ?", nombre, idioma).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+
+		snapshot = models.NewFragmentoVersion(&fragmento, latest+1)
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+		return activateFragmentoVersion(tx, nombre, idioma, snapshot.Version)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Activa = true
+	return snapshot, nil
+}
+
+// RollbackFragmento restaura en cgd_correos_fragmentos el contenido de una versión anterior y la marca como activa.
+func (repo *GormFragmentoRepository) RollbackFragmento(nombre, idioma string, version int) error {
+	idioma = versionIdioma(idioma)
+
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		var fragmentoVersion models.FragmentoVersion
+		err := tx.Where("nombre = ? AND idioma = ? AND version = ?", nombre, idioma, version).
+			First(&fragmentoVersion).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("la versión %d del fragmento %s (%s) no existe", version, nombre, idioma)
+			}
+			return err
+		}
+
+		result := tx.Model(&models.Fragmento{}).
+			Where("nombre = ? AND idioma = ?", nombre, idioma).
+			Updates(map[string]interface{}{
+				"tipo":      fragmentoVersion.Tipo,
+				"contenido": fragmentoVersion.Contenido,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fragmentoNotFound(nombre, idioma, gorm.ErrRecordNotFound)
+		}
+		return activateFragmentoVersion(tx, nombre, idioma, version)
+	})
+}
+
+// activateFragmentoVersion deja activa únicamente la versión indicada y la registra en el fragmento.
+func activateFragmentoVersion(tx *gorm.DB, nombre, idioma string, version int) error {
+	// Se desactivan primero todas las versiones para no violar el índice único parcial de la versión activa
+	err := tx.Model(&models.FragmentoVersion{}).
+		Where("nombre = ? AND idioma = ?", nombre, idioma).
+		Update("activa", false).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.FragmentoVersion{}).
+		Where("nombre = ? AND idioma = ? AND version = ?", nombre, idioma, version).
+		Update("activa", true).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Fragmento{}).
+		Where("nombre = ? AND idioma = ?", nombre, idioma).
+		Update("version", version).Error
+}
+
+func fragmentoNotFound(nombre, idioma string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("el fragmento %s (%s) no existe", nombre, idioma)
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newFragmentoTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(&models.Fragmento{}, &models.FragmentoVersion{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+	return db
+}
+
+func TestFindFragmentoFallsBack(t *testing.T) {
+	db := newFragmentoTestDB(t)
+	for _, fragmento := range []models.Fragmento{
+		{Nombre: "pie", Idioma: "es-CO", Contenido: "Confidencial"},
+		{Nombre: "pie", Idioma: "en", Contenido: "Confidential"},
+	} {
+		if err := db.Create(&fragmento).Error; err != nil {
+			t.Fatalf("Error al insertar fragmento de prueba: %v", err)
+		}
+	}
+
+	repo := NewFragmentoRepository(db)
+
+	fragmento, err := repo.FindFragmento("pie", "en-US")
+	if err != nil {
+		t.Fatalf("Error al buscar el fragmento: %v", err)
+	}
+	if fragmento == nil || fragmento.Contenido != "Confidential" || fragmento.Tipo != models.TipoFragmentoParcial {
+		t.Fatalf("Fragmento inesperado: %+v", fragmento)
+	}
+
+	fragmento, err = repo.FindFragmento("pie", "fr")
+	if err != nil || fragmento == nil || fragmento.Idioma != "es-CO" {
+		t.Fatalf("Se esperaba el fragmento en el idioma predeterminado: %+v, %v", fragmento, err)
+	}
+
+	fragmento, err = repo.FindFragmento("encabezado", "")
+	if err != nil || fragmento != nil {
+		t.Fatalf("El fragmento encabezado no debería existir: %+v, %v", fragmento, err)
+	}
+}
+
+func TestFragmentoRepositorySnapshotAndRollback(t *testing.T) {
+	db := newFragmentoTestDB(t)
+	fragmento := models.Fragmento{Nombre: "pie", Tipo: models.TipoFragmentoParcial, Contenido: "Pie v1"}
+	if err := db.Create(&fragmento).Error; err != nil {
+		t.Fatalf("Error al insertar fragmento de prueba: %v", err)
+	}
+
+	repo := NewFragmentoRepository(db)
+
+	if _, err := repo.SnapshotFragmento("pie", ""); err != nil {
+		t.Fatalf("Error al registrar la versión: %v", err)
+	}
+	if err := db.Model(&models.Fragmento{}).Where("nombre = ?", "pie").Update("contenido", "Pie v2").Error; err != nil {
+		t.Fatalf("Error al actualizar el fragmento: %v", err)
+	}
+
+	// La edición no se incluye en las plantillas hasta registrarla como versión
+	served, err := repo.FindFragmento("pie", "")
+	if err != nil || served == nil || served.Contenido != "Pie v1" || served.Version != 1 {
+		t.Fatalf("Se esperaba la versión activa 1: %+v, %v", served, err)
+	}
+
+	second, err := repo.SnapshotFragmento("pie", "")
+	if err != nil {
+		t.Fatalf("Error al registrar la versión: %v", err)
+	}
+	if second.Version != 2 || !second.Activa || second.Contenido != "Pie v2" {
+		t.Fatalf("Versión inesperada: %+v", second)
+	}
+
+	if err := repo.RollbackFragmento("pie", "", 1); err != nil {
+		t.Fatalf("Error al restaurar la versión: %v", err)
+	}
+
+	var restored models.Fragmento
+	if err := db.Where("nombre = ?", "pie").First(&restored).Error; err != nil {
+		t.Fatalf("Error al consultar el fragmento: %v", err)
+	}
+	if restored.Contenido != "Pie v1" || restored.Version != 1 {
+		t.Errorf("Fragmento no restaurado: %+v", restored)
+	}
+
+	versiones, err := repo.ListFragmentoVersions("pie", "")
+	if err != nil {
+		t.Fatalf("Error al listar las versiones: %v", err)
+	}
+	if len(versiones) != 2 || !versiones[0].Activa || versiones[1].Activa {
+		t.Errorf("Versiones inesperadas: %+v", versiones)
+	}
+
+	if err := repo.RollbackFragmento("pie", "", 9); err == nil {
+		t.Errorf("Se esperaba un error al restaurar una versión inexistente")
+	}
+	if _, err := repo.SnapshotFragmento("encabezado", ""); err == nil {
+		t.Errorf("Se esperaba un error al versionar un fragmento inexistente")
+	}
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"errors"
+	"gmf_message_processor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GormImagenRepository obtiene las imágenes inline de las plantillas utilizando GORM.
+type GormImagenRepository struct {
+	DB DBInterface
+}
+
+func NewImagenRepository(db DBInterface) *GormImagenRepository {
+	return &GormImagenRepository{DB: db}
+}
+
+// FindImage devuelve la imagen con el nombre indicado, o nil si no existe.
+func (repo *GormImagenRepository) FindImage(nombre string) (*models.ImagenCorreo, error) {
+	var imagen models.ImagenCorreo
+
+	if err := repo.DB.Where("nombre = ?", nombre).First(&imagen).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &imagen, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"bytes"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestImagenRepositoryFindImage(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(&models.ImagenCorreo{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	logo := &models.ImagenCorreo{Nombre: "logo.png", ContentType: "image/png", Contenido: []byte{0x89, 'P', 'N', 'G'}}
+	if err := db.Create(logo).Error; err != nil {
+		t.Fatalf("Error al insertar la imagen de prueba: %v", err)
+	}
+
+	repo := NewImagenRepository(db)
+
+	imagen, err := repo.FindImage("logo.png")
+	if err != nil {
+		t.Fatalf("Error al consultar la imagen: %v", err)
+	}
+	if imagen == nil || imagen.ContentType != "image/png" || !bytes.Equal(imagen.Contenido, logo.Contenido) {
+		t.Errorf("Se esperaba la imagen logo.png, se obtuvo %+v", imagen)
+	}
+
+	imagen, err = repo.FindImage("no-existe.png")
+	if err != nil {
+		t.Fatalf("Error al consultar la imagen: %v", err)
+	}
+	if imagen != nil {
+		t.Errorf("Se esperaba nil para una imagen inexistente, se obtuvo %+v", imagen)
+	}
+}
@@ -0,0 +1,20 @@
+package repository
+
+import "gmf_message_processor/internal/models"
+
+// GormParametroRepository obtiene los parámetros declarados de las plantillas utilizando GORM.
+type GormParametroRepository struct {
+	DB DBInterface
+}
+
+func NewParametroRepository(db DBInterface) *GormParametroRepository {
+	return &GormParametroRepository{DB: db}
+}
+
+// FindParametros devuelve los parámetros declarados para la plantilla, ordenados por nombre. Una plantilla sin
+// parámetros declarados devuelve una lista vacía.
+func (repo *GormParametroRepository) FindParametros(idPlantilla string) ([]models.ParametroPlantilla, error) {
+	var parametros []models.ParametroPlantilla
+	err := repo.DB.Where("id_plantilla = ?", idPlantilla).Order("nombre").Find(&parametros).Error
+	return parametros, err
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestParametroRepositoryFindParametros(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(&models.ParametroPlantilla{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	canal := "SFTP"
+	parametros := []models.ParametroPlantilla{
+		{IDPlantilla: "PC001", Nombre: "nombre_archivo", Tipo: models.TipoParametroTexto, Requerido: true},
+		{IDPlantilla: "PC001", Nombre: "canal", ValorDefecto: &canal},
+		{IDPlantilla: "PC002", Nombre: "total", Tipo: models.TipoParametroNumero},
+	}
+	if err := db.Create(&parametros).Error; err != nil {
+		t.Fatalf("Error al insertar los parámetros de prueba: %v", err)
+	}
+
+	repo := NewParametroRepository(db)
+
+	encontrados, err := repo.FindParametros("PC001")
+	if err != nil {
+		t.Fatalf("Error al consultar los parámetros: %v", err)
+	}
+	if len(encontrados) != 2 || encontrados[0].Nombre != "canal" || encontrados[1].Nombre != "nombre_archivo" {
+		t.Fatalf("Se esperaban los parámetros canal y nombre_archivo, se obtuvo %+v", encontrados)
+	}
+	if encontrados[0].ValorDefecto == nil || *encontrados[0].ValorDefecto != canal {
+		t.Errorf("Se esperaba el valor por defecto %q, se obtuvo %v", canal, encontrados[0].ValorDefecto)
+	}
+	if encontrados[0].Tipo != models.TipoParametroTexto || !encontrados[1].Requerido {
+		t.Errorf("Tipo o requerido inesperados: %+v", encontrados)
+	}
+
+	encontrados, err = repo.FindParametros("PC009")
+	if err != nil {
+		t.Fatalf("Error al consultar los parámetros: %v", err)
+	}
+	if len(encontrados) != 0 {
+		t.Errorf("Se esperaba una lista vacía, se obtuvo %+v", encontrados)
+	}
+}
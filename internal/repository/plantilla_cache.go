@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/models"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Valores por defecto de la caché de plantillas (TEMPLATE_CACHE_TTL y TEMPLATE_CACHE_NEGATIVE_TTL).
+const (
+	defaultCacheTTL         = 5 * time.Minute
+	defaultCacheNegativeTTL = time.Minute
+)
+
+// PlantillaSource es el repositorio de plantillas que decora CachedPlantillaRepository.
+type PlantillaSource interface {
+	CheckPlantillaExists(idPlantilla string) (bool, *models.Plantilla, error)
+	FindPlantillaIdioma(idPlantilla, idioma string) (bool, *models.Plantilla, error)
+}
+
+// CachedPlantillaRepository guarda en memoria las plantillas consultadas para que una Lambda caliente no consulte
+// la base de datos en cada mensaje. Las plantillas inexistentes también se guardan (caché negativa) durante
+// negativeTTL. Los errores de la base de datos no se guardan. Cada consulta devuelve una copia, ya que el servicio
+// modifica la plantilla al renderizarla.
+type CachedPlantillaRepository struct {
+	source      PlantillaSource
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	idPlantilla string
+	idioma      string
+}
+
+type cacheEntry struct {
+	plantilla *models.Plantilla // nil si la plantilla no existe
+	expires   time.Time
+}
+
+func NewCachedPlantillaRepository(
+	source PlantillaSource, ttl, negativeTTL time.Duration) *CachedPlantillaRepository {
+	return &CachedPlantillaRepository{
+		source:      source,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		entries:     map[cacheKey]cacheEntry{},
+	}
+}
+
+// CacheTTLFromEnv lee TEMPLATE_CACHE_TTL y TEMPLATE_CACHE_NEGATIVE_TTL (segundos, por defecto 300 y 60). Un TTL de 0
+// desactiva la caché correspondiente.
+func CacheTTLFromEnv() (ttl, negativeTTL time.Duration, err error) {
+	if ttl, err = secondsFromEnv("TEMPLATE_CACHE_TTL", defaultCacheTTL); err != nil {
+		return 0, 0, err
+	}
+	if negativeTTL, err = secondsFromEnv("TEMPLATE_CACHE_NEGATIVE_TTL", defaultCacheNegativeTTL); err != nil {
+		return 0, 0, err
+	}
+	return ttl, negativeTTL, nil
+}
+
+func secondsFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("error: %s debe ser un número de segundos mayor o igual a 0: %q", name, raw)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// CheckPlantillaExists devuelve la plantilla en el idioma predeterminado, desde la caché si está vigente.
+func (c *CachedPlantillaRepository) CheckPlantillaExists(idPlantilla string) (bool, *models.Plantilla, error) {
+	return c.lookup(cacheKey{idPlantilla: idPlantilla}, func() (bool, *models.Plantilla, error) {
+		return c.source.CheckPlantillaExists(idPlantilla)
+	})
+}
+
+// FindPlantillaIdioma devuelve la plantilla en el idioma más cercano al solicitado, desde la caché si está vigente.
+func (c *CachedPlantillaRepository) FindPlantillaIdioma(
+	idPlantilla, idioma string) (bool, *models.Plantilla, error) {
+	key := cacheKey{idPlantilla: idPlantilla, idioma: models.NormalizeIdioma(idioma)}
+	return c.lookup(key, func() (bool, *models.Plantilla, error) {
+		return c.source.FindPlantillaIdioma(idPlantilla, idioma)
+	})
+}
+
+// Invalidate descarta de la caché la plantilla indicada en todos sus idiomas. Un ID vacío descarta todas las
+// plantillas.
+func (c *CachedPlantillaRepository) Invalidate(idPlantilla string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.entries {
+		if idPlantilla == "" || key.idPlantilla == idPlantilla {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (c *CachedPlantillaRepository) lookup(
+	key cacheKey, load func() (bool, *models.Plantilla, error)) (bool, *models.Plantilla, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		if entry.plantilla == nil {
+			return false, nil, nil
+		}
+		return true, copyPlantilla(entry.plantilla), nil
+	}
+
+	exists, plantilla, err := load()
+	if err != nil {
+		return exists, plantilla, err
+	}
+
+	ttl := c.ttl
+	if !exists || plantilla == nil {
+		ttl, plantilla = c.negativeTTL, nil
+	}
+	c.mu.Lock()
+	if ttl > 0 {
+		c.entries[key] = cacheEntry{plantilla: copyPlantilla(plantilla), expires: now.Add(ttl)}
+	} else {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if plantilla == nil {
+		return false, nil, nil
+	}
+	return true, plantilla, nil
+}
+
+func copyPlantilla(plantilla *models.Plantilla) *models.Plantilla {
+	if plantilla == nil {
+		return nil
+	}
+	copied := *plantilla
+	return &copied
+}
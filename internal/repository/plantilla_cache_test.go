@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"errors"
+	"gmf_message_processor/internal/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// countingSource cuenta las consultas que llegan al repositorio decorado.
+type countingSource struct {
+	plantillas map[string]models.Plantilla
+	calls      int
+	err        error
+}
+
+func (s *countingSource) CheckPlantillaExists(idPlantilla string) (bool, *models.Plantilla, error) {
+	return s.FindPlantillaIdioma(idPlantilla, "")
+}
+
+func (s *countingSource) FindPlantillaIdioma(idPlantilla, _ string) (bool, *models.Plantilla, error) {
+	s.calls++
+	if s.err != nil {
+		return false, nil, s.err
+	}
+	plantilla, ok := s.plantillas[idPlantilla]
+	if !ok {
+		return false, nil, nil
+	}
+	return true, &plantilla, nil
+}
+
+func newTestCache(source PlantillaSource, now *time.Time) *CachedPlantillaRepository {
+	cache := NewCachedPlantillaRepository(source, time.Minute, 10*time.Second)
+	cache.now = func() time.Time { return *now }
+	return cache
+}
+
+func TestCachedPlantillaRepositoryHitsAndExpires(t *testing.T) {
+	source := &countingSource{plantillas: map[string]models.Plantilla{"PC001": {IDPlantilla: "PC001", Cuerpo: "v1"}}}
+	now := time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC)
+	cache := newTestCache(source, &now)
+
+	_, first, err := cache.CheckPlantillaExists("PC001")
+	if err != nil {
+		t.Fatalf("Error al consultar la plantilla: %v", err)
+	}
+	// El servicio modifica la plantilla al renderizarla; la copia en caché no debe cambiar
+	first.Cuerpo = "renderizado"
+
+	exists, second, err := cache.CheckPlantillaExists("PC001")
+	if err != nil || !exists || second.Cuerpo != "v1" {
+		t.Fatalf("Se esperaba la plantilla original desde la caché: %v, %+v, %v", exists, second, err)
+	}
+	if source.calls != 1 {
+		t.Fatalf("Se esperaba una sola consulta a la base de datos, se hicieron %d", source.calls)
+	}
+
+	source.plantillas["PC001"] = models.Plantilla{IDPlantilla: "PC001", Cuerpo: "v2"}
+	now = now.Add(time.Minute)
+	_, third, _ := cache.CheckPlantillaExists("PC001")
+	if third.Cuerpo != "v2" || source.calls != 2 {
+		t.Errorf("Se esperaba recargar la plantilla al vencer el TTL: %+v (%d consultas)", third, source.calls)
+	}
+}
+
+func TestCachedPlantillaRepositoryNegativeCaching(t *testing.T) {
+	source := &countingSource{plantillas: map[string]models.Plantilla{}}
+	now := time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC)
+	cache := newTestCache(source, &now)
+
+	for i := 0; i < 3; i++ {
+		if exists, _, err := cache.FindPlantillaIdioma("PC404", "en"); err != nil || exists {
+			t.Fatalf("La plantilla PC404 no debería existir: %v, %v", exists, err)
+		}
+	}
+	if source.calls != 1 {
+		t.Fatalf("Se esperaba una sola consulta para la plantilla inexistente, se hicieron %d", source.calls)
+	}
+
+	now = now.Add(10 * time.Second)
+	cache.FindPlantillaIdioma("PC404", "EN")
+	if source.calls != 2 {
+		t.Errorf("Se esperaba volver a consultar al vencer la caché negativa, se hicieron %d", source.calls)
+	}
+}
+
+func TestCachedPlantillaRepositoryDoesNotCacheErrors(t *testing.T) {
+	source := &countingSource{err: errors.New("db caída")}
+	now := time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC)
+	cache := newTestCache(source, &now)
+
+	cache.CheckPlantillaExists("PC001")
+	if _, _, err := cache.CheckPlantillaExists("PC001"); err == nil {
+		t.Fatalf("Se esperaba el error de la base de datos")
+	}
+	if source.calls != 2 {
+		t.Errorf("Los errores no deberían guardarse en la caché, se hicieron %d consultas", source.calls)
+	}
+}
+
+func TestCachedPlantillaRepositoryInvalidate(t *testing.T) {
+	source := &countingSource{plantillas: map[string]models.Plantilla{
+		"PC001": {IDPlantilla: "PC001"},
+		"PC002": {IDPlantilla: "PC002"},
+	}}
+	now := time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC)
+	cache := newTestCache(source, &now)
+
+	cache.CheckPlantillaExists("PC001")
+	cache.FindPlantillaIdioma("PC001", "en")
+	cache.CheckPlantillaExists("PC002")
+
+	if removed := cache.Invalidate("PC001"); removed != 2 {
+		t.Fatalf("Se esperaba descartar los 2 idiomas de PC001, se descartaron %d", removed)
+	}
+	cache.CheckPlantillaExists("PC002")
+	cache.CheckPlantillaExists("PC001")
+	if source.calls != 4 {
+		t.Fatalf("Solo PC001 debería consultarse de nuevo, se hicieron %d consultas", source.calls)
+	}
+
+	if removed := cache.Invalidate(""); removed != 2 {
+		t.Errorf("Se esperaba vaciar la caché, se descartaron %d entradas", removed)
+	}
+}
+
+func TestCacheTTLFromEnv(t *testing.T) {
+	ttl, negativeTTL, err := CacheTTLFromEnv()
+	if err != nil || ttl != 5*time.Minute || negativeTTL != time.Minute {
+		t.Fatalf("Valores por defecto inesperados: %v, %v, %v", ttl, negativeTTL, err)
+	}
+
+	os.Setenv("TEMPLATE_CACHE_TTL", "0")
+	os.Setenv("TEMPLATE_CACHE_NEGATIVE_TTL", "15")
+	defer os.Unsetenv("TEMPLATE_CACHE_TTL")
+	defer os.Unsetenv("TEMPLATE_CACHE_NEGATIVE_TTL")
+	ttl, negativeTTL, err = CacheTTLFromEnv()
+	if err != nil || ttl != 0 || negativeTTL != 15*time.Second {
+		t.Fatalf("Valores inesperados: %v, %v, %v", ttl, negativeTTL, err)
+	}
+
+	os.Setenv("TEMPLATE_CACHE_TTL", "cinco")
+	if _, _, err := CacheTTLFromEnv(); err == nil {
+		t.Errorf("Se esperaba un error con un TTL inválido")
+	}
+}
@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"errors"
 	"gmf_message_processor/internal/models"
 	"gorm.io/gorm"
 )
@@ -21,16 +20,28 @@ func NewPlantillaRepository(db DBInterface) *GormPlantillaRepository {
 	return &GormPlantillaRepository{DB: db}
 }
 
-// CheckPlantillaExists verifica si una plantilla existe en la base de datos y la devuelve.
+// CheckPlantillaExists verifica si una plantilla existe en la base de datos en el idioma predeterminado y la devuelve.
 func (repo *GormPlantillaRepository) CheckPlantillaExists(idPlantilla string) (bool, *models.Plantilla, error) {
-	var plantilla models.Plantilla
+	return repo.FindPlantillaIdioma(idPlantilla, "")
+}
 
-	if err := repo.DB.Where("id_plantilla = ?", idPlantilla).First(&plantilla).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, nil, nil
-		}
+// FindPlantillaIdioma devuelve la plantilla en el idioma más cercano al solicitado según models.IdiomaChain (por
+// ejemplo, en-US → en → es-CO). Un idioma vacío busca solo el predeterminado.
+func (repo *GormPlantillaRepository) FindPlantillaIdioma(
+	idPlantilla, idioma string) (bool, *models.Plantilla, error) {
+	chain := models.IdiomaChain(idioma)
+
+	var plantillas []models.Plantilla
+	if err := repo.DB.Where("id_plantilla = ? AND idioma IN ?", idPlantilla, chain).Find(&plantillas).Error; err != nil {
 		return false, nil, err
 	}
 
-	return true, &plantilla, nil
+	for _, candidate := range chain {
+		for i := range plantillas {
+			if plantillas[i].Idioma == candidate {
+				return true, &plantillas[i], nil
+			}
+		}
+	}
+	return false, nil, nil
 }
@@ -125,3 +125,56 @@ func TestCheckPlantillaExistsDatabaseConnectionError(t *testing.T) {
 		t.Fatalf("Se esperaba un error de conexión a la base de datos, pero no se produjo ninguno")
 	}
 }
+
+func TestFindPlantillaIdiomaFallsBack(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+
+	if err := db.AutoMigrate(&models.Plantilla{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+	for _, plantilla := range []models.Plantilla{
+		{IDPlantilla: plantillaID, Idioma: "es-CO", Asunto: "Asunto"},
+		{IDPlantilla: plantillaID, Idioma: "en", Asunto: "Subject"},
+		{IDPlantilla: plantillaID, Idioma: "en-GB", Asunto: "Subject (GB)"},
+	} {
+		if err := db.Create(&plantilla).Error; err != nil {
+			t.Fatalf("Error al insertar plantilla de prueba: %v", err)
+		}
+	}
+
+	repo := NewPlantillaRepository(db)
+
+	tests := []struct {
+		idioma string
+		want   string
+	}{
+		{idioma: "en-GB", want: "en-GB"},
+		{idioma: "en-us", want: "en"},
+		{idioma: "fr", want: "es-CO"},
+		{idioma: "", want: "es-CO"},
+	}
+	for _, tt := range tests {
+		existe, plantilla, err := repo.FindPlantillaIdioma(plantillaID, tt.idioma)
+		if err != nil {
+			t.Fatalf("Error al buscar la plantilla en %q: %v", tt.idioma, err)
+		}
+		if !existe || plantilla.Idioma != tt.want {
+			t.Errorf("FindPlantillaIdioma(%q) = %v, %+v; se esperaba el idioma %s", tt.idioma, existe, plantilla, tt.want)
+		}
+	}
+
+	existe, _, err := repo.FindPlantillaIdioma("plantilla-2", "en")
+	if err != nil || existe {
+		t.Fatalf("La plantilla plantilla-2 no debería existir: %v, %v", existe, err)
+	}
+}
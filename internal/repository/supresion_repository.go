@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SupresionDBInterface define las operaciones de la base de datos que necesita la lista de supresión.
+type SupresionDBInterface interface {
+	Where(query interface{}, args ...interface{}) *gorm.DB
+	Order(value interface{}) *gorm.DB
+	Clauses(conds ...clause.Expression) *gorm.DB
+}
+
+// GormSupresionRepository administra la lista de supresión utilizando GORM.
+type GormSupresionRepository struct {
+	DB  SupresionDBInterface
+	now func() time.Time
+}
+
+func NewSupresionRepository(db SupresionDBInterface) *GormSupresionRepository {
+	return &GormSupresionRepository{DB: db, now: time.Now}
+}
+
+// FindSuppressed devuelve las supresiones vigentes para las direcciones indicadas.
+func (repo *GormSupresionRepository) FindSuppressed(addresses []string) ([]models.Supresion, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		normalized = append(normalized, normalizeDireccion(address))
+	}
+
+	var supresiones []models.Supresion
+	err := repo.DB.
+		Where("direccion IN ?", normalized).
+		Where("expira_en IS NULL OR expira_en > ?", repo.now()).
+		Find(&supresiones).Error
+	return supresiones, err
+}
+
+// ListSupresiones devuelve todas las supresiones, incluidas las expiradas.
+func (repo *GormSupresionRepository) ListSupresiones() ([]models.Supresion, error) {
+	var supresiones []models.Supresion
+	err := repo.DB.Order("direccion").Find(&supresiones).Error
+	return supresiones, err
+}
+
+// AddSupresion crea la supresión de una dirección o, si ya existe, actualiza su motivo, origen y expiración.
+func (repo *GormSupresionRepository) AddSupresion(supresion *models.Supresion) error {
+	supresion.Direccion = normalizeDireccion(supresion.Direccion)
+	return repo.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "direccion"}},
+		DoUpdates: clause.AssignmentColumns([]string{"motivo", "origen", "expira_en", "updated_at"}),
+	}).Create(supresion).Error
+}
+
+// RemoveSupresion elimina la supresión de una dirección. Devuelve false si la dirección no estaba suprimida.
+func (repo *GormSupresionRepository) RemoveSupresion(address string) (bool, error) {
+	result := repo.DB.Where("direccion = ?", normalizeDireccion(address)).Delete(&models.Supresion{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// normalizeDireccion compara las direcciones sin distinguir mayúsculas.
+func normalizeDireccion(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newSupresionTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+
+	if err := db.AutoMigrate(&models.Supresion{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+	return db
+}
+
+func TestSupresionRepositoryFindSuppressed(t *testing.T) {
+	db := newSupresionTestDB(t)
+	repo := NewSupresionRepository(db)
+	now := time.Date(2024, 10, 7, 9, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	vencida := now.Add(-time.Hour)
+	vigente := now.Add(time.Hour)
+	for _, supresion := range []*models.Supresion{
+		{Direccion: "Rebote@Test.com", Motivo: models.MotivoSupresionRebote, Origen: "ses"},
+		{Direccion: "vencida@test.com", Motivo: models.MotivoSupresionManual, Origen: "cli", ExpiraEn: &vencida},
+		{Direccion: "temporal@test.com", Motivo: models.MotivoSupresionQueja, Origen: "ses", ExpiraEn: &vigente},
+	} {
+		if err := repo.AddSupresion(supresion); err != nil {
+			t.Fatalf("Error al insertar la supresión de prueba: %v", err)
+		}
+	}
+
+	supresiones, err := repo.FindSuppressed(
+		[]string{"REBOTE@test.com", "vencida@test.com", "temporal@test.com", "libre@test.com"})
+	if err != nil {
+		t.Fatalf("Error al consultar las supresiones: %v", err)
+	}
+	if len(supresiones) != 2 {
+		t.Fatalf("Se esperaban 2 supresiones vigentes, se obtuvieron %d: %+v", len(supresiones), supresiones)
+	}
+
+	todas, err := repo.ListSupresiones()
+	if err != nil {
+		t.Fatalf("Error al listar las supresiones: %v", err)
+	}
+	if len(todas) != 3 || todas[0].Direccion != "rebote@test.com" {
+		t.Fatalf("Listado de supresiones incorrecto: %+v", todas)
+	}
+}
+
+func TestSupresionRepositoryRemoveSupresion(t *testing.T) {
+	db := newSupresionTestDB(t)
+	repo := NewSupresionRepository(db)
+
+	if err := repo.AddSupresion(&models.Supresion{
+		Direccion: "a@test.com", Motivo: models.MotivoSupresionManual, Origen: "cli"}); err != nil {
+		t.Fatalf("Error al insertar la supresión de prueba: %v", err)
+	}
+
+	// Volver a suprimir la dirección actualiza el motivo sin duplicarla
+	if err := repo.AddSupresion(&models.Supresion{
+		Direccion: "A@test.com", Motivo: models.MotivoSupresionQueja, Origen: "ses"}); err != nil {
+		t.Fatalf("Error al actualizar la supresión de prueba: %v", err)
+	}
+	todas, err := repo.ListSupresiones()
+	if err != nil || len(todas) != 1 || todas[0].Motivo != models.MotivoSupresionQueja {
+		t.Fatalf("Se esperaba una única supresión actualizada: %+v, err=%v", todas, err)
+	}
+
+	removed, err := repo.RemoveSupresion("A@test.com")
+	if err != nil || !removed {
+		t.Fatalf("Se esperaba eliminar la supresión, removed=%v err=%v", removed, err)
+	}
+
+	removed, err = repo.RemoveSupresion("a@test.com")
+	if err != nil || removed {
+		t.Fatalf("La supresión ya no debería existir, removed=%v err=%v", removed, err)
+	}
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// VersionDBInterface define las operaciones de la base de datos que necesita el historial de versiones.
+type VersionDBInterface interface {
+	Where(query interface{}, args ...interface{}) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
+}
+
+// GormVersionRepository administra el historial de versiones de las plantillas utilizando GORM. Las versiones se
+// llevan por plantilla e idioma; un idioma vacío equivale al predeterminado.
+type GormVersionRepository struct {
+	DB VersionDBInterface
+}
+
+func NewVersionRepository(db VersionDBInterface) *GormVersionRepository {
+	return &GormVersionRepository{DB: db}
+}
+
+// FindVersion devuelve la versión indicada de la plantilla, o nil si no existe.
+func (repo *GormVersionRepository) FindVersion(
+	idPlantilla, idioma string, version int) (*models.PlantillaVersion, error) {
+	var plantillaVersion models.PlantillaVersion
+
+	err := repo.DB.Where("id_plantilla = ? AND idioma = ? AND version = ?", idPlantilla, versionIdioma(idioma), version).
+		First(&plantillaVersion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &plantillaVersion, nil
+}
+
+// FindActiveVersion devuelve la versión activa de la plantilla, o nil si no tiene versiones registradas.
+func (repo *GormVersionRepository) FindActiveVersion(idPlantilla, idioma string) (*models.PlantillaVersion, error) {
+	var versiones []models.PlantillaVersion
+	err := repo.DB.Where("id_plantilla = ? AND idioma = ? AND activa = ?", idPlantilla, versionIdioma(idioma), true).
+		Limit(1).
+		Find(&versiones).Error
+	if err != nil || len(versiones) == 0 {
+		return nil, err
+	}
+	return &versiones[0], nil
+}
+
+// ListVersions devuelve las versiones de la plantilla, de la más antigua a la más reciente.
+func (repo *GormVersionRepository) ListVersions(idPlantilla, idioma string) ([]models.PlantillaVersion, error) {
+	var versiones []models.PlantillaVersion
+	err := repo.DB.Where("id_plantilla = ? AND idioma = ?", idPlantilla, versionIdioma(idioma)).
+		Order("version").
+		Find(&versiones).Error
+	return versiones, err
+}
+
+// SnapshotPlantilla registra el contenido actual de la plantilla como una nueva versión y la marca como activa.
+func (repo *GormVersionRepository) SnapshotPlantilla(idPlantilla, idioma string) (*models.PlantillaVersion, error) {
+	idioma = versionIdioma(idioma)
+	var snapshot *models.PlantillaVersion
+
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var plantilla models.Plantilla
+		if err := tx.Where("id_plantilla = ? AND idioma = ?", idPlantilla, idioma).First(&plantilla).Error; err != nil {
+			return plantillaNotFound(idPlantilla, idioma, err)
+		}
+
+		var latest int
+		err := tx.Model(&models.PlantillaVersion{}).
+			Where("id_plantilla = ? AND idioma = ?", idPlantilla, idioma).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+
+		snapshot = models.NewPlantillaVersion(&plantilla, latest+1)
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+		return activateVersion(tx, idPlantilla, idioma, snapshot.Version)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Activa = true
+	return snapshot, nil
+}
+
+// RollbackPlantilla restaura en cgd_correos_plantillas el contenido de una versión anterior y la marca como activa.
+func (repo *GormVersionRepository) RollbackPlantilla(idPlantilla, idioma string, version int) error {
+	idioma = versionIdioma(idioma)
+
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		var plantillaVersion models.PlantillaVersion
+		err := tx.Where("id_plantilla = ? AND idioma = ? AND version = ?", idPlantilla, idioma, version).
+			First(&plantillaVersion).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("la versión %d de la plantilla %s (%s) no existe", version, idPlantilla, idioma)
+			}
+			return err
+		}
+
+		restored := plantillaVersion.Plantilla()
+		result := tx.Model(&models.Plantilla{}).
+			Where("id_plantilla = ? AND idioma = ?", idPlantilla, idioma).
+			Select("asunto", "cuerpo", "remitente", "destinatario", "adjunto", "motor", "renderizar_direcciones",
+				"modo_parametros", "valor_defecto", "firmar_smime", "cifrar_smime", "layout", "version").
+			Updates(restored)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return plantillaNotFound(idPlantilla, idioma, gorm.ErrRecordNotFound)
+		}
+		return activateVersion(tx, idPlantilla, idioma, version)
+	})
+}
+
+// activateVersion deja activa únicamente la versión indicada y la registra en la plantilla.
+func activateVersion(tx *gorm.DB, idPlantilla, idioma string, version int) error {
+	// Se desactivan primero todas las versiones para no violar el índice único parcial de la versión activa
+	err := tx.Model(&models.PlantillaVersion{}).
+		Where("id_plantilla = ? AND idioma = ?", idPlantilla, idioma).
+		Update("activa", false).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.PlantillaVersion{}).
+		Where("id_plantilla = ? AND idioma = ? AND version = ?", idPlantilla, idioma, version).
+		Update("activa", true).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Plantilla{}).
+		Where("id_plantilla = ? AND idioma = ?", idPlantilla, idioma).
+		Update("version", version).Error
+}
+
+// versionIdioma normaliza el idioma de la consulta; uno vacío equivale al predeterminado.
+func versionIdioma(idioma string) string {
+	if idioma = models.NormalizeIdioma(idioma); idioma == "" {
+		return models.DefaultIdioma()
+	}
+	return idioma
+}
+
+func plantillaNotFound(idPlantilla, idioma string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("la plantilla %s (%s) no existe", idPlantilla, idioma)
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestVersionRepositorySnapshotAndRollback(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(&models.Plantilla{}, &models.PlantillaVersion{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	plantilla := models.Plantilla{IDPlantilla: "PC001", Asunto: "Asunto v1", Cuerpo: "Cuerpo v1", Remitente: "a@test.com"}
+	if err := db.Create(&plantilla).Error; err != nil {
+		t.Fatalf("Error al insertar plantilla de prueba: %v", err)
+	}
+
+	repo := NewVersionRepository(db)
+
+	first, err := repo.SnapshotPlantilla("PC001", "")
+	if err != nil {
+		t.Fatalf("Error al registrar la versión: %v", err)
+	}
+	if first.Version != 1 || !first.Activa || first.Cuerpo != "Cuerpo v1" {
+		t.Fatalf("Versión inesperada: %+v", first)
+	}
+
+	// Editar la plantilla y registrar la segunda versión
+	cambios := map[string]interface{}{"asunto": "Asunto v2", "cuerpo": "Cuerpo v2", "firmar_smime": true}
+	if err := db.Model(&models.Plantilla{}).Where("id_plantilla = ?", "PC001").Updates(cambios).Error; err != nil {
+		t.Fatalf("Error al actualizar la plantilla: %v", err)
+	}
+	second, err := repo.SnapshotPlantilla("PC001", "")
+	if err != nil {
+		t.Fatalf("Error al registrar la versión: %v", err)
+	}
+	if second.Version != 2 || !second.FirmarSMIME {
+		t.Fatalf("Versión inesperada: %+v", second)
+	}
+
+	if err := repo.RollbackPlantilla("PC001", "es-co", 1); err != nil {
+		t.Fatalf("Error al restaurar la versión: %v", err)
+	}
+
+	var restored models.Plantilla
+	if err := db.Where("id_plantilla = ?", "PC001").First(&restored).Error; err != nil {
+		t.Fatalf("Error al consultar la plantilla: %v", err)
+	}
+	if restored.Asunto != "Asunto v1" || restored.Cuerpo != "Cuerpo v1" || restored.FirmarSMIME || restored.Version != 1 {
+		t.Errorf("La plantilla no se restauró a la versión 1: %+v", restored)
+	}
+
+	versiones, err := repo.ListVersions("PC001", "")
+	if err != nil {
+		t.Fatalf("Error al listar las versiones: %v", err)
+	}
+	if len(versiones) != 2 || !versiones[0].Activa || versiones[1].Activa {
+		t.Errorf("Solo la versión 1 debería estar activa: %+v", versiones)
+	}
+
+	pinned, err := repo.FindVersion("PC001", "", 2)
+	if err != nil {
+		t.Fatalf("Error al consultar la versión: %v", err)
+	}
+	if pinned == nil || pinned.Asunto != "Asunto v2" {
+		t.Errorf("Se esperaba la versión 2, se obtuvo %+v", pinned)
+	}
+
+	missing, err := repo.FindVersion("PC001", "", 9)
+	if err != nil || missing != nil {
+		t.Errorf("Se esperaba nil para una versión inexistente, se obtuvo %+v, %v", missing, err)
+	}
+	if err := repo.RollbackPlantilla("PC001", "es-co", 9); err == nil {
+		t.Errorf("Se esperaba un error al restaurar una versión inexistente")
+	}
+	if _, err := repo.SnapshotPlantilla("PC009", ""); err == nil {
+		t.Errorf("Se esperaba un error al versionar una plantilla inexistente")
+	}
+}
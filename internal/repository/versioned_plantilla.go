@@ -0,0 +1,52 @@
+package repository
+
+import "gmf_message_processor/internal/models"
+
+// ActiveVersionSource consulta la versión activa de una plantilla en el historial.
+type ActiveVersionSource interface {
+	FindActiveVersion(idPlantilla, idioma string) (*models.PlantillaVersion, error)
+}
+
+// VersionedPlantillaRepository sirve las plantillas desde su versión activa en cgd_correos_plantillas_versiones, de
+// modo que una edición directa de cgd_correos_plantillas no se envía hasta registrarla con templates snapshot. Si la
+// plantilla en vivo difiere de la versión activa se marca con CambiosSinVersionar. Las plantillas sin versiones
+// registradas se sirven desde cgd_correos_plantillas.
+type VersionedPlantillaRepository struct {
+	source   PlantillaSource
+	versions ActiveVersionSource
+}
+
+func NewVersionedPlantillaRepository(
+	source PlantillaSource, versions ActiveVersionSource) *VersionedPlantillaRepository {
+	return &VersionedPlantillaRepository{source: source, versions: versions}
+}
+
+// CheckPlantillaExists devuelve la versión activa de la plantilla en el idioma predeterminado.
+func (repo *VersionedPlantillaRepository) CheckPlantillaExists(idPlantilla string) (bool, *models.Plantilla, error) {
+	return repo.activeVersion(repo.source.CheckPlantillaExists(idPlantilla))
+}
+
+// FindPlantillaIdioma devuelve la versión activa de la plantilla en el idioma más cercano al solicitado.
+func (repo *VersionedPlantillaRepository) FindPlantillaIdioma(
+	idPlantilla, idioma string) (bool, *models.Plantilla, error) {
+	return repo.activeVersion(repo.source.FindPlantillaIdioma(idPlantilla, idioma))
+}
+
+func (repo *VersionedPlantillaRepository) activeVersion(
+	exists bool, plantilla *models.Plantilla, err error) (bool, *models.Plantilla, error) {
+	if err != nil || !exists || plantilla == nil {
+		return exists, plantilla, err
+	}
+
+	version, err := repo.versions.FindActiveVersion(plantilla.IDPlantilla, plantilla.Idioma)
+	if err != nil {
+		return false, nil, err
+	}
+	if version == nil {
+		return true, plantilla, nil
+	}
+
+	served := version.Plantilla()
+	served.CambiosSinVersionar = !version.Matches(plantilla)
+	return true, served, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestVersionedPlantillaRepositoryServesActiveVersion(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(&models.Plantilla{}, &models.PlantillaVersion{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	plantilla := models.Plantilla{IDPlantilla: "PC001", Asunto: "Asunto v1", Cuerpo: "Cuerpo v1", Remitente: "a@test.com"}
+	if err := db.Create(&plantilla).Error; err != nil {
+		t.Fatalf("Error al insertar plantilla de prueba: %v", err)
+	}
+
+	versions := NewVersionRepository(db)
+	repo := NewVersionedPlantillaRepository(NewPlantillaRepository(db), versions)
+
+	// Sin versiones registradas se sirve la plantilla en vivo
+	_, served, err := repo.CheckPlantillaExists("PC001")
+	if err != nil {
+		t.Fatalf("Error al consultar la plantilla: %v", err)
+	}
+	if served.Cuerpo != "Cuerpo v1" || served.Version != 0 || served.CambiosSinVersionar {
+		t.Errorf("Se esperaba la plantilla en vivo, se obtuvo %+v", served)
+	}
+
+	if _, err := versions.SnapshotPlantilla("PC001", ""); err != nil {
+		t.Fatalf("Error al registrar la versión: %v", err)
+	}
+	_, served, err = repo.FindPlantillaIdioma("PC001", "es-CO")
+	if err != nil {
+		t.Fatalf("Error al consultar la plantilla: %v", err)
+	}
+	if served.Cuerpo != "Cuerpo v1" || served.Version != 1 || served.CambiosSinVersionar {
+		t.Errorf("Se esperaba la versión 1 sin cambios, se obtuvo %+v", served)
+	}
+
+	// Una edición directa no se envía hasta registrarla como versión
+	err = db.Model(&models.Plantilla{}).Where("id_plantilla = ?", "PC001").Update("cuerpo", "Borrador").Error
+	if err != nil {
+		t.Fatalf("Error al actualizar la plantilla: %v", err)
+	}
+	_, served, err = repo.CheckPlantillaExists("PC001")
+	if err != nil {
+		t.Fatalf("Error al consultar la plantilla: %v", err)
+	}
+	if served.Cuerpo != "Cuerpo v1" || served.Version != 1 || !served.CambiosSinVersionar {
+		t.Errorf("Se esperaba la versión 1 marcada con cambios sin versionar, se obtuvo %+v", served)
+	}
+
+	exists, _, err := repo.CheckPlantillaExists("PC999")
+	if err != nil || exists {
+		t.Errorf("La plantilla PC999 no debería existir (err: %v)", err)
+	}
+}
+
+func TestPlantillaVersionSingleActiveVersion(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(memoria), &gorm.Config{})
+	if err != nil {
+		t.Fatalf(mensajeErrorDatabaseConnection, err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf(mensajeErrorInstancia, err)
+		}
+		sqlDB.Close()
+	})
+	if err := db.AutoMigrate(&models.PlantillaVersion{}); err != nil {
+		t.Fatalf("Error al migrar tabla de prueba: %v", err)
+	}
+
+	versiones := []models.PlantillaVersion{
+		{IDPlantilla: "PC001", Idioma: "es-CO", Version: 1},
+		{IDPlantilla: "PC001", Idioma: "es-CO", Version: 2, Activa: true},
+		{IDPlantilla: "PC001", Idioma: "en", Version: 1, Activa: true},
+	}
+	if err := db.Create(&versiones).Error; err != nil {
+		t.Fatalf("Error al insertar versiones de prueba: %v", err)
+	}
+
+	// El índice único parcial impide una segunda versión activa en el mismo idioma
+	duplicada := models.PlantillaVersion{IDPlantilla: "PC001", Idioma: "es-CO", Version: 3, Activa: true}
+	if err := db.Create(&duplicada).Error; err == nil {
+		t.Errorf("Se esperaba un error al registrar una segunda versión activa")
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"gmf_message_processor/internal/render"
+)
+
+// TemplateCache define la invalidación de la caché de plantillas del repositorio.
+type TemplateCache interface {
+	Invalidate(idPlantilla string) int
+}
+
+// WithTemplateCache permite invalidar la caché de plantillas con el mensaje de control invalidar_cache.
+func WithTemplateCache(cache TemplateCache) PlantillaServiceOption {
+	return func(s *PlantillaService) {
+		s.cache = cache
+	}
+}
+
+// handleControl procesa un mensaje de control. Un control desconocido es un error permanente.
+func (s *PlantillaService) handleControl(msg *models.SQSMessage, messageID string) error {
+	if msg.Control != models.ControlInvalidarCache {
+		return models.NewPermanentError(fmt.Errorf("error: mensaje de control %q no soportado", msg.Control))
+	}
+	if s.cache == nil {
+		logs.LogWarn("Se recibió invalidar_cache y la caché de plantillas no está habilitada", messageID)
+		return nil
+	}
+
+	removed := s.cache.Invalidate(msg.IDPlantilla)
+	if msg.IDPlantilla == "" {
+		render.ResetCache()
+		logs.LogInfo(fmt.Sprintf("Caché de plantillas vaciada (%d entradas)", removed), messageID)
+		return nil
+	}
+	logs.LogInfo(fmt.Sprintf("Plantilla %s descartada de la caché (%d entradas)", msg.IDPlantilla, removed), messageID)
+	return nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTemplateCache struct {
+	mock.Mock
+}
+
+func (m *MockTemplateCache) Invalidate(idPlantilla string) int {
+	return m.Called(idPlantilla).Int(0)
+}
+
+func TestHandlePlantillaInvalidatesCache(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	cache := new(MockTemplateCache)
+	cache.On("Invalidate", "PC001").Return(2)
+	cache.On("Invalidate", "").Return(5)
+
+	service := NewPlantillaService(repo, emailService, WithTemplateCache(cache))
+
+	err := service.HandlePlantilla(context.TODO(),
+		&models.SQSMessage{IDPlantilla: "PC001", Control: models.ControlInvalidarCache}, "messageID")
+	assert.NoError(t, err)
+
+	err = service.HandlePlantilla(context.TODO(), &models.SQSMessage{Control: models.ControlInvalidarCache}, "messageID")
+	assert.NoError(t, err)
+
+	cache.AssertExpectations(t)
+	repo.AssertNotCalled(t, "CheckPlantillaExists", mock.Anything)
+	emailService.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything)
+}
+
+func TestHandlePlantillaControlMessages(t *testing.T) {
+	service := NewPlantillaService(new(MockPlantillaRepository), new(MockEmailService))
+
+	// Sin caché configurada el mensaje de invalidación se ignora
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{Control: models.ControlInvalidarCache}, "messageID")
+	assert.NoError(t, err)
+
+	err = service.HandlePlantilla(context.TODO(), &models.SQSMessage{Control: "reiniciar"}, "messageID")
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), `mensaje de control "reiniciar" no soportado`)
+}
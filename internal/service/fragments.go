@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"gmf_message_processor/internal/render"
+)
+
+// Fragments define la consulta de los layouts y parciales compartidos por las plantillas.
+type Fragments interface {
+	FindFragmento(nombre, idioma string) (*models.Fragmento, error)
+}
+
+// WithFragments permite que las plantillas usen un layout e incluyan parciales con {{> nombre}}.
+func WithFragments(fragments Fragments) PlantillaServiceOption {
+	return func(s *PlantillaService) {
+		s.fragments = fragments
+	}
+}
+
+// assembleCuerpo arma el cuerpo de la plantilla con su layout y sus parciales, en el idioma de la plantilla. Las
+// plantillas sin layout ni inclusiones no consultan los fragmentos.
+func (s *PlantillaService) assembleCuerpo(plantilla *models.Plantilla, messageID string) error {
+	if plantilla.Layout == "" && len(render.Includes(plantilla.Cuerpo)) == 0 {
+		return nil
+	}
+	if s.fragments == nil {
+		return models.NewPermanentError(fmt.Errorf("error: la plantilla %s usa layouts o parciales y los fragmentos "+
+			"no están configurados", plantilla.IDPlantilla))
+	}
+
+	cuerpo, err := render.Assemble(plantilla.IDPlantilla, plantilla.Layout, plantilla.Cuerpo,
+		func(nombre string) (*models.Fragmento, error) {
+			return s.fragments.FindFragmento(nombre, plantilla.Idioma)
+		})
+	if err != nil {
+		logs.LogError(fmt.Sprintf("Error al armar la plantilla %s con sus fragmentos", plantilla.IDPlantilla),
+			err, messageID)
+		return err
+	}
+	plantilla.Cuerpo = cuerpo
+	return nil
+}
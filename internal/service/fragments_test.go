@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFragments struct {
+	mock.Mock
+}
+
+func (m *MockFragments) FindFragmento(nombre, idioma string) (*models.Fragmento, error) {
+	args := m.Called(nombre, idioma)
+	return args.Get(0).(*models.Fragmento), args.Error(1)
+}
+
+func TestHandlePlantillaAssemblesLayoutAndPartials(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	fragments := new(MockFragments)
+
+	repo.On("FindPlantillaIdioma", "PC010", "en").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC010",
+		Idioma:       "en",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       "<p>{{.nombre}}</p>",
+		Remitente:    remitente,
+		Destinatario: destinatario,
+		Motor:        "html",
+		Layout:       "base",
+	}, nil)
+	fragments.On("FindFragmento", "base", "en").Return(&models.Fragmento{
+		Nombre: "base", Idioma: "en", Tipo: models.TipoFragmentoLayout, Contenido: "<div>{{> cuerpo}}{{> pie}}</div>",
+	}, nil)
+	fragments.On("FindFragmento", "pie", "en").Return(&models.Fragmento{
+		Nombre: "pie", Idioma: "es-CO", Tipo: models.TipoFragmentoParcial, Contenido: "<small>{{.nombre}}</small>",
+	}, nil)
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, asuntoPrueba,
+		"<div><p>&lt;Ana&gt;</p><small>&lt;Ana&gt;</small></div>").Return(nil)
+
+	service := NewPlantillaService(repo, emailService, WithFragments(fragments))
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC010",
+		Idioma:      "en",
+		Parametro:   []models.ParametrosSQS{{Nombre: "nombre", Valor: "<Ana>"}},
+	}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaLayoutWithoutFragmentsIsPermanent(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	repo.On("CheckPlantillaExists", "PC010").Return(true, &models.Plantilla{
+		IDPlantilla: "PC010",
+		Cuerpo:      "Cuerpo",
+		Layout:      "base",
+	}, nil)
+
+	service := NewPlantillaService(repo, new(MockEmailService))
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC010"}, "messageID")
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "los fragmentos no están configurados")
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gmf_message_processor/internal/email"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"strings"
+)
+
+// limitRecipients restringe los destinatarios renderizados de la plantilla a los indicados en el mensaje. El
+// mensaje solo puede acotar la lista, nunca ampliarla: las direcciones que no pertenecen a la plantilla se
+// descartan. Si ninguna pertenece se devuelve un error permanente.
+func limitRecipients(destinatarios string, override []string, messageID string) (string, error) {
+	addresses, err := email.ParseAddressList(destinatarios)
+	if err != nil {
+		// Se deja la lista sin cambios para que el servicio de correo reporte el error
+		return destinatarios, nil
+	}
+
+	requested := make(map[string]bool, len(override))
+	for _, destinatario := range override {
+		requested[strings.ToLower(strings.TrimSpace(destinatario))] = true
+	}
+
+	remaining := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		key := strings.ToLower(address.Address)
+		if !requested[key] {
+			continue
+		}
+		if address.Name == "" {
+			remaining = append(remaining, address.Address)
+		} else {
+			remaining = append(remaining, address.String())
+		}
+		delete(requested, key)
+	}
+
+	for destinatario := range requested {
+		logs.LogWarn(
+			fmt.Sprintf("Destinatario %s descartado: no pertenece a los destinatarios de la plantilla", destinatario),
+			messageID,
+		)
+	}
+
+	if len(remaining) == 0 {
+		err := models.NewPermanentError(
+			errors.New("ninguno de los destinatarios del mensaje pertenece a los destinatarios de la plantilla"))
+		logs.LogError("Destinatarios del reintento inválidos", err, messageID)
+		return "", err
+	}
+	return strings.Join(remaining, ","), nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"gmf_message_processor/internal/render"
+)
+
+// ParameterSchema define la consulta de los parámetros declarados de una plantilla.
+type ParameterSchema interface {
+	FindParametros(idPlantilla string) ([]models.ParametroPlantilla, error)
+}
+
+// WithParameterSchema hace que el servicio valide los parámetros de cada mensaje contra el esquema declarado de su
+// plantilla antes de renderizarla.
+func WithParameterSchema(schema ParameterSchema) PlantillaServiceOption {
+	return func(s *PlantillaService) {
+		s.schema = schema
+	}
+}
+
+// validateParameters valida los parámetros contra el esquema de la plantilla y completa los valores por defecto.
+// Las plantillas sin parámetros declarados no se validan.
+func (s *PlantillaService) validateParameters(
+	idPlantilla string, params render.Params, messageID string) (render.Params, error) {
+	schema, err := s.schema.FindParametros(idPlantilla)
+	if err != nil {
+		logs.LogError(fmt.Sprintf("Error al consultar los parámetros declarados de la plantilla %s", idPlantilla),
+			err, messageID)
+		return nil, err
+	}
+	if len(schema) == 0 {
+		return params, nil
+	}
+
+	validated, err := render.Validate(idPlantilla, schema, params)
+	if err != nil {
+		logs.LogError("El mensaje no cumple el esquema de parámetros de la plantilla", err, messageID)
+		return nil, err
+	}
+	return validated, nil
+}
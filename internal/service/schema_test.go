@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockParameterSchema struct {
+	mock.Mock
+}
+
+func (m *MockParameterSchema) FindParametros(idPlantilla string) ([]models.ParametroPlantilla, error) {
+	args := m.Called(idPlantilla)
+	return args.Get(0).([]models.ParametroPlantilla), args.Error(1)
+}
+
+func plantillaConEsquema() *models.Plantilla {
+	return &models.Plantilla{
+		IDPlantilla:    "PC008",
+		Asunto:         asuntoPrueba,
+		Cuerpo:         "{{.nombre_archivo}} por {{.canal}}",
+		Remitente:      remitente,
+		Destinatario:   destinatario,
+		Motor:          "html",
+		ModoParametros: "fail",
+	}
+}
+
+func TestHandlePlantillaValidatesParameterSchema(t *testing.T) {
+	canal := "SFTP"
+	schema := []models.ParametroPlantilla{
+		{IDPlantilla: "PC008", Nombre: "nombre_archivo", Requerido: true, Patron: `[\w.-]+\.csv`},
+		{IDPlantilla: "PC008", Nombre: "canal", ValorDefecto: &canal},
+	}
+
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	parametros := new(MockParameterSchema)
+	repo.On("CheckPlantillaExists", "PC008").Return(true, plantillaConEsquema(), nil)
+	parametros.On("FindParametros", "PC008").Return(schema, nil)
+	// El valor por defecto del esquema resuelve el marcador, de modo que el modo fail no rechaza el mensaje
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, asuntoPrueba, "pagos.csv por SFTP").Return(nil)
+
+	service := NewPlantillaService(repo, emailService, WithParameterSchema(parametros))
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC008",
+		Parametro:   []models.ParametrosSQS{{Nombre: "nombre_archivo", Valor: "pagos.csv"}},
+	}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaRejectsParametersOutsideSchema(t *testing.T) {
+	schema := []models.ParametroPlantilla{
+		{IDPlantilla: "PC008", Nombre: "nombre_archivo", Requerido: true, Patron: `[\w.-]+\.csv`},
+	}
+
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	parametros := new(MockParameterSchema)
+	repo.On("CheckPlantillaExists", "PC008").Return(true, plantillaConEsquema(), nil)
+	parametros.On("FindParametros", "PC008").Return(schema, nil)
+
+	service := NewPlantillaService(repo, emailService, WithParameterSchema(parametros))
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC008",
+		Parametro:   []models.ParametrosSQS{{Nombre: "nombre_archivo", Valor: "pagos.xlsx"}},
+	}, "messageID")
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), `el parámetro nombre_archivo no cumple el patrón`)
+	emailService.AssertNotCalled(t, "SendEmail")
+}
+
+func TestHandlePlantillaSchemaLookupError(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	parametros := new(MockParameterSchema)
+	repo.On("CheckPlantillaExists", "PC008").Return(true, plantillaConEsquema(), nil)
+	parametros.On("FindParametros", "PC008").Return([]models.ParametroPlantilla(nil), errors.New("db caída"))
+
+	service := NewPlantillaService(repo, new(MockEmailService), WithParameterSchema(parametros))
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC008"}, "messageID")
+
+	assert.EqualError(t, err, "db caída")
+	assert.False(t, models.IsPermanentError(err))
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"gmf_message_processor/internal/email"
 	"gmf_message_processor/internal/logs"
 	"gmf_message_processor/internal/models"
-	"gmf_message_processor/internal/utils"
+	"gmf_message_processor/internal/render"
+	"strings"
 )
 
 type IPlantillaService interface {
@@ -16,6 +18,7 @@ type IPlantillaService interface {
 // EmailService define la interfaz para el servicio de correo electrónico.
 type EmailService interface {
 	SendEmail(
+		ctx context.Context,
 		remitente,
 		destinatarios,
 		asunto,
@@ -26,36 +29,97 @@ type EmailService interface {
 // PlantillaRepository define la interfaz para el repositorio de Plantilla.
 type PlantillaRepository interface {
 	CheckPlantillaExists(idPlantilla string) (bool, *models.Plantilla, error)
+	FindPlantillaIdioma(idPlantilla, idioma string) (bool, *models.Plantilla, error)
 }
 
 // PlantillaService define el servicio que maneja la lógica de negocio para Plantilla.
 type PlantillaService struct {
 	repo         PlantillaRepository
 	emailService EmailService
+	suppressions SuppressionList
+	recorder     SendRecorder
+	schema       ParameterSchema
+	versions     VersionHistory
+	fragments    Fragments
+	cache        TemplateCache
 }
 
+// PlantillaServiceOption configura dependencias opcionales de PlantillaService.
+type PlantillaServiceOption func(*PlantillaService)
+
 // NewPlantillaService crea una nueva instancia de PlantillaService.
-func NewPlantillaService(repo PlantillaRepository, emailService EmailService) *PlantillaService {
-	return &PlantillaService{
+func NewPlantillaService(
+	repo PlantillaRepository, emailService EmailService, opts ...PlantillaServiceOption) *PlantillaService {
+	service := &PlantillaService{
 		repo:         repo,
 		emailService: emailService,
 	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	return service
 }
 
 func (s *PlantillaService) HandlePlantilla(ctx context.Context, msg *models.SQSMessage, messageID string) error {
-	// Verificar si la plantilla existe en la base de datos
-	exists, plantilla, err := s.repo.CheckPlantillaExists(msg.IDPlantilla)
+	// Los mensajes de control no envían correos
+	if msg.Control != "" {
+		return s.handleControl(msg, messageID)
+	}
+
+	// Obtener la plantilla (la versión fijada por el mensaje o la activa)
+	plantilla, err := s.findPlantilla(msg, messageID)
 	if err != nil {
-		logs.LogError(
-			fmt.Sprintf("Error al verificar si la plantilla con ID %s existe en la base de datos", msg.IDPlantilla),
-			err,
-			messageID,
-		)
 		return err
 	}
-	if !exists {
-		logs.LogError(fmt.Sprintf("La plantilla con ID %s no existe en la base de datos", msg.IDPlantilla), nil, messageID)
-		return errors.New("la plantilla no existe en la base de datos")
+
+	// Armar el cuerpo con el layout y los parciales compartidos
+	if err := s.assembleCuerpo(plantilla, messageID); err != nil {
+		return err
+	}
+
+	// Las plantillas confidenciales se firman y/o cifran con S/MIME en el proveedor de correo
+	if plantilla.FirmarSMIME || plantilla.CifrarSMIME {
+		ctx = email.WithSMIME(ctx, email.SMIMEOptions{Sign: plantilla.FirmarSMIME, Encrypt: plantilla.CifrarSMIME})
+	}
+
+	// Validar los parámetros contra el esquema declarado de la plantilla
+	params := render.NewParams(msg.Parametro)
+	if s.schema != nil {
+		if params, err = s.validateParameters(plantilla.IDPlantilla, params, messageID); err != nil {
+			return err
+		}
+	}
+
+	// Renderizar la plantilla con los parámetros del mensaje (los destinatarios antes de filtrar las supresiones)
+	if err := renderPlantilla(plantilla, params, messageID); err != nil {
+		return err
+	}
+	logs.LogInfo(
+		fmt.Sprintf("Plantilla %s renderizada (%s)", plantilla.IDPlantilla, describeVersion(plantilla)),
+		messageID,
+	)
+
+	// En un reintento de entrega parcial solo se envía a los destinatarios pendientes, que deben ser de la plantilla
+	if len(msg.Destinatarios) > 0 {
+		destinatarios, err := limitRecipients(plantilla.Destinatario, msg.Destinatarios, messageID)
+		if err != nil {
+			return err
+		}
+		logs.LogInfo(fmt.Sprintf("Reintento limitado a los destinatarios diferidos: %s", destinatarios), messageID)
+		plantilla.Destinatario = destinatarios
+	}
+
+	// Descartar los destinatarios suprimidos (rebotes permanentes, quejas o bajas manuales)
+	if s.suppressions != nil {
+		destinatarios, allSuppressed, err := s.filterSuppressed(plantilla.Destinatario, messageID)
+		if err != nil {
+			return err
+		}
+		if allSuppressed {
+			s.recordSuppressed(plantilla.Remitente, plantilla.Destinatario, messageID)
+			return nil
+		}
+		plantilla.Destinatario = destinatarios
 	}
 
 	// Verificar que haya al menos un conjunto de parámetros en el array
@@ -65,14 +129,9 @@ func (s *PlantillaService) HandlePlantilla(ctx context.Context, msg *models.SQSM
 			messageID,
 		)
 
-		// Aquí puedes usar valores predeterminados o simplemente continuar sin los parámetros.
-		placeholders := map[string]string{}
-
-		// Reemplazar placeholders en el cuerpo de la plantilla
-		plantilla.Cuerpo = utils.ReplacePlaceholders(plantilla.Cuerpo, placeholders)
-
 		// Continuar con el envío de correo aunque no haya parámetros
 		err = s.emailService.SendEmail(
+			ctx,
 			plantilla.Remitente,
 			plantilla.Destinatario,
 			plantilla.Asunto,
@@ -87,17 +146,9 @@ func (s *PlantillaService) HandlePlantilla(ctx context.Context, msg *models.SQSM
 		return nil
 	}
 
-	// Iterar sobre todos los parámetros y mapear los valores
-	placeholders := map[string]string{}
-	for _, param := range msg.Parametro {
-		placeholders["&"+param.Nombre] = param.Valor
-	}
-
-	// Reemplazar los placeholders en el cuerpo de la plantilla
-	plantilla.Cuerpo = utils.ReplacePlaceholders(plantilla.Cuerpo, placeholders)
-
 	// Enviar el correo electrónico usando el servicio de correo
 	err = s.emailService.SendEmail(
+		ctx,
 		plantilla.Remitente,
 		plantilla.Destinatario,
 		plantilla.Asunto,
@@ -111,3 +162,184 @@ func (s *PlantillaService) HandlePlantilla(ctx context.Context, msg *models.SQSM
 
 	return nil
 }
+
+// findPlantilla devuelve la versión de la plantilla fijada por version_plantilla o, si el mensaje no fija ninguna,
+// la activa, en el idioma más cercano al solicitado.
+func (s *PlantillaService) findPlantilla(msg *models.SQSMessage, messageID string) (*models.Plantilla, error) {
+	if msg.VersionPlantilla > 0 {
+		return s.findPinnedVersion(msg.IDPlantilla, msg.Idioma, msg.VersionPlantilla, messageID)
+	}
+	return s.findActivePlantilla(msg.IDPlantilla, msg.Idioma, messageID)
+}
+
+// findActivePlantilla devuelve la versión activa de la plantilla. Sin idioma se consulta el predeterminado.
+func (s *PlantillaService) findActivePlantilla(idPlantilla, idioma, messageID string) (*models.Plantilla, error) {
+	plantilla, err := s.lookupPlantilla(idPlantilla, idioma, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if plantilla.CambiosSinVersionar {
+		logs.LogWarn(fmt.Sprintf("La plantilla %s (%s) tiene cambios sin registrar como versión; se envía la versión "+
+			"activa %d", plantilla.IDPlantilla, plantilla.Idioma, plantilla.Version), messageID)
+	}
+	return plantilla, nil
+}
+
+// lookupPlantilla consulta la plantilla en el idioma más cercano al solicitado y falla si no existe.
+func (s *PlantillaService) lookupPlantilla(idPlantilla, idioma, messageID string) (*models.Plantilla, error) {
+	var (
+		exists    bool
+		plantilla *models.Plantilla
+		err       error
+	)
+	if idioma == "" {
+		exists, plantilla, err = s.repo.CheckPlantillaExists(idPlantilla)
+	} else {
+		exists, plantilla, err = s.repo.FindPlantillaIdioma(idPlantilla, idioma)
+	}
+	if err != nil {
+		logs.LogError(
+			fmt.Sprintf("Error al verificar si la plantilla con ID %s existe en la base de datos", idPlantilla),
+			err,
+			messageID,
+		)
+		return nil, err
+	}
+	if !exists {
+		logs.LogError(fmt.Sprintf("La plantilla con ID %s no existe en la base de datos", idPlantilla), nil, messageID)
+		return nil, errors.New("la plantilla no existe en la base de datos")
+	}
+	return plantilla, nil
+}
+
+// subjectLineBreaks elimina los saltos de línea que un parámetro pueda introducir en el asunto.
+var subjectLineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// renderPlantilla renderiza el cuerpo y el asunto de la plantilla con el motor que ésta selecciona y, si
+// RenderizarDirecciones está activo, también el nombre visible del remitente y los destinatarios, que deben seguir
+// siendo direcciones válidas. Los errores de la plantilla son permanentes.
+func renderPlantilla(plantilla *models.Plantilla, params render.Params, messageID string) error {
+	engine, err := render.ForMotor(plantilla.Motor)
+	if err == nil {
+		params, err = checkParameters(engine, plantilla, params, messageID)
+	}
+	if err == nil {
+		err = renderFields(engine, plantilla, params)
+	}
+	if err != nil {
+		logs.LogError(fmt.Sprintf("Error al renderizar la plantilla con ID %s", plantilla.IDPlantilla), err, messageID)
+		return err
+	}
+	return nil
+}
+
+// checkParameters compara los parámetros del mensaje con los marcadores de la plantilla y aplica su ModoParametros:
+// fail rechaza el mensaje de forma permanente, warn solo registra una advertencia y default además completa los
+// marcadores sin resolver con ValorDefecto.
+func checkParameters(
+	engine render.Engine, plantilla *models.Plantilla, params render.Params, messageID string) (render.Params, error) {
+	mode, err := render.ParseMode(plantilla.ModoParametros)
+	if err != nil {
+		return nil, err
+	}
+	texts := []string{plantilla.Cuerpo, plantilla.Asunto}
+	if plantilla.RenderizarDirecciones {
+		texts = append(texts, plantilla.Remitente, plantilla.Destinatario)
+	}
+	report, err := render.Check(engine, plantilla.IDPlantilla, params, texts...)
+	if err != nil || report.Empty() {
+		return params, err
+	}
+
+	switch mode {
+	case render.ModeFail:
+		return nil, models.NewPermanentError(
+			fmt.Errorf("error: parámetros inválidos para la plantilla %s: %s", plantilla.IDPlantilla, report))
+	case render.ModeDefault:
+		completed := make(render.Params, len(params)+len(report.Unresolved))
+		for name, value := range params {
+			completed[name] = value
+		}
+		for _, name := range report.Unresolved {
+			completed[name] = plantilla.ValorDefecto
+		}
+		params = completed
+		logs.LogWarn(fmt.Sprintf("Plantilla %s: %s (se usa el valor por defecto %q)",
+			plantilla.IDPlantilla, report, plantilla.ValorDefecto), messageID)
+	default:
+		logs.LogWarn(fmt.Sprintf("Plantilla %s: %s", plantilla.IDPlantilla, report), messageID)
+	}
+	return params, nil
+}
+
+func renderFields(engine render.Engine, plantilla *models.Plantilla, params render.Params) error {
+	cuerpo, err := engine.Render(plantilla.IDPlantilla, plantilla.Cuerpo, params)
+	if err != nil {
+		return err
+	}
+	asunto, err := engine.RenderText(plantilla.IDPlantilla+"/asunto", plantilla.Asunto, params)
+	if err != nil {
+		return err
+	}
+	plantilla.Cuerpo = cuerpo
+	plantilla.Asunto = subjectLineBreaks.Replace(asunto)
+
+	if !plantilla.RenderizarDirecciones {
+		return nil
+	}
+	remitente, err := renderSender(engine, plantilla, params)
+	if err != nil {
+		return err
+	}
+	destinatario, err := engine.RenderText(plantilla.IDPlantilla+"/destinatario", plantilla.Destinatario, params)
+	if err != nil {
+		return err
+	}
+	if _, err := email.ParseAddressList(destinatario); err != nil {
+		return err
+	}
+	plantilla.Remitente = remitente
+	plantilla.Destinatario = destinatario
+	return nil
+}
+
+// senderNameEscaper escapa el nombre visible del remitente para escribirlo entre comillas.
+var senderNameEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// renderSender renderiza únicamente el nombre visible del remitente. La dirección se toma tal cual de la plantilla
+// y debe ser fija, de modo que un parámetro no pueda suplantar al remitente.
+func renderSender(engine render.Engine, plantilla *models.Plantilla, params render.Params) (string, error) {
+	name, address := splitSender(plantilla.Remitente)
+	if _, err := email.ParseSender(address); err != nil {
+		return "", err
+	}
+	if name == "" {
+		return address, nil
+	}
+
+	name, err := engine.RenderText(plantilla.IDPlantilla+"/remitente", name, params)
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimSpace(subjectLineBreaks.Replace(name))
+	if name == "" {
+		return address, nil
+	}
+	return fmt.Sprintf(`"%s" <%s>`, senderNameEscaper.Replace(name), address), nil
+}
+
+// splitSender separa el nombre visible (sin comillas) y la dirección de un remitente con la forma
+// "Nombre" <direccion>. Si no tiene nombre visible, todo el texto es la dirección.
+func splitSender(raw string) (string, string) {
+	raw = strings.TrimSpace(raw)
+	start := strings.LastIndex(raw, "<")
+	if start < 0 || !strings.HasSuffix(raw, ">") {
+		return "", raw
+	}
+
+	name := strings.TrimSpace(raw[:start])
+	if len(name) >= 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		name = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(name[1 : len(name)-1])
+	}
+	return name, strings.TrimSpace(raw[start+1 : len(raw)-1])
+}
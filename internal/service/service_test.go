@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"gmf_message_processor/internal/email"
 	"gmf_message_processor/internal/models"
 	"testing"
 
@@ -19,17 +20,23 @@ func (m *MockPlantillaRepository) CheckPlantillaExists(idPlantilla string) (bool
 	return args.Bool(0), args.Get(1).(*models.Plantilla), args.Error(2)
 }
 
+func (m *MockPlantillaRepository) FindPlantillaIdioma(idPlantilla, idioma string) (bool, *models.Plantilla, error) {
+	args := m.Called(idPlantilla, idioma)
+	return args.Bool(0), args.Get(1).(*models.Plantilla), args.Error(2)
+}
+
 type MockEmailService struct {
 	mock.Mock
 }
 
 func (m *MockEmailService) SendEmail(
+	ctx context.Context,
 	remitente,
 	destinatarios,
 	asunto,
 	cuerpo,
 	messageID string) error {
-	args := m.Called(remitente, destinatarios, asunto, cuerpo)
+	args := m.Called(ctx, remitente, destinatarios, asunto, cuerpo)
 	return args.Error(0)
 }
 
@@ -61,6 +68,7 @@ func TestHandlePlantillaInvalidParameters(t *testing.T) {
 	// Mock del servicio de email para que devuelva un error
 	emailService.On(
 		"SendEmail",
+		mock.Anything,
 		remitente,
 		destinatario,
 		asuntoPrueba,
@@ -123,6 +131,7 @@ func TestHandlePlantillaSuccess(t *testing.T) {
 	emailService := new(MockEmailService)
 	emailService.On(
 		"SendEmail",
+		mock.Anything,
 		remitente,
 		"dest@test.com",
 		asuntoPrueba,
@@ -161,6 +170,7 @@ func TestHandlePlantillaErrorSendingEmail(t *testing.T) {
 	emailService := new(MockEmailService)
 	emailService.On(
 		"SendEmail",
+		mock.Anything,
 		remitente,
 		destinatario2,
 		asuntoPrueba,
@@ -228,6 +238,7 @@ func TestHandlePlantillaWithPlaceholders(t *testing.T) {
 	// Simular que el envío de correo es exitoso, incluyendo el `messageID` como quinto argumento
 	emailService.On(
 		"SendEmail",
+		mock.Anything,
 		remitente,
 		destinatario,
 		asuntoPrueba,
@@ -273,6 +284,7 @@ func TestHandlePlantillaPanicOnErrorSendingEmail(t *testing.T) {
 	// Simular que el envío de correo falla
 	emailService.On(
 		"SendEmail",
+		mock.Anything,
 		remitente,
 		destinatario,
 		asuntoPrueba,
@@ -299,3 +311,506 @@ func TestHandlePlantillaPanicOnErrorSendingEmail(t *testing.T) {
 	repo.AssertExpectations(t)
 	emailService.AssertExpectations(t)
 }
+
+func TestHandlePlantillaRetryOnlyDeferredRecipients(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC003",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatario + "," + destinatario2,
+	}, nil)
+
+	// Solo se envía al destinatario diferido en el intento anterior
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario2, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(
+		context.TODO(),
+		&models.SQSMessage{
+			IDPlantilla:   "PC003",
+			RetryCount:    1,
+			Destinatarios: []string{destinatario2},
+		},
+		"messageID",
+	)
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaRetryIgnoresForeignRecipients(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC003",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatario + "," + destinatario2,
+	}, nil)
+
+	// La dirección ajena a la plantilla se descarta
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario2, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(
+		context.TODO(),
+		&models.SQSMessage{
+			IDPlantilla:   "PC003",
+			Destinatarios: []string{"atacante@evil.com", "DEST@test.com"},
+		},
+		"messageID",
+	)
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaRetriesDeferredSandboxRecipients(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC003",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatario + "," + destinatario2,
+	}, nil)
+
+	// La dirección de pruebas se difiere en el primer intento y se acepta en el reintento
+	emailService.On("SendEmail", mock.Anything, remitente, "qa@test.com", "[QA] "+asuntoPrueba, mock.Anything).
+		Return(&email.DeliveryError{Results: []email.RecipientResult{
+			{Address: "qa@test.com", Status: email.RecipientDeferred, Code: 451},
+		}}).Once()
+	emailService.On("SendEmail", mock.Anything, remitente, "qa@test.com", "[QA] "+asuntoPrueba, mock.Anything).
+		Return(nil).Once()
+
+	service := NewPlantillaService(repo, email.NewSandboxEmailService(emailService, "qa", "qa@test.com"))
+	msg := &models.SQSMessage{IDPlantilla: "PC003"}
+
+	err := service.HandlePlantilla(context.TODO(), msg, "messageID")
+
+	var deliveryErr *email.DeliveryError
+	assert.True(t, errors.As(err, &deliveryErr))
+	assert.False(t, models.IsPermanentError(err))
+	assert.Equal(t, []string{destinatario, destinatario2}, deliveryErr.DeferredRecipients())
+
+	// El reintento lleva los destinatarios originales, que sí pertenecen a la plantilla
+	msg.Destinatarios = deliveryErr.DeferredRecipients()
+	err = service.HandlePlantilla(context.TODO(), msg, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaRejectsOnlyForeignRecipients(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC003",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatario,
+	}, nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(
+		context.TODO(),
+		&models.SQSMessage{
+			IDPlantilla:   "PC003",
+			Destinatarios: []string{"atacante@evil.com"},
+		},
+		"messageID",
+	)
+
+	assert.Error(t, err)
+	assert.True(t, models.IsPermanentError(err))
+	emailService.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything)
+}
+
+func TestHandlePlantillaPassesContextToEmailService(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC003",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatario,
+	}, nil)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "lambda")
+	emailService.On("SendEmail", ctx, remitente, destinatario, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(ctx, &models.SQSMessage{IDPlantilla: "PC003"}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaRequestsSMIMEProtection(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC004").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC004",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatario,
+		FirmarSMIME:  true,
+		CifrarSMIME:  true,
+	}, nil)
+
+	withSMIME := mock.MatchedBy(func(ctx context.Context) bool {
+		return email.SMIMEFromContext(ctx) == email.SMIMEOptions{Sign: true, Encrypt: true}
+	})
+	emailService.On("SendEmail", withSMIME, remitente, destinatario, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(context.Background(), &models.SQSMessage{IDPlantilla: "PC004"}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaHTMLEngineEscapesParameters(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC005").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC005",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       "<p>Archivo {{.archivo}}</p>{{if .codigo}}<p>Código {{.codigo}}</p>{{end}}",
+		Remitente:    remitente,
+		Destinatario: destinatario,
+		Motor:        "html",
+	}, nil)
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, asuntoPrueba,
+		"<p>Archivo &lt;script&gt;x&lt;/script&gt;.csv</p>").Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC005",
+		Parametro:   []models.ParametrosSQS{{Nombre: "archivo", Valor: "<script>x</script>.csv"}},
+	}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaInvalidTemplateIsPermanent(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC005").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC005",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       "{{if .archivo}}sin cerrar",
+		Remitente:    remitente,
+		Destinatario: destinatario,
+		Motor:        "html",
+	}, nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC005"}, "messageID")
+
+	assert.True(t, models.IsPermanentError(err))
+	emailService.AssertNotCalled(t, "SendEmail")
+}
+
+func TestHandlePlantillaRendersSubject(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC006").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC006",
+		Asunto:       "Rechazo del archivo &archivo",
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatario,
+	}, nil)
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario,
+		"Rechazo del archivo pagos & cobros.csv (lote 2)", cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC006",
+		Parametro:   []models.ParametrosSQS{{Nombre: "archivo", Valor: "pagos & cobros.csv\r\n(lote 2)"}},
+	}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaRendersAddresses(t *testing.T) {
+	plantilla := func(renderizar bool) *models.Plantilla {
+		return &models.Plantilla{
+			IDPlantilla:           "PC006",
+			Asunto:                asuntoPrueba,
+			Cuerpo:                cuerpoPrueba,
+			Remitente:             `"Pagos {{.area}}" <test@example.com>`,
+			Destinatario:          `"{{.analista}}" <dest@example.com>, {{.correo_copia}}`,
+			Motor:                 "html",
+			RenderizarDirecciones: renderizar,
+		}
+	}
+	parametros := []models.ParametrosSQS{
+		{Nombre: "area", Valor: "Tesorería"},
+		{Nombre: "analista", Valor: "Pérez, Ana"},
+		{Nombre: "correo_copia", Valor: "copia@test.com"},
+	}
+
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	repo.On("CheckPlantillaExists", "PC006").Return(true, plantilla(true), nil).Once()
+	emailService.On("SendEmail", mock.Anything, `"Pagos Tesorería" <test@example.com>`,
+		`"Pérez, Ana" <dest@example.com>, copia@test.com`, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+	err := service.HandlePlantilla(context.TODO(),
+		&models.SQSMessage{IDPlantilla: "PC006", Parametro: parametros}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+
+	// Sin RenderizarDirecciones los campos de dirección se envían tal como están
+	repo.On("CheckPlantillaExists", "PC006").Return(true, plantilla(false), nil).Once()
+	emailService.On("SendEmail", mock.Anything, `"Pagos {{.area}}" <test@example.com>`,
+		`"{{.analista}}" <dest@example.com>, {{.correo_copia}}`, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	err = service.HandlePlantilla(context.TODO(),
+		&models.SQSMessage{IDPlantilla: "PC006", Parametro: parametros}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaRenderedAddressMustBeValid(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("CheckPlantillaExists", "PC006").Return(true, &models.Plantilla{
+		IDPlantilla:           "PC006",
+		Asunto:                asuntoPrueba,
+		Cuerpo:                cuerpoPrueba,
+		Remitente:             remitente,
+		Destinatario:          "&correo_analista",
+		RenderizarDirecciones: true,
+	}, nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC006",
+		Parametro:   []models.ParametrosSQS{{Nombre: "correo_analista", Valor: "Ana Pérez"}},
+	}, "messageID")
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "Ana Pérez")
+	emailService.AssertNotCalled(t, "SendEmail")
+}
+
+func TestHandlePlantillaParameterCannotChangeSenderAddress(t *testing.T) {
+	plantilla := func(remitente string) *models.Plantilla {
+		return &models.Plantilla{
+			IDPlantilla:           "PC006",
+			Asunto:                asuntoPrueba,
+			Cuerpo:                cuerpoPrueba,
+			Remitente:             remitente,
+			Destinatario:          destinatario,
+			Motor:                 "html",
+			RenderizarDirecciones: true,
+		}
+	}
+	parametros := []models.ParametrosSQS{{Nombre: "area", Valor: `Pagos" <suplantador@evil.com>`}}
+
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	service := NewPlantillaService(repo, emailService)
+
+	// El parámetro solo cambia el nombre visible: la dirección sigue siendo la de la plantilla
+	repo.On("CheckPlantillaExists", "PC006").Return(true, plantilla(`"{{.area}}" <test@example.com>`), nil).Once()
+	emailService.On("SendEmail", mock.Anything, `"Pagos\" <suplantador@evil.com>" <test@example.com>`,
+		destinatario, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	err := service.HandlePlantilla(context.TODO(),
+		&models.SQSMessage{IDPlantilla: "PC006", Parametro: parametros}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+
+	// La dirección del remitente no admite marcadores
+	repo.On("CheckPlantillaExists", "PC006").Return(true, plantilla(`Pagos <{{.correo}}>`), nil).Once()
+
+	err = service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC006",
+		Parametro:   []models.ParametrosSQS{{Nombre: "correo", Valor: "suplantador@evil.com"}},
+	}, "messageID")
+
+	assert.True(t, models.IsPermanentError(err))
+	emailService.AssertNumberOfCalls(t, "SendEmail", 1)
+}
+
+func TestHandlePlantillaParameterModes(t *testing.T) {
+	plantilla := func(modo string) *models.Plantilla {
+		return &models.Plantilla{
+			IDPlantilla:    "PC007",
+			Asunto:         asuntoPrueba,
+			Cuerpo:         "Archivo &archivo rechazado: &codigo_rechazo",
+			Remitente:      remitente,
+			Destinatario:   destinatario,
+			ModoParametros: modo,
+			ValorDefecto:   "N/A",
+		}
+	}
+	msg := &models.SQSMessage{
+		IDPlantilla: "PC007",
+		Parametro: []models.ParametrosSQS{
+			{Nombre: "archivo", Valor: "pagos.csv"},
+			{Nombre: "sistema", Valor: "X"},
+		},
+	}
+
+	tests := []struct {
+		modo   string
+		cuerpo string
+	}{
+		{"", "Archivo pagos.csv rechazado: &codigo_rechazo"},
+		{"warn", "Archivo pagos.csv rechazado: &codigo_rechazo"},
+		{"default", "Archivo pagos.csv rechazado: N/A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.modo, func(t *testing.T) {
+			repo := new(MockPlantillaRepository)
+			emailService := new(MockEmailService)
+			repo.On("CheckPlantillaExists", "PC007").Return(true, plantilla(tt.modo), nil)
+			emailService.On("SendEmail", mock.Anything, remitente, destinatario, asuntoPrueba, tt.cuerpo).Return(nil)
+
+			err := NewPlantillaService(repo, emailService).HandlePlantilla(context.TODO(), msg, "messageID")
+
+			assert.NoError(t, err)
+			emailService.AssertExpectations(t)
+		})
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		repo := new(MockPlantillaRepository)
+		emailService := new(MockEmailService)
+		repo.On("CheckPlantillaExists", "PC007").Return(true, plantilla("fail"), nil)
+
+		err := NewPlantillaService(repo, emailService).HandlePlantilla(context.TODO(), msg, "messageID")
+
+		assert.True(t, models.IsPermanentError(err))
+		assert.Contains(t, err.Error(), "marcadores sin resolver: codigo_rechazo")
+		assert.Contains(t, err.Error(), "parámetros no utilizados: sistema")
+		emailService.AssertNotCalled(t, "SendEmail")
+	})
+}
+
+func TestHandlePlantillaParameterModesKeepQueryStringLinks(t *testing.T) {
+	const cuerpo = `Hola &nombre, consulte <a href="https://x.co/?a=1&b=2&ref=&referencia">su pago</a>`
+	msg := &models.SQSMessage{
+		IDPlantilla: "PC007",
+		Parametro: []models.ParametrosSQS{
+			{Nombre: "nombre", Valor: "Juan"},
+			{Nombre: "referencia", Valor: "R-1"},
+		},
+	}
+
+	for _, modo := range []string{"fail", "warn", "default"} {
+		t.Run(modo, func(t *testing.T) {
+			repo := new(MockPlantillaRepository)
+			emailService := new(MockEmailService)
+			repo.On("CheckPlantillaExists", "PC007").Return(true, &models.Plantilla{
+				IDPlantilla:    "PC007",
+				Asunto:         asuntoPrueba,
+				Cuerpo:         cuerpo,
+				Remitente:      remitente,
+				Destinatario:   destinatario,
+				ModoParametros: modo,
+				ValorDefecto:   "N/A",
+			}, nil)
+			// Los parámetros de la consulta no son marcadores: el enlace solo cambia en &referencia
+			emailService.On("SendEmail", mock.Anything, remitente, destinatario, asuntoPrueba,
+				`Hola Juan, consulte <a href="https://x.co/?a=1&b=2&ref=R-1">su pago</a>`).Return(nil)
+
+			err := NewPlantillaService(repo, emailService).HandlePlantilla(context.TODO(), msg, "messageID")
+
+			assert.NoError(t, err)
+			emailService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandlePlantillaFailModeAcceptsParameterPrefixPlaceholder(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	repo.On("CheckPlantillaExists", "PC007").Return(true, &models.Plantilla{
+		IDPlantilla:    "PC007",
+		Asunto:         asuntoPrueba,
+		Cuerpo:         "Archivo &archivo_rechazado",
+		Remitente:      remitente,
+		Destinatario:   destinatario,
+		ModoParametros: "fail",
+	}, nil)
+	// El motor legacy reconoce el parámetro archivo como prefijo del marcador, igual al validar que al renderizar
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, asuntoPrueba, "Archivo A_rechazado").
+		Return(nil)
+
+	msg := &models.SQSMessage{
+		IDPlantilla: "PC007",
+		Parametro:   []models.ParametrosSQS{{Nombre: "archivo", Valor: "A"}},
+	}
+	err := NewPlantillaService(repo, emailService).HandlePlantilla(context.TODO(), msg, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaUsesRequestedLocale(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+
+	repo.On("FindPlantillaIdioma", "PC003", "en-US").Return(true, &models.Plantilla{
+		IDPlantilla:  "PC003",
+		Idioma:       "en",
+		Asunto:       "Subject",
+		Cuerpo:       "Hello &nombre",
+		Remitente:    remitente,
+		Destinatario: destinatario,
+	}, nil)
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, "Subject", "Hello Juan").Return(nil)
+
+	service := NewPlantillaService(repo, emailService)
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla: "PC003",
+		Idioma:      "en-US",
+		Parametro:   []models.ParametrosSQS{{Nombre: "nombre", Valor: "Juan"}},
+	}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+	repo.AssertNotCalled(t, "CheckPlantillaExists", mock.Anything)
+}
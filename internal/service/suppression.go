@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/email"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+	"strings"
+)
+
+// SuppressionList define la consulta de la lista de supresión previa a cada envío.
+type SuppressionList interface {
+	FindSuppressed(addresses []string) ([]models.Supresion, error)
+}
+
+// SendRecorder registra los envíos que el servicio omite sin llegar a un proveedor de correo.
+type SendRecorder interface {
+	RecordSend(envio *models.EnvioCorreo) error
+}
+
+// WithSuppressionList hace que el servicio descarte los destinatarios suprimidos antes de enviar.
+func WithSuppressionList(list SuppressionList) PlantillaServiceOption {
+	return func(s *PlantillaService) {
+		s.suppressions = list
+	}
+}
+
+// WithSendRecorder hace que el servicio registre en la tabla de envíos los correos omitidos porque todos sus
+// destinatarios están suprimidos.
+func WithSendRecorder(recorder SendRecorder) PlantillaServiceOption {
+	return func(s *PlantillaService) {
+		s.recorder = recorder
+	}
+}
+
+// recordSuppressed registra el envío omitido con estado OMITIDO. Un fallo al registrar no afecta el mensaje.
+func (s *PlantillaService) recordSuppressed(remitente, destinatarios, messageID string) {
+	logs.LogWarn("Envío omitido: todos los destinatarios están en la lista de supresión", messageID)
+	if s.recorder == nil {
+		return
+	}
+
+	envio := &models.EnvioCorreo{
+		MessageID:     messageID,
+		Proveedor:     models.ProveedorNinguno,
+		Remitente:     remitente,
+		Destinatarios: destinatarios,
+		Estado:        models.EstadoEnvioOmitido,
+		Error:         "todos los destinatarios están en la lista de supresión",
+	}
+	if err := s.recorder.RecordSend(envio); err != nil {
+		logs.LogWarn(fmt.Sprintf("No fue posible registrar el envío omitido: %v", err), messageID)
+	}
+}
+
+// filterSuppressed devuelve los destinatarios que no están suprimidos, separados por comas, e indica si todos
+// estaban suprimidos. Si la lista no puede interpretarse se devuelve sin cambios para que el servicio de correo
+// reporte el error.
+func (s *PlantillaService) filterSuppressed(destinatarios, messageID string) (string, bool, error) {
+	addresses, err := email.ParseAddressList(destinatarios)
+	if err != nil {
+		return destinatarios, false, nil
+	}
+
+	lookup := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		lookup = append(lookup, address.Address)
+	}
+
+	supresiones, err := s.suppressions.FindSuppressed(lookup)
+	if err != nil {
+		logs.LogError("Error al consultar la lista de supresión", err, messageID)
+		return "", false, err
+	}
+	if len(supresiones) == 0 {
+		return destinatarios, false, nil
+	}
+
+	suppressed := map[string]models.Supresion{}
+	for _, supresion := range supresiones {
+		suppressed[strings.ToLower(supresion.Direccion)] = supresion
+	}
+
+	remaining := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if supresion, ok := suppressed[strings.ToLower(address.Address)]; ok {
+			logs.LogWarn(fmt.Sprintf("Destinatario %s omitido por estar en la lista de supresión (motivo: %s, origen: %s)",
+				address.Address, supresion.Motivo, supresion.Origen), messageID)
+			continue
+		}
+		remaining = append(remaining, address.String())
+	}
+	return strings.Join(remaining, ","), len(remaining) == 0, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSuppressionList struct {
+	mock.Mock
+}
+
+func (m *MockSuppressionList) FindSuppressed(addresses []string) ([]models.Supresion, error) {
+	args := m.Called(addresses)
+	return args.Get(0).([]models.Supresion), args.Error(1)
+}
+
+type MockSendRecorder struct {
+	mock.Mock
+}
+
+func (m *MockSendRecorder) RecordSend(envio *models.EnvioCorreo) error {
+	args := m.Called(envio)
+	return args.Error(0)
+}
+
+func plantillaConDestinatarios(destinatarios string) *models.Plantilla {
+	return &models.Plantilla{
+		IDPlantilla:  "PC003",
+		Asunto:       asuntoPrueba,
+		Cuerpo:       cuerpoPrueba,
+		Remitente:    remitente,
+		Destinatario: destinatarios,
+	}
+}
+
+func TestHandlePlantillaDropsSuppressedRecipients(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	suppressions := new(MockSuppressionList)
+
+	repo.On("CheckPlantillaExists", "PC003").
+		Return(true, plantillaConDestinatarios("Dest@Example.com, dest@test.com"), nil)
+	suppressions.On("FindSuppressed", []string{"Dest@example.com", destinatario2}).
+		Return([]models.Supresion{{Direccion: destinatario, Motivo: models.MotivoSupresionRebote, Origen: "ses"}}, nil)
+	emailService.On("SendEmail", mock.Anything, remitente, "<"+destinatario2+">", asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService, WithSuppressionList(suppressions))
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC003"}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaAllRecipientsSuppressedIsSkipped(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	suppressions := new(MockSuppressionList)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, plantillaConDestinatarios(destinatario), nil)
+	suppressions.On("FindSuppressed", []string{destinatario}).
+		Return([]models.Supresion{{Direccion: destinatario, Motivo: models.MotivoSupresionQueja, Origen: "ses"}}, nil)
+
+	recorder := new(MockSendRecorder)
+	recorder.On("RecordSend", &models.EnvioCorreo{
+		MessageID:     "messageID",
+		Proveedor:     models.ProveedorNinguno,
+		Remitente:     remitente,
+		Destinatarios: destinatario,
+		Estado:        models.EstadoEnvioOmitido,
+		Error:         "todos los destinatarios están en la lista de supresión",
+	}).Return(nil)
+
+	service := NewPlantillaService(repo, emailService, WithSuppressionList(suppressions), WithSendRecorder(recorder))
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC003"}, "messageID")
+
+	assert.NoError(t, err)
+	recorder.AssertExpectations(t)
+	emailService.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandlePlantillaSuppressedRecordFailureIsIgnored(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	suppressions := new(MockSuppressionList)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, plantillaConDestinatarios(destinatario), nil)
+	suppressions.On("FindSuppressed", []string{destinatario}).
+		Return([]models.Supresion{{Direccion: destinatario, Motivo: models.MotivoSupresionRebote, Origen: "ses"}}, nil)
+	recorder := new(MockSendRecorder)
+	recorder.On("RecordSend", mock.Anything).Return(errors.New("db down"))
+
+	service := NewPlantillaService(repo, emailService, WithSuppressionList(suppressions), WithSendRecorder(recorder))
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC003"}, "messageID")
+
+	assert.NoError(t, err)
+	recorder.AssertExpectations(t)
+}
+
+func TestHandlePlantillaSuppressionLookupError(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	suppressions := new(MockSuppressionList)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, plantillaConDestinatarios(destinatario), nil)
+	suppressions.On("FindSuppressed", []string{destinatario}).
+		Return([]models.Supresion(nil), errors.New("conexión cerrada"))
+
+	service := NewPlantillaService(repo, emailService, WithSuppressionList(suppressions))
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC003"}, "messageID")
+
+	assert.Error(t, err)
+	emailService.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandlePlantillaWithoutSuppressedRecipientsKeepsList(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	suppressions := new(MockSuppressionList)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, plantillaConDestinatarios(destinatario), nil)
+	suppressions.On("FindSuppressed", []string{destinatario}).Return([]models.Supresion(nil), nil)
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, asuntoPrueba, cuerpoPrueba).Return(nil)
+
+	service := NewPlantillaService(repo, emailService, WithSuppressionList(suppressions))
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{IDPlantilla: "PC003"}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+	"gmf_message_processor/internal/logs"
+	"gmf_message_processor/internal/models"
+)
+
+// VersionHistory define la consulta de versiones anteriores de una plantilla.
+type VersionHistory interface {
+	FindVersion(idPlantilla, idioma string, version int) (*models.PlantillaVersion, error)
+}
+
+// WithVersionHistory permite que los mensajes fijen la versión de la plantilla con version_plantilla.
+func WithVersionHistory(history VersionHistory) PlantillaServiceOption {
+	return func(s *PlantillaService) {
+		s.versions = history
+	}
+}
+
+// findPinnedVersion devuelve la plantilla con el contenido de la versión fijada por el mensaje. El idioma se resuelve
+// igual que sin versión fijada (models.IdiomaChain) y la versión debe existir en ese idioma: no se busca en otros,
+// ya que los números de versión de cada idioma son independientes. Una versión inexistente, o un mensaje con
+// versión cuando el historial no está configurado, es un error permanente.
+func (s *PlantillaService) findPinnedVersion(
+	idPlantilla, idioma string, version int, messageID string) (*models.Plantilla, error) {
+	if s.versions == nil {
+		return nil, models.NewPermanentError(
+			fmt.Errorf("error: el mensaje fija la versión %d de la plantilla %s y el historial de versiones no está "+
+				"configurado", version, idPlantilla))
+	}
+
+	plantilla, err := s.lookupPlantilla(idPlantilla, idioma, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	plantillaVersion, err := s.versions.FindVersion(idPlantilla, plantilla.Idioma, version)
+	if err != nil {
+		logs.LogError(fmt.Sprintf("Error al consultar la versión %d de la plantilla %s (%s)",
+			version, idPlantilla, plantilla.Idioma), err, messageID)
+		return nil, err
+	}
+	if plantillaVersion == nil {
+		return nil, models.NewPermanentError(fmt.Errorf("error: la versión %d de la plantilla %s (%s) no existe",
+			version, idPlantilla, plantilla.Idioma))
+	}
+	return plantillaVersion.Plantilla(), nil
+}
+
+// describeVersion describe el idioma y la versión renderizados para los logs.
+func describeVersion(plantilla *models.Plantilla) string {
+	if plantilla.Version == 0 {
+		return fmt.Sprintf("idioma %s, sin versión registrada", plantilla.Idioma)
+	}
+	return fmt.Sprintf("idioma %s, versión %d", plantilla.Idioma, plantilla.Version)
+}
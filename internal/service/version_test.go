@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"gmf_message_processor/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockVersionHistory struct {
+	mock.Mock
+}
+
+func (m *MockVersionHistory) FindVersion(
+	idPlantilla, idioma string, version int) (*models.PlantillaVersion, error) {
+	args := m.Called(idPlantilla, idioma, version)
+	return args.Get(0).(*models.PlantillaVersion), args.Error(1)
+}
+
+func TestHandlePlantillaUsesPinnedVersion(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	versions := new(MockVersionHistory)
+
+	repo.On("CheckPlantillaExists", "PC003").Return(true, &models.Plantilla{IDPlantilla: "PC003", Idioma: "es-CO"}, nil)
+	versions.On("FindVersion", "PC003", "es-CO", 2).Return(&models.PlantillaVersion{
+		IDPlantilla:  "PC003",
+		Version:      2,
+		Asunto:       "Asunto v2",
+		Cuerpo:       "Hola &nombre (v2)",
+		Remitente:    remitente,
+		Destinatario: destinatario,
+	}, nil)
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, "Asunto v2", "Hola Juan (v2)").Return(nil)
+
+	service := NewPlantillaService(repo, emailService, WithVersionHistory(versions))
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla:      "PC003",
+		VersionPlantilla: 2,
+		Parametro:        []models.ParametrosSQS{{Nombre: "nombre", Valor: "Juan"}},
+	}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaPinnedVersionNotFoundIsPermanent(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	versions := new(MockVersionHistory)
+	repo.On("CheckPlantillaExists", "PC003").Return(true, &models.Plantilla{IDPlantilla: "PC003", Idioma: "es-CO"}, nil)
+	versions.On("FindVersion", "PC003", "es-CO", 7).Return((*models.PlantillaVersion)(nil), nil)
+
+	service := NewPlantillaService(repo, new(MockEmailService), WithVersionHistory(versions))
+
+	err := service.HandlePlantilla(context.TODO(),
+		&models.SQSMessage{IDPlantilla: "PC003", VersionPlantilla: 7}, "messageID")
+
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "la versión 7 de la plantilla PC003 (es-CO) no existe")
+
+	// Sin historial configurado una versión fijada tampoco puede resolverse
+	err = NewPlantillaService(new(MockPlantillaRepository), new(MockEmailService)).HandlePlantilla(
+		context.TODO(), &models.SQSMessage{IDPlantilla: "PC003", VersionPlantilla: 7}, "messageID")
+	assert.True(t, models.IsPermanentError(err))
+}
+
+func TestHandlePlantillaPinnedVersionUsesResolvedLocale(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	versions := new(MockVersionHistory)
+
+	// La plantilla no existe en en-US: el idioma resuelto es en
+	repo.On("FindPlantillaIdioma", "PC003", "en-us").
+		Return(true, &models.Plantilla{IDPlantilla: "PC003", Idioma: "en"}, nil)
+	versions.On("FindVersion", "PC003", "en", 2).Return(&models.PlantillaVersion{
+		IDPlantilla:  "PC003",
+		Idioma:       "en",
+		Version:      2,
+		Asunto:       "Subject v2",
+		Cuerpo:       "Hello &nombre",
+		Remitente:    remitente,
+		Destinatario: destinatario,
+	}, nil)
+	emailService.On("SendEmail", mock.Anything, remitente, destinatario, "Subject v2", "Hello Juan").Return(nil)
+
+	service := NewPlantillaService(repo, emailService, WithVersionHistory(versions))
+
+	err := service.HandlePlantilla(context.TODO(), &models.SQSMessage{
+		IDPlantilla:      "PC003",
+		Idioma:           "en-us",
+		VersionPlantilla: 2,
+		Parametro:        []models.ParametrosSQS{{Nombre: "nombre", Valor: "Juan"}},
+	}, "messageID")
+
+	assert.NoError(t, err)
+	emailService.AssertExpectations(t)
+}
+
+func TestHandlePlantillaPinnedVersionDoesNotFallBackAcrossLocales(t *testing.T) {
+	repo := new(MockPlantillaRepository)
+	emailService := new(MockEmailService)
+	versions := new(MockVersionHistory)
+
+	repo.On("FindPlantillaIdioma", "PC003", "en").Return(true, &models.Plantilla{IDPlantilla: "PC003", Idioma: "en"}, nil)
+	versions.On("FindVersion", "PC003", "en", 3).Return((*models.PlantillaVersion)(nil), nil)
+
+	service := NewPlantillaService(repo, emailService, WithVersionHistory(versions))
+
+	err := service.HandlePlantilla(context.TODO(),
+		&models.SQSMessage{IDPlantilla: "PC003", Idioma: "en", VersionPlantilla: 3}, "messageID")
+
+	// La versión 3 de es-CO no se usa en lugar de la de en
+	assert.True(t, models.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "la versión 3 de la plantilla PC003 (en) no existe")
+	versions.AssertNotCalled(t, "FindVersion", "PC003", "es-CO", 3)
+	emailService.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything)
+}
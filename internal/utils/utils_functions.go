@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type UtilsInterface interface {
@@ -29,6 +30,13 @@ type UtilsInterface interface {
 		queueURL string,
 		messageBody string,
 		messageID string) error
+	SendMessageToQueueWithDelay(
+		ctx context.Context,
+		client aws.SQSAPI,
+		queueURL string,
+		messageBody string,
+		messageID string,
+		delay time.Duration) error
 }
 
 type Utils struct{}
@@ -65,7 +73,8 @@ func (u *Utils) ValidateSQSMessage(body string) (*models.SQSMessage, error) {
 	if err := json.Unmarshal([]byte(body), &msg); err != nil {
 		return nil, errors.New("invalid JSON format")
 	}
-	if msg.IDPlantilla == "" {
+	// Los mensajes de control pueden omitir id_plantilla
+	if msg.IDPlantilla == "" && msg.Control == "" {
 		return nil, errors.New("id_plantilla is required")
 	}
 	return &msg, nil
@@ -87,10 +96,23 @@ func (u *Utils) SendMessageToQueue(
 		}
 	}
 
+	return u.SendMessageToQueueWithDelay(
+		ctx, client, queueURL, messageBody, messageID, time.Duration(delaySeconds)*time.Second)
+}
+
+// SendMessageToQueueWithDelay envía el mensaje con un retraso explícito en lugar de SQS_MESSAGE_DELAY (por
+// ejemplo, al diferir un envío por la respuesta del servidor SMTP).
+func (u *Utils) SendMessageToQueueWithDelay(
+	ctx context.Context,
+	client aws.SQSAPI,
+	queueURL string,
+	messageBody string,
+	messageID string,
+	delay time.Duration) error {
 	_, err := client.SendMessage(ctx, &sqs.SendMessageInput{
 		QueueUrl:     &queueURL,
 		MessageBody:  &messageBody,
-		DelaySeconds: int32(delaySeconds),
+		DelaySeconds: int32(delay / time.Second),
 	})
 
 	if err != nil {
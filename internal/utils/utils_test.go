@@ -6,6 +6,7 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/stretchr/testify/assert"
@@ -104,6 +105,15 @@ func TestValidateSQSMessage(t *testing.T) {
 	assert.Equal(t, "id_plantilla is required", err.Error())
 }
 
+func TestValidateSQSMessageControl(t *testing.T) {
+	u := &utils.Utils{}
+
+	msg, err := u.ValidateSQSMessage(`{"control": "invalidar_cache"}`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.ControlInvalidarCache, msg.Control)
+}
+
 // TestSendMessageToQueue tests the SendMessageToQueue function.
 func TestSendMessageToQueue(t *testing.T) {
 	u := &utils.Utils{}
@@ -248,3 +258,22 @@ func TestReplacePlaceholdersOrderByLength(t *testing.T) {
 	// Validar que los placeholders se reemplazan correctamente sin conflictos.
 	assert.Equal(t, "Archivo: archivo1.txt, Incidente: archivo1_incidentes.txt", result)
 }
+
+func TestSendMessageToQueueWithDelay(t *testing.T) {
+	u := &utils.Utils{}
+	mockSQS := new(MockSQSAPI)
+
+	// El retraso explícito reemplaza SQS_MESSAGE_DELAY
+	os.Setenv("SQS_MESSAGE_DELAY", "5")
+	defer os.Unsetenv("SQS_MESSAGE_DELAY")
+
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return input.DelaySeconds == 600
+	})).Return(&sqs.SendMessageOutput{}, nil)
+
+	err := u.SendMessageToQueueWithDelay(context.TODO(), mockSQS, queueURL, messageBody, "testMessageID",
+		10*time.Minute)
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+}